@@ -0,0 +1,177 @@
+// Package compression detects the compression format of files and decompresses them as they are read
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// Format is the compression format of a file
+type Format string
+
+const (
+	None  Format = ""
+	Gzip  Format = "gzip"
+	Zstd  Format = "zstd"
+	Bzip2 Format = "bzip2"
+	Xz    Format = "xz"
+	Lz4   Format = "lz4"
+	Zip   Format = "zip"
+)
+
+// magicSize is the number of bytes read from the head of a file to detect its compression format
+const magicSize = 10
+
+// extensions maps file extensions to the compression format they denote
+var extensions = map[string]Format{
+	".gz":   Gzip,
+	".zst":  Zstd,
+	".bz2":  Bzip2,
+	".xz":   Xz,
+	".lz4":  Lz4,
+	".zip":  Zip,
+	".tgz":  Gzip,
+	".tbz2": Bzip2,
+	".txz":  Xz,
+}
+
+// fromMagic returns the compression format indicated by the magic bytes at the head of a file
+func fromMagic(head []byte) Format {
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return Gzip
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return Zstd
+	case bytes.HasPrefix(head, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return Xz
+	case bytes.HasPrefix(head, []byte{0x04, 0x22, 0x4d, 0x18}):
+		return Lz4
+	case bytes.HasPrefix(head, []byte{'P', 'K', 0x03, 0x04}):
+		return Zip
+	// the bzip2 magic is followed by the block size (1-9) and the block header magic (the BCD digits of pi)
+	// check all of these, as a text file may start with "BZh"
+	case len(head) >= 10 && bytes.HasPrefix(head, []byte("BZh")) && head[3] >= '1' && head[3] <= '9' &&
+		bytes.Equal(head[4:10], []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}):
+		return Bzip2
+	default:
+		return None
+	}
+}
+
+// Detect returns the compression format of the file, based on its extension
+// or, if it does not have a compressed extension, the magic bytes at the head of the file
+func Detect(filePath string) Format {
+	if c, ok := extensions[strings.ToLower(path.Ext(filePath))]; ok {
+		return c
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return None
+	}
+	defer f.Close()
+
+	head := make([]byte, magicSize)
+	n, _ := io.ReadFull(f, head)
+	return fromMagic(head[:n])
+}
+
+// Open opens the file, decompressing it if it is compressed
+func Open(filePath string) (io.ReadCloser, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return Decompress(f, filePath)
+}
+
+// Decompress wraps the reader in a streaming decompressor if the data is compressed
+// The compression format is determined from the extension of the given name or, if it does not have a compressed
+// extension, the magic bytes at the head of the data. If the decompressor cannot be created, the reader is closed
+// NOTE: zip files cannot be streamed, so are returned as is
+func Decompress(r io.ReadCloser, name string) (io.ReadCloser, error) {
+	c, ok := extensions[strings.ToLower(path.Ext(name))]
+	if !ok {
+		br := bufio.NewReader(r)
+		// an error here means the data is shorter than the magic - just use what we have
+		head, _ := br.Peek(magicSize)
+		c = fromMagic(head)
+		r = NewReadCloser(br, r)
+	}
+
+	var decompressor io.Reader
+	var closers []io.Closer
+	switch c {
+	case Gzip:
+		gzReader, err := gzip.NewReader(r)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		decompressor = gzReader
+		closers = append(closers, gzReader)
+	case Zstd:
+		zstdReader, err := zstd.NewReader(r)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		decompressor = zstdReader
+		closers = append(closers, zstdReader.IOReadCloser())
+	case Bzip2:
+		decompressor = bzip2.NewReader(r)
+	case Xz:
+		xzReader, err := xz.NewReader(r)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		decompressor = xzReader
+	case Lz4:
+		decompressor = lz4.NewReader(r)
+	default:
+		return r, nil
+	}
+	return NewReadCloser(decompressor, append(closers, r)...), nil
+}
+
+// DecompressedName returns the name of the file once it has been decompressed, i.e. without the compressed extension
+func DecompressedName(name string) string {
+	ext := path.Ext(name)
+	if c, ok := extensions[strings.ToLower(ext)]; ok && c != Zip {
+		return strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+// NewReadCloser returns an io.ReadCloser which reads from the reader and, when closed, closes the closers in order
+// (e.g. a decompressor and the underlying file)
+func NewReadCloser(r io.Reader, closers ...io.Closer) io.ReadCloser {
+	return &readCloser{Reader: r, closers: closers}
+}
+
+// readCloser is an io.ReadCloser which closes the decompressor and the underlying file
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *readCloser) Close() error {
+	var err error
+	for _, c := range d.closers {
+		if closeErr := c.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
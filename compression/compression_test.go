@@ -0,0 +1,135 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// bzip2TestFile is a bzip2 compressed file containing the lines "bzip2 1" and "bzip2 2"
+// (the standard library has no bzip2 writer, so this is checked in)
+const bzip2TestFile = "test_data/app.log.bz2"
+
+func TestDecompress(t *testing.T) {
+	bzip2Data, err := os.ReadFile(bzip2TestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{name: "app.log.gz", data: compressTestData(t, Gzip, "gzip"), expected: "gzip"},
+		{name: "app.log.zst", data: compressTestData(t, Zstd, "zstd"), expected: "zstd"},
+		{name: "app.log.xz", data: compressTestData(t, Xz, "xz"), expected: "xz"},
+		{name: "app.log.lz4", data: compressTestData(t, Lz4, "lz4"), expected: "lz4"},
+		{name: "app.log.bz2", data: bzip2Data, expected: "bzip2 1\nbzip2 2\n"},
+		// the compression is detected from the magic bytes if the extension is not a compressed extension
+		{name: "app.log.1", data: compressTestData(t, Gzip, "gzip magic"), expected: "gzip magic"},
+		{name: "app.log.2", data: compressTestData(t, Zstd, "zstd magic"), expected: "zstd magic"},
+		{name: "app.log.3", data: compressTestData(t, Xz, "xz magic"), expected: "xz magic"},
+		{name: "app.log.4", data: compressTestData(t, Lz4, "lz4 magic"), expected: "lz4 magic"},
+		{name: "app.log.5", data: bzip2Data, expected: "bzip2 1\nbzip2 2\n"},
+		// uncompressed data is returned as is - including text which starts with the bzip2 magic
+		{name: "app.log", data: []byte("plain"), expected: "plain"},
+		{name: "bzh.log", data: []byte("BZh91 is not bzip2"), expected: "BZh91 is not bzip2"},
+		{name: "short.log", data: []byte("a"), expected: "a"},
+		{name: "empty.log", data: nil, expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Decompress(io.NopCloser(bytes.NewReader(tt.data)), tt.name)
+			if err != nil {
+				t.Fatalf("Decompress() error = %v", err)
+			}
+			defer r.Close()
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("error reading decompressed data: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		data     []byte
+		expected Format
+	}{
+		{name: "app.log.XZ", data: []byte("extension is used"), expected: Xz},
+		{name: "app.log.1", data: compressTestData(t, Zstd, "zstd"), expected: Zstd},
+		{name: "app.zip.1", data: []byte("PK\x03\x04"), expected: Zip},
+		{name: "app.log", data: []byte("plain text"), expected: None},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if c := Detect(path); c != tt.expected {
+				t.Errorf("Detect() = %q, expected %q", c, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDecompressedName(t *testing.T) {
+	tests := map[string]string{
+		"app.log.gz":  "app.log",
+		"app.log.XZ":  "app.log",
+		"bundle.tgz":  "bundle",
+		"archive.zip": "archive.zip",
+		"app.log":     "app.log",
+	}
+	for name, expected := range tests {
+		if got := DecompressedName(name); got != expected {
+			t.Errorf("DecompressedName(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+// compressTestData compresses the data using the given compression format
+func compressTestData(t *testing.T, c Format, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch c {
+	case Gzip:
+		w = gzip.NewWriter(&buf)
+	case Zstd:
+		w, err = zstd.NewWriter(&buf)
+	case Xz:
+		w, err = xz.NewWriter(&buf)
+	case Lz4:
+		w = lz4.NewWriter(&buf)
+	default:
+		t.Fatalf("unsupported compression %s", c)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(w, strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
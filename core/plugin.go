@@ -7,7 +7,14 @@ import (
 	"log/slog"
 
 	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/tailpipe-plugin-core/formats"
+	"github.com/turbot/tailpipe-plugin-core/sources/command"
+	"github.com/turbot/tailpipe-plugin-core/sources/container_logs"
 	"github.com/turbot/tailpipe-plugin-core/sources/file"
+	"github.com/turbot/tailpipe-plugin-core/sources/http_receiver"
+	"github.com/turbot/tailpipe-plugin-core/sources/journal"
+	"github.com/turbot/tailpipe-plugin-core/sources/stdin"
+	"github.com/turbot/tailpipe-plugin-core/sources/syslog"
 	"github.com/turbot/tailpipe-plugin-core/tables/log"
 	"github.com/turbot/tailpipe-plugin-sdk/context_values"
 	sdkformats "github.com/turbot/tailpipe-plugin-sdk/formats"
@@ -21,16 +28,36 @@ import (
 func init() {
 	// register sources
 	row_source.RegisterRowSource[*file.FileSource]()
+	row_source.RegisterRowSource[*stdin.StdinSource]()
+	row_source.RegisterRowSource[*syslog.SyslogSource]()
+	row_source.RegisterRowSource[*http_receiver.HttpReceiverSource]()
+	row_source.RegisterRowSource[*command.CommandSource]()
+	row_source.RegisterRowSource[*journal.JournalSource]()
+	row_source.RegisterRowSource[*container_logs.ContainerLogsSource]()
 
 	// register formats - these are actually defined in the sdk so other plugins can use them as default -
 	// but we register them as ours
-	table.RegisterFormat[*sdkformats.Grok]()
-	table.RegisterFormat[*sdkformats.Regex]()
 	table.RegisterFormat[*sdkformats.Delimited]()
 	table.RegisterFormat[*sdkformats.JsonLines]()
 	table.RegisterFormatPresets(sdkformats.DefaultJsonLines)
 	table.RegisterFormatPresets(sdkformats.DefaultDelimited)
 
+	// register the formats defined by this plugin
+	// (the grok and regex formats extend the sdk formats to support multiline records)
+	table.RegisterFormat[*formats.Grok]()
+	table.RegisterFormat[*formats.Regex]()
+	table.RegisterFormat[*formats.Evtx]()
+	table.RegisterFormat[*formats.Json]()
+	table.RegisterFormat[*formats.Logfmt]()
+	table.RegisterFormat[*formats.Syslog]()
+	table.RegisterFormat[*formats.Cef]()
+	table.RegisterFormat[*formats.Leef]()
+	table.RegisterFormat[*formats.W3c]()
+	table.RegisterFormat[*formats.AccessLog]()
+	table.RegisterFormatPresets(formats.DefaultEvtx, formats.DefaultJson, formats.DefaultLogfmt, formats.DefaultSyslog, formats.DefaultCef, formats.DefaultLeef, formats.DefaultW3c)
+	// the access log presets for common web servers and proxies, e.g. access_log.nginx_combined
+	table.RegisterFormatPresets(formats.AccessLogPresets...)
+
 }
 
 const PluginName = "core"
@@ -0,0 +1,263 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const AccessLogFormatIdentifier = "access_log"
+
+// AccessLogPresets are the access log formats exported by the core plugin - one for each style, named after the style
+// (e.g. access_log.nginx_combined)
+var AccessLogPresets = accessLogPresets()
+
+func accessLogPresets() []formats.Format {
+	var presets []formats.Format
+	for _, name := range accessLogStyleNames() {
+		presets = append(presets, &AccessLog{
+			Name:        name,
+			Description: accessLogStyles[name].description,
+			Style:       name,
+		})
+	}
+	return presets
+}
+
+// AccessLog is a format for the access logs of common web servers and proxies - the style selects the server and log
+// format, e.g. apache_combined or haproxy_http (see accessLogStyles)
+// Every style has the columns (where the log includes the value):
+//   - timestamp (RFC 3339) and tp_timestamp (RFC 3339, UTC) - the time of the request
+//   - remote_addr, remote_user, request, method, path, protocol, http_referer and http_user_agent (strings)
+//   - status and bytes_sent (integers)
+//   - request_time_ms (float) - the duration of the request in milliseconds
+//
+// and the style specific columns of the log, e.g. upstream_host for envoy
+// The status, sizes, counts and durations are integers or floats rather than strings, so the types of the columns are
+// the same for every table which uses the format. A value of "-" is null
+type AccessLog struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+	// the style of the access log, e.g. nginx_combined
+	Style string `hcl:"style"`
+	// the time zone of timestamps which do not include one (i.e. HAProxy), e.g. "Europe/London" (default "UTC")
+	Timezone *string `hcl:"timezone,optional"`
+}
+
+func NewAccessLog() formats.Format {
+	return &AccessLog{}
+}
+
+func (a *AccessLog) Validate() error {
+	if _, ok := accessLogStyles[a.Style]; !ok {
+		return fmt.Errorf("invalid style %s: must be one of %s", a.Style, strings.Join(accessLogStyleNames(), ", "))
+	}
+	_, err := timezoneLocation(a.Timezone)
+	return err
+}
+
+// GetName returns the name of this format instance
+func (a *AccessLog) GetName() string {
+	return a.Name
+}
+
+// SetName sets the name of this format instance
+func (a *AccessLog) SetName(name string) {
+	a.Name = name
+}
+
+// GetDescription returns the description of this format instance
+func (a *AccessLog) GetDescription() string {
+	return a.Description
+}
+
+// GetProperties returns the format properties as a string map - used for introspection
+func (a *AccessLog) GetProperties() map[string]string {
+	properties := map[string]string{
+		"style": a.Style,
+	}
+	if a.Timezone != nil {
+		properties["timezone"] = *a.Timezone
+	}
+	return properties
+}
+
+// Identifier returns the format type identifier
+func (a *AccessLog) Identifier() string {
+	return AccessLogFormatIdentifier
+}
+
+func (a *AccessLog) GetRegex() (string, error) {
+	style, ok := accessLogStyles[a.Style]
+	if !ok {
+		return "", fmt.Errorf("invalid style %s", a.Style)
+	}
+	// the JSON styles do not support regex
+	if style.regex == nil {
+		return "N/A", nil
+	}
+	return style.regex.String(), nil
+}
+
+func (a *AccessLog) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	location, err := timezoneLocation(a.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &AccessLogMapper{style: accessLogStyles[a.Style], location: location}, nil
+}
+
+// AccessLogMapper maps an access log line to a DynamicRow
+type AccessLogMapper struct {
+	style *accessLogStyle
+	// the location of timestamps which do not include a time zone
+	location *time.Location
+}
+
+func (m *AccessLogMapper) Identifier() string {
+	return "access_log_mapper"
+}
+
+func (m *AccessLogMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var line string
+	switch data := a.(type) {
+	case string:
+		line = data
+	case []byte:
+		line = string(data)
+	default:
+		return nil, fmt.Errorf("expected string, got %T", a)
+	}
+
+	fields, typed, err := m.style.parse(line, m.location)
+	if err != nil {
+		return nil, err
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from access log line: %w", err)
+	}
+	// the typed values take precedence over the source values when the row is enriched
+	for column, value := range typed {
+		row.OutputColumns[column] = value
+	}
+	return row, nil
+}
+
+// parse parses an access log line, returning the fields (as strings) and the typed values of the integer, float and
+// timestamp columns
+func (s *accessLogStyle) parse(line string, location *time.Location) (map[string]string, map[string]any, error) {
+	line = strings.TrimRight(line, "\r\n")
+	var fields map[string]string
+	if s.regex != nil {
+		match := s.regex.FindStringSubmatch(line)
+		if match == nil {
+			return nil, nil, fmt.Errorf("line does not match the %s access log format", s.name)
+		}
+		fields = make(map[string]string)
+		for i, name := range s.regex.SubexpNames() {
+			value := match[i]
+			if i == 0 || name == "" || value == "" {
+				continue
+			}
+			if value == "-" {
+				if !slices.Contains(s.zeroIfDash, name) {
+					continue
+				}
+				value = "0"
+			}
+			fields[name] = unescapeAccessLogValue(value)
+		}
+	} else {
+		var err error
+		if fields, err = s.parseFunc(line); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// split the request line, e.g. "GET /index.html HTTP/1.1"
+	if request, ok := fields["request"]; ok {
+		if method, target, ok := strings.Cut(request, " "); ok {
+			fields["method"] = method
+			if path, protocol, ok := strings.Cut(target, " "); ok && strings.HasPrefix(protocol, "HTTP/") {
+				fields["path"], fields["protocol"] = path, protocol
+			} else {
+				fields["path"] = target
+			}
+		}
+	}
+
+	typed := make(map[string]any)
+	value, ok := fields[s.timestampColumn]
+	if !ok {
+		return nil, nil, fmt.Errorf("missing %s access log timestamp", s.name)
+	}
+	timestamp, err := time.ParseInLocation(s.timestampLayout, value, location)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s access log timestamp", s.name)
+	}
+	fields["timestamp"] = timestamp.Format(time.RFC3339Nano)
+	fields["tp_timestamp"] = timestamp.UTC().Format(time.RFC3339Nano)
+	typed["timestamp"] = timestamp
+
+	// NOTE: the errors do not include the value, so the row errors for an artifact are aggregated
+	for _, column := range s.integerColumns {
+		if value, ok := fields[column]; ok {
+			i, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid value for %s: expected an integer", column)
+			}
+			typed[column] = i
+		}
+	}
+	for _, column := range s.floatColumns {
+		if value, ok := fields[column]; ok {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid value for %s: expected a float", column)
+			}
+			typed[column] = f
+		}
+	}
+	return fields, typed, nil
+}
+
+// unescapeAccessLogValue unescapes a value escaped by Apache ('"' and '\' are escaped with a backslash) or Nginx
+// (characters are escaped as \xHH) - a backslash before any other character is kept
+func unescapeAccessLogValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			switch value[i+1] {
+			case '"', '\\':
+				i++
+				sb.WriteByte(value[i])
+				continue
+			case 'x':
+				if i+4 <= len(value) {
+					if c, err := strconv.ParseUint(value[i+2:i+4], 16, 8); err == nil {
+						sb.WriteByte(byte(c))
+						i += 3
+						continue
+					}
+				}
+			}
+		}
+		sb.WriteByte(value[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,305 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the access log styles
+const (
+	AccessLogStyleApacheCommon        = "apache_common"
+	AccessLogStyleApacheCombined      = "apache_combined"
+	AccessLogStyleApacheVhostCombined = "apache_vhost_combined"
+	AccessLogStyleNginxCombined       = "nginx_combined"
+	AccessLogStyleNginxMain           = "nginx_main"
+	AccessLogStyleHaproxyHttp         = "haproxy_http"
+	AccessLogStyleHaproxyTcp          = "haproxy_tcp"
+	AccessLogStyleEnvoy               = "envoy"
+	AccessLogStyleCaddy               = "caddy"
+	AccessLogStyleTraefik             = "traefik"
+)
+
+// the layout of the timestamps of the Common Log Format, e.g. "10/Oct/2000:13:55:36 -0700"
+const clfTimestampLayout = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogStyle defines how the lines of an access log style are parsed
+type accessLogStyle struct {
+	name        string
+	description string
+	// the regex which parses a line - the named groups are the columns
+	regex *regexp.Regexp
+	// the function which parses a line, if the style is not parsed by a regex
+	parseFunc func(line string) (map[string]string, error)
+	// columns which are 0 (rather than null) if their value is "-"
+	zeroIfDash []string
+	// the column containing the timestamp, and its layout
+	timestampColumn string
+	timestampLayout string
+	// the columns converted to integers and floats
+	integerColumns []string
+	floatColumns   []string
+}
+
+// accessLogQuoted returns a regex group matching a quoted value, in which quotes are escaped with a backslash
+func accessLogQuoted(name string) string {
+	return fmt.Sprintf(`"(?P<%s>(?:[^"\\]|\\.)*)"`, name)
+}
+
+var (
+	// the Common Log Format: %h %l %u %t "%r" %>s %b
+	clfPattern = `(?P<remote_addr>\S+) (?P<ident>\S+) (?P<remote_user>\S+) \[(?P<time_local>[^\]]+)\] ` +
+		accessLogQuoted("request") + ` (?P<status>\d{3}) (?P<bytes_sent>\d+|-)`
+	// the Combined Log Format: the Common Log Format followed by "%{Referer}i" "%{User-Agent}i"
+	combinedPattern = clfPattern + ` ` + accessLogQuoted("http_referer") + ` ` + accessLogQuoted("http_user_agent")
+	// the HAProxy syslog header is skipped, e.g. "Feb  6 12:14:14 localhost haproxy[14389]: "
+	haproxyPrefix = `^(?:.*\s)?(?P<remote_addr>\S+):(?P<remote_port>\d+) \[(?P<accept_date>[^\]]+)\] (?P<frontend_name>\S+) (?P<backend_name>[^\s/]+)/(?P<server_name>\S+) `
+	// the HAProxy connection counts and queues: %ac/%fc/%bc/%sc/%rc %sq/%bq
+	haproxyCounts = `(?P<actconn>\d+)/(?P<feconn>\d+)/(?P<beconn>\d+)/(?P<srv_conn>\d+)/\+?(?P<retries>\d+) (?P<srv_queue>\d+)/(?P<backend_queue>\d+)`
+
+	haproxyIntegerColumns = []string{"remote_port", "time_queue", "time_connect", "bytes_sent", "actconn", "feconn", "beconn", "srv_conn", "retries", "srv_queue", "backend_queue"}
+)
+
+// accessLogStyles are the supported access log styles, keyed by name
+var accessLogStyles = map[string]*accessLogStyle{
+	AccessLogStyleApacheCommon: {
+		description:     "Apache Common Log Format",
+		regex:           regexp.MustCompile(`^` + clfPattern + `\s*$`),
+		zeroIfDash:      []string{"bytes_sent"},
+		timestampColumn: "time_local",
+		timestampLayout: clfTimestampLayout,
+		integerColumns:  []string{"status", "bytes_sent"},
+	},
+	AccessLogStyleApacheCombined: {
+		description:     "Apache Combined Log Format",
+		regex:           regexp.MustCompile(`^` + combinedPattern + `\s*$`),
+		zeroIfDash:      []string{"bytes_sent"},
+		timestampColumn: "time_local",
+		timestampLayout: clfTimestampLayout,
+		integerColumns:  []string{"status", "bytes_sent"},
+	},
+	// %v:%p %h %l %u %t "%r" %>s %O "%{Referer}i" "%{User-Agent}i"
+	AccessLogStyleApacheVhostCombined: {
+		description:     "Apache Combined Log Format with the virtual host and port",
+		regex:           regexp.MustCompile(`^(?P<server_name>\S+?):(?P<server_port>\d+) ` + combinedPattern + `\s*$`),
+		zeroIfDash:      []string{"bytes_sent"},
+		timestampColumn: "time_local",
+		timestampLayout: clfTimestampLayout,
+		integerColumns:  []string{"server_port", "status", "bytes_sent"},
+	},
+	// $remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"
+	AccessLogStyleNginxCombined: {
+		description:     "Nginx combined log format",
+		regex:           regexp.MustCompile(`^` + combinedPattern + `\s*$`),
+		timestampColumn: "time_local",
+		timestampLayout: clfTimestampLayout,
+		integerColumns:  []string{"status", "bytes_sent"},
+	},
+	// the main log format of the default nginx.conf - the combined log format followed by "$http_x_forwarded_for"
+	AccessLogStyleNginxMain: {
+		description:     "Nginx main log format (combined with X-Forwarded-For)",
+		regex:           regexp.MustCompile(`^` + combinedPattern + ` ` + accessLogQuoted("http_x_forwarded_for") + `\s*$`),
+		timestampColumn: "time_local",
+		timestampLayout: clfTimestampLayout,
+		integerColumns:  []string{"status", "bytes_sent"},
+	},
+	// %ci:%cp [%tr] %ft %b/%s %TR/%Tw/%Tc/%Tr/%Ta %ST %B %CC %CS %tsc %ac/%fc/%bc/%sc/%rc %sq/%bq %hr %hs %{+Q}r
+	// (a timer is -1 if the stage was not reached)
+	AccessLogStyleHaproxyHttp: {
+		description: "HAProxy HTTP log format (option httplog)",
+		regex: regexp.MustCompile(haproxyPrefix +
+			`(?P<time_request>-?\d+)/(?P<time_queue>-?\d+)/(?P<time_connect>-?\d+)/(?P<time_response>-?\d+)/\+?(?P<request_time_ms>-?\d+) ` +
+			`(?P<status>-?\d+) \+?(?P<bytes_sent>\d+) (?P<captured_request_cookie>\S+) (?P<captured_response_cookie>\S+) (?P<termination_state>\S+) ` +
+			haproxyCounts + `(?: \{(?P<captured_request_headers>[^}]*)\})?(?: \{(?P<captured_response_headers>[^}]*)\})? "(?P<request>[^"]*)"?\s*$`),
+		timestampColumn: "accept_date",
+		// the milliseconds are optional
+		timestampLayout: "02/Jan/2006:15:04:05",
+		integerColumns:  append([]string{"status", "time_request", "time_response"}, haproxyIntegerColumns...),
+		floatColumns:    []string{"request_time_ms"},
+	},
+	// %ci:%cp [%t] %ft %b/%s %Tw/%Tc/%Tt %B %ts %ac/%fc/%bc/%sc/%rc %sq/%bq
+	AccessLogStyleHaproxyTcp: {
+		description: "HAProxy TCP log format (option tcplog)",
+		regex: regexp.MustCompile(haproxyPrefix +
+			`(?P<time_queue>-?\d+)/(?P<time_connect>-?\d+)/\+?(?P<request_time_ms>-?\d+) \+?(?P<bytes_sent>\d+) (?P<termination_state>\S+) ` +
+			haproxyCounts + `\s*$`),
+		timestampColumn: "accept_date",
+		timestampLayout: "02/Jan/2006:15:04:05",
+		integerColumns:  haproxyIntegerColumns,
+		floatColumns:    []string{"request_time_ms"},
+	},
+	// [%START_TIME%] "%REQ(:METHOD)% %REQ(X-ENVOY-ORIGINAL-PATH?:PATH)% %PROTOCOL%" %RESPONSE_CODE% %RESPONSE_FLAGS%
+	// %BYTES_RECEIVED% %BYTES_SENT% %DURATION% %RESP(X-ENVOY-UPSTREAM-SERVICE-TIME)% "%REQ(X-FORWARDED-FOR)%"
+	// "%REQ(USER-AGENT)%" "%REQ(X-REQUEST-ID)%" "%REQ(:AUTHORITY)%" "%UPSTREAM_HOST%"
+	AccessLogStyleEnvoy: {
+		description: "Envoy default access log format",
+		regex: regexp.MustCompile(`^\[(?P<start_time>[^\]]+)\] ` + accessLogQuoted("request") +
+			` (?P<status>\d+) (?P<response_flags>\S+) (?P<bytes_received>\d+) (?P<bytes_sent>\d+) (?P<request_time_ms>\d+) (?P<upstream_service_time>\S+) ` +
+			`"(?P<x_forwarded_for>[^"]*)" ` + accessLogQuoted("http_user_agent") + ` "(?P<request_id>[^"]*)" "(?P<authority>[^"]*)" "(?P<upstream_host>[^"]*)"\s*$`),
+		timestampColumn: "start_time",
+		timestampLayout: time.RFC3339Nano,
+		integerColumns:  []string{"status", "bytes_received", "bytes_sent", "upstream_service_time"},
+		floatColumns:    []string{"request_time_ms"},
+	},
+	AccessLogStyleCaddy: {
+		description:     "Caddy JSON access log",
+		parseFunc:       parseCaddyAccessLog,
+		timestampColumn: "ts",
+		timestampLayout: time.RFC3339Nano,
+		integerColumns:  []string{"status", "bytes_read", "bytes_sent", "remote_port"},
+		floatColumns:    []string{"request_time_ms"},
+	},
+	// <remote_IP_address> - <client_user_name_if_available> [<timestamp>] "<request_method> <request_path> <request_protocol>"
+	// <HTTP_status> <content-size> "<request_referrer>" "<request_user_agent>" <number_of_requests_received_since_Traefik_started>
+	// "<Traefik_router_name>" "<Traefik_server_URL>" <request_duration_in_ms>ms
+	AccessLogStyleTraefik: {
+		description: "Traefik Common Log Format",
+		regex: regexp.MustCompile(`^` + combinedPattern +
+			` (?P<request_count>\d+) "(?P<router_name>[^"]*)" "(?P<server_url>[^"]*)" (?P<request_time_ms>\d+)ms\s*$`),
+		timestampColumn: "time_local",
+		timestampLayout: clfTimestampLayout,
+		integerColumns:  []string{"status", "bytes_sent", "request_count"},
+		floatColumns:    []string{"request_time_ms"},
+	},
+}
+
+func init() {
+	for name, style := range accessLogStyles {
+		style.name = name
+	}
+}
+
+// accessLogStyleNames returns the names of the access log styles, sorted
+func accessLogStyleNames() []string {
+	return slices.Sorted(maps.Keys(accessLogStyles))
+}
+
+// caddyAccessLog is a Caddy JSON access log entry
+type caddyAccessLog struct {
+	Level  string          `json:"level"`
+	Ts     json.RawMessage `json:"ts"`
+	Logger string          `json:"logger"`
+	Msg    string          `json:"msg"`
+	// the request - the client_ip is the remote_ip unless the request is from a trusted proxy
+	Request struct {
+		RemoteIp   string              `json:"remote_ip"`
+		RemotePort string              `json:"remote_port"`
+		ClientIp   string              `json:"client_ip"`
+		Proto      string              `json:"proto"`
+		Method     string              `json:"method"`
+		Host       string              `json:"host"`
+		Uri        string              `json:"uri"`
+		Headers    map[string][]string `json:"headers"`
+	} `json:"request"`
+	BytesRead json.Number     `json:"bytes_read"`
+	UserId    string          `json:"user_id"`
+	Duration  json.RawMessage `json:"duration"`
+	Size      json.Number     `json:"size"`
+	Status    json.Number     `json:"status"`
+}
+
+// parseCaddyAccessLog parses a Caddy JSON access log entry
+// the ts is seconds since the epoch, or a formatted time (if the time_format of the log encoder is set), and the
+// duration is seconds, or a Go duration string (if the duration_format is set to string)
+func parseCaddyAccessLog(line string) (map[string]string, error) {
+	var entry caddyAccessLog
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return nil, fmt.Errorf("invalid caddy access log entry: %w", err)
+	}
+
+	fields := map[string]string{
+		"level":       entry.Level,
+		"logger":      entry.Logger,
+		"msg":         entry.Msg,
+		"remote_addr": entry.Request.ClientIp,
+		"remote_port": entry.Request.RemotePort,
+		"remote_user": entry.UserId,
+		"method":      entry.Request.Method,
+		"host":        entry.Request.Host,
+		"path":        entry.Request.Uri,
+		"protocol":    entry.Request.Proto,
+		"bytes_read":  entry.BytesRead.String(),
+		"bytes_sent":  entry.Size.String(),
+		"status":      entry.Status.String(),
+	}
+	if fields["remote_addr"] == "" {
+		fields["remote_addr"] = entry.Request.RemoteIp
+	}
+	for header, column := range map[string]string{"User-Agent": "http_user_agent", "Referer": "http_referer"} {
+		if values := entry.Request.Headers[header]; len(values) > 0 {
+			fields[column] = values[0]
+		}
+	}
+
+	ts, err := parseCaddyTimestamp(entry.Ts)
+	if err != nil {
+		return nil, err
+	}
+	fields["ts"] = ts.Format(time.RFC3339Nano)
+
+	if len(entry.Duration) > 0 {
+		duration, err := parseCaddyDuration(entry.Duration)
+		if err != nil {
+			return nil, err
+		}
+		fields["request_time_ms"] = strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', -1, 64)
+	}
+
+	// remove the fields which are not in the entry
+	for column, value := range fields {
+		if value == "" {
+			delete(fields, column)
+		}
+	}
+	return fields, nil
+}
+
+// the layouts of the Caddy time formats (other than the unix formats)
+var caddyTimestampLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700", "2006/01/02 15:04:05.000", "2006/01/02 15:04:05"}
+
+func parseCaddyTimestamp(raw json.RawMessage) (time.Time, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		for _, layout := range caddyTimestampLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid caddy access log timestamp")
+	}
+	// seconds since the epoch - parse the fraction separately to avoid the loss of precision of a float
+	seconds, fraction, _ := strings.Cut(string(raw), ".")
+	sec, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid caddy access log timestamp")
+	}
+	var nsec int64
+	if fraction != "" {
+		fraction = (fraction + "000000000")[:9]
+		if nsec, err = strconv.ParseInt(fraction, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("invalid caddy access log timestamp")
+		}
+	}
+	return time.Unix(sec, nsec).UTC(), nil
+}
+
+func parseCaddyDuration(raw json.RawMessage) (time.Duration, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid caddy access log duration")
+		}
+		return duration, nil
+	}
+	seconds, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid caddy access log duration")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,260 @@
+package formats
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogMapper_Map(t *testing.T) {
+	tests := []struct {
+		name          string
+		style         string
+		line          string
+		expected      map[string]string
+		expectedTyped map[string]any
+		expectedErr   string
+	}{
+		{
+			name:  "apache common",
+			style: AccessLogStyleApacheCommon,
+			line:  `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			expected: map[string]string{
+				"remote_addr":  "127.0.0.1",
+				"remote_user":  "frank",
+				"method":       "GET",
+				"path":         "/apache_pb.gif",
+				"protocol":     "HTTP/1.0",
+				"timestamp":    "2000-10-10T13:55:36-07:00",
+				"tp_timestamp": "2000-10-10T20:55:36Z",
+			},
+			expectedTyped: map[string]any{"status": int64(200), "bytes_sent": int64(2326)},
+		},
+		{
+			name:          "apache common without bytes",
+			style:         AccessLogStyleApacheCommon,
+			line:          `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "HEAD / HTTP/1.1" 304 -`,
+			expected:      map[string]string{"method": "HEAD"},
+			expectedTyped: map[string]any{"status": int64(304), "bytes_sent": int64(0)},
+		},
+		{
+			name:  "apache combined with escaped quotes",
+			style: AccessLogStyleApacheCombined,
+			line:  `192.0.2.1 - - [10/Oct/2000:13:55:36 +0000] "GET /search?q=\"x\" HTTP/1.1" 200 512 "http://example.com/" "Mozilla/5.0 (X11; Linux)"`,
+			expected: map[string]string{
+				"path":            `/search?q="x"`,
+				"http_referer":    "http://example.com/",
+				"http_user_agent": "Mozilla/5.0 (X11; Linux)",
+			},
+		},
+		{
+			name:          "apache vhost combined",
+			style:         AccessLogStyleApacheVhostCombined,
+			line:          `www.example.com:443 192.0.2.1 - - [10/Oct/2000:13:55:36 +0000] "GET / HTTP/1.1" 200 1024 "-" "curl/8.0"`,
+			expected:      map[string]string{"server_name": "www.example.com", "http_user_agent": "curl/8.0"},
+			expectedTyped: map[string]any{"server_port": int64(443)},
+		},
+		{
+			name:     "nginx combined with escaped characters",
+			style:    AccessLogStyleNginxCombined,
+			line:     `10.0.0.1 - - [10/Mar/2024:08:00:01 +0100] "GET /a\x22b HTTP/2.0" 404 153 "-" "Go-http-client/2.0"`,
+			expected: map[string]string{"path": `/a"b`, "timestamp": "2024-03-10T08:00:01+01:00", "tp_timestamp": "2024-03-10T07:00:01Z"},
+		},
+		{
+			name:          "nginx main",
+			style:         AccessLogStyleNginxMain,
+			line:          `10.0.0.1 - bob [10/Mar/2024:08:00:01 +0000] "POST /api HTTP/1.1" 201 0 "-" "curl/8.0" "203.0.113.9, 10.0.0.2"`,
+			expected:      map[string]string{"remote_user": "bob", "http_x_forwarded_for": "203.0.113.9, 10.0.0.2"},
+			expectedTyped: map[string]any{"status": int64(201), "bytes_sent": int64(0)},
+		},
+		{
+			name:  "haproxy http",
+			style: AccessLogStyleHaproxyHttp,
+			line:  `Feb  6 12:14:14 localhost haproxy[14389]: 10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {1wt.eu} {} "GET /index.html HTTP/1.1"`,
+			expected: map[string]string{
+				"remote_addr":              "10.0.1.2",
+				"frontend_name":            "http-in",
+				"backend_name":             "static",
+				"server_name":              "srv1",
+				"termination_state":        "----",
+				"captured_request_headers": "1wt.eu",
+				"path":                     "/index.html",
+				"tp_timestamp":             "2009-02-06T12:14:14.655Z",
+			},
+			expectedTyped: map[string]any{
+				"remote_port":     int64(33317),
+				"time_request":    int64(10),
+				"time_connect":    int64(30),
+				"request_time_ms": float64(109),
+				"status":          int64(200),
+				"bytes_sent":      int64(2750),
+			},
+		},
+		{
+			name:          "haproxy http with unreached stages",
+			style:         AccessLogStyleHaproxyHttp,
+			line:          `10.0.1.2:33319 [06/Feb/2009:12:12:51.443] www www/<NOSRV> -1/-1/-1/-1/8002 408 212 - - cR-- 2/2/2/0/0 0/0 "<BADREQ>"`,
+			expected:      map[string]string{"server_name": "<NOSRV>", "request": "<BADREQ>"},
+			expectedTyped: map[string]any{"time_response": int64(-1), "status": int64(408)},
+		},
+		{
+			name:          "haproxy tcp",
+			style:         AccessLogStyleHaproxyTcp,
+			line:          `<134>Feb  6 12:12:56 localhost haproxy[14387]: 10.0.1.2:33313 [06/Feb/2009:12:12:51.443] fnt bck/srv1 0/0/5007 212 -- 0/0/0/0/3 0/0`,
+			expected:      map[string]string{"backend_name": "bck", "termination_state": "--"},
+			expectedTyped: map[string]any{"request_time_ms": float64(5007), "bytes_sent": int64(212), "retries": int64(3)},
+		},
+		{
+			name:  "envoy",
+			style: AccessLogStyleEnvoy,
+			line:  `[2016-04-15T20:17:00.310Z] "POST /api/v1/locations HTTP/2" 204 - 154 0 226 100 "10.0.35.28" "nsq2http" "cc21d9b0-cf5c-432b-8c7e-98aeb7988cd2" "locations" "tcp://10.0.2.1:80"`,
+			expected: map[string]string{
+				"method":          "POST",
+				"x_forwarded_for": "10.0.35.28",
+				"request_id":      "cc21d9b0-cf5c-432b-8c7e-98aeb7988cd2",
+				"authority":       "locations",
+				"upstream_host":   "tcp://10.0.2.1:80",
+				"tp_timestamp":    "2016-04-15T20:17:00.31Z",
+			},
+			expectedTyped: map[string]any{
+				"status":                int64(204),
+				"bytes_received":        int64(154),
+				"bytes_sent":            int64(0),
+				"request_time_ms":       float64(226),
+				"upstream_service_time": int64(100),
+			},
+		},
+		{
+			name:  "caddy",
+			style: AccessLogStyleCaddy,
+			line:  `{"level":"info","ts":1646861401.5241024,"logger":"http.log.access","msg":"handled request","request":{"remote_ip":"127.0.0.1","remote_port":"41342","client_ip":"127.0.0.1","proto":"HTTP/2.0","method":"GET","host":"localhost","uri":"/","headers":{"User-Agent":["curl/7.82.0"]}},"bytes_read":0,"user_id":"","duration":0.000929675,"size":10900,"status":200}`,
+			expected: map[string]string{
+				"remote_addr":     "127.0.0.1",
+				"method":          "GET",
+				"host":            "localhost",
+				"path":            "/",
+				"protocol":        "HTTP/2.0",
+				"http_user_agent": "curl/7.82.0",
+				"tp_timestamp":    "2022-03-09T21:30:01.5241024Z",
+			},
+			expectedTyped: map[string]any{
+				"status":          int64(200),
+				"bytes_sent":      int64(10900),
+				"bytes_read":      int64(0),
+				"remote_port":     int64(41342),
+				"request_time_ms": 0.929675,
+			},
+		},
+		{
+			name:          "caddy with formatted time and duration",
+			style:         AccessLogStyleCaddy,
+			line:          `{"ts":"2022-03-09T21:30:01.524Z","request":{"remote_ip":"10.0.0.1","method":"GET","uri":"/"},"duration":"1.5ms","size":0,"status":404}`,
+			expected:      map[string]string{"remote_addr": "10.0.0.1", "tp_timestamp": "2022-03-09T21:30:01.524Z"},
+			expectedTyped: map[string]any{"request_time_ms": 1.5, "status": int64(404)},
+		},
+		{
+			name:  "traefik",
+			style: AccessLogStyleTraefik,
+			line:  `192.168.1.10 - - [10/Mar/2024:08:00:01 +0000] "GET /whoami HTTP/1.1" 200 715 "-" "curl/8.5.0" 42 "whoami@docker" "http://172.18.0.3:80" 3ms`,
+			expected: map[string]string{
+				"router_name": "whoami@docker",
+				"server_url":  "http://172.18.0.3:80",
+			},
+			expectedTyped: map[string]any{"request_count": int64(42), "request_time_ms": float64(3), "bytes_sent": int64(715)},
+		},
+		{
+			name:        "no match",
+			style:       AccessLogStyleNginxCombined,
+			line:        `this is not an access log line`,
+			expectedErr: "line does not match the nginx_combined access log format",
+		},
+		{
+			name:        "invalid timestamp",
+			style:       AccessLogStyleApacheCommon,
+			line:        `127.0.0.1 - - [yesterday] "GET / HTTP/1.0" 200 1`,
+			expectedErr: "invalid apache_common access log timestamp",
+		},
+		{
+			name:        "invalid caddy entry",
+			style:       AccessLogStyleCaddy,
+			line:        `{"ts":`,
+			expectedErr: "invalid caddy access log entry",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := (&AccessLog{Style: tt.style}).GetMapper()
+			if err != nil {
+				t.Fatalf("GetMapper() error = %v", err)
+			}
+			row, err := mapper.Map(context.Background(), tt.line)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Map() error = %v", err)
+			}
+			fields := make(map[string]string)
+			for key := range tt.expected {
+				if value, ok := row.GetSourceValue(key); ok {
+					fields[key] = value
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.expected) {
+				t.Errorf("expected fields %q, got %q", tt.expected, fields)
+			}
+			typed := make(map[string]any)
+			for key := range tt.expectedTyped {
+				if value, ok := row.OutputColumns[key]; ok {
+					typed[key] = value
+				}
+			}
+			if len(tt.expectedTyped) > 0 && !reflect.DeepEqual(typed, tt.expectedTyped) {
+				t.Errorf("expected typed values %v, got %v", tt.expectedTyped, typed)
+			}
+			if _, ok := row.OutputColumns["timestamp"].(time.Time); !ok {
+				t.Errorf("expected a typed timestamp, got %v", row.OutputColumns["timestamp"])
+			}
+		})
+	}
+}
+
+func TestAccessLog_Timezone(t *testing.T) {
+	mapper, err := (&AccessLog{Style: AccessLogStyleHaproxyTcp, Timezone: stringPtr("Europe/Paris")}).GetMapper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := mapper.Map(context.Background(), `10.0.1.2:33313 [06/Feb/2009:12:12:51] fnt bck/srv1 0/0/5007 212 -- 0/0/0/0/0 0/0`)
+	if err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	if timestamp, _ := row.GetSourceValue("tp_timestamp"); timestamp != "2009-02-06T11:12:51Z" {
+		t.Errorf("expected tp_timestamp 2009-02-06T11:12:51Z, got %s", timestamp)
+	}
+}
+
+func TestAccessLogPresets(t *testing.T) {
+	if len(AccessLogPresets) != len(accessLogStyles) {
+		t.Fatalf("expected a preset for each of the %d styles, got %d", len(accessLogStyles), len(AccessLogPresets))
+	}
+	for _, preset := range AccessLogPresets {
+		if err := preset.Validate(); err != nil {
+			t.Errorf("preset %s: Validate() error = %v", preset.GetName(), err)
+		}
+		if preset.GetDescription() == "" {
+			t.Errorf("preset %s has no description", preset.GetName())
+		}
+		if _, err := preset.GetRegex(); err != nil {
+			t.Errorf("preset %s: GetRegex() error = %v", preset.GetName(), err)
+		}
+	}
+
+	if err := (&AccessLog{Style: "iis"}).Validate(); err == nil || !strings.Contains(err.Error(), "invalid style iis") {
+		t.Errorf("expected invalid style error, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// the artifact modes of the line based formats (grok and regex), which determine how an artifact is split into records
+const (
+	// each line of an artifact is a record (the default) - or, if multiline is set, each group of lines
+	ArtifactModeLine = "line"
+	// each artifact is a single record
+	ArtifactModeFile = "file"
+)
+
+// validateArtifactMode returns an error if the artifact mode is not valid, or is not compatible with multiline
+func validateArtifactMode(mode string, multiline *Multiline) error {
+	switch mode {
+	case ArtifactModeLine:
+		if multiline != nil {
+			return multiline.Validate()
+		}
+		return nil
+	case ArtifactModeFile:
+		if multiline != nil {
+			return fmt.Errorf("multiline can not be used with artifact_mode %s", ArtifactModeFile)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid artifact_mode %s: must be one of %s or %s", mode, ArtifactModeLine, ArtifactModeFile)
+	}
+}
+
+// lineSourceOptions returns the source options for a line based format
+// In line mode each line is a row, unless multiline is set, in which case the multiline loader groups the lines into
+// records. In file mode no options are needed, as by default each artifact is loaded as a single row
+func lineSourceOptions(mode string, multiline *Multiline) []row_source.RowSourceOption {
+	if mode == ArtifactModeFile {
+		return nil
+	}
+	opts := []row_source.RowSourceOption{
+		artifact_source.WithRowPerLine(),
+	}
+	if multiline != nil {
+		opts = append(opts, artifact_source.WithArtifactLoader(NewMultilineLoader(multiline)))
+	}
+	return opts
+}
+
+// textMapper wraps the mapper of a line based format
+// an artifact loaded as a single row is sent as bytes - this is converted to a string, which is what the mapper expects
+type textMapper struct {
+	mappers.Mapper[*types.DynamicRow]
+}
+
+func (m *textMapper) Map(ctx context.Context, a any, opts ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	if data, ok := a.([]byte); ok {
+		a = string(data)
+	}
+	return m.Mapper.Map(ctx, a, opts...)
+}
@@ -0,0 +1,61 @@
+package formats
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateArtifactMode(t *testing.T) {
+	multiline := &Multiline{StartPattern: stringPtr("^start")}
+	tests := []struct {
+		name        string
+		mode        string
+		multiline   *Multiline
+		expectedErr string
+	}{
+		{name: "line", mode: ArtifactModeLine},
+		{name: "line with multiline", mode: ArtifactModeLine, multiline: multiline},
+		{name: "file", mode: ArtifactModeFile},
+		{name: "file with multiline", mode: ArtifactModeFile, multiline: multiline, expectedErr: "multiline can not be used"},
+		{name: "invalid", mode: "record", expectedErr: "invalid artifact_mode record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateArtifactMode(tt.mode, tt.multiline)
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("validateArtifactMode() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestRegex_FileMode(t *testing.T) {
+	mode := ArtifactModeFile
+	regex := &Regex{Layout: `(?s)^request (?P<request_id>\S+)\n(?P<body>.*)$`, ArtifactMode: &mode}
+	if opts := regex.GetSourceOptions(); len(opts) != 0 {
+		t.Errorf("expected no source options in file mode, got %d", len(opts))
+	}
+
+	mapper, err := regex.GetMapper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// in file mode, the artifact is loaded as bytes
+	row, err := mapper.Map(context.Background(), []byte("request abc\nline 1\nline 2"))
+	if err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	if id, _ := row.GetSourceValue("request_id"); id != "abc" {
+		t.Errorf("expected request_id abc, got %q", id)
+	}
+	if body, _ := row.GetSourceValue("body"); body != "line 1\nline 2" {
+		t.Errorf("expected the body to contain the remaining lines, got %q", body)
+	}
+}
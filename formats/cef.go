@@ -0,0 +1,215 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const CefFormatIdentifier = "cef"
+
+// the columns of the CEF header fields
+var cefHeaderColumns = []string{"cef_version", "device_vendor", "device_product", "device_version", "device_event_class_id", "name", "severity"}
+
+// the CEF extension keys which are timestamps - these are converted to RFC 3339
+var cefTimestampKeys = map[string]struct{}{
+	"rt":                      {},
+	"art":                     {},
+	"start":                   {},
+	"end":                     {},
+	"deviceCustomDate1":       {},
+	"deviceCustomDate2":       {},
+	"flexDate1":               {},
+	"fileCreateTime":          {},
+	"fileModificationTime":    {},
+	"oldFileCreateTime":       {},
+	"oldFileModificationTime": {},
+}
+
+// DefaultCef is the default CEF format - this is exported by the core plugin
+var DefaultCef = &Cef{
+	Name:        "default",
+	Description: "ArcSight Common Event Format (CEF)",
+}
+
+// Cef is a format for ArcSight Common Event Format (CEF) events, e.g.
+// `CEF:0|Vendor|Product|1.0|100|Port scan|5|src=10.0.0.1 dst=10.0.0.2 cs1Label=Rule cs1=Block all`
+// The columns are:
+//   - cef_version, device_vendor, device_product, device_version, device_event_class_id, name and severity (the header)
+//   - a column for each extension key, e.g. src and dst - the timestamp keys (rt, start, end etc.) are converted to
+//     RFC 3339 if they are in a known format
+//   - a column for each custom field with a label - the value of cs1 with cs1Label=Rule is in the column rule
+//     (the label is converted to lower case, with any characters other than letters and digits replaced by '_')
+//   - syslog_timestamp, syslog_hostname etc. if the event follows a syslog header
+type Cef struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+	// the time zone of timestamps which do not include one, e.g. "Europe/London" (default "UTC")
+	Timezone *string `hcl:"timezone,optional"`
+}
+
+func NewCef() formats.Format {
+	return &Cef{}
+}
+
+func (c *Cef) Validate() error {
+	_, err := timezoneLocation(c.Timezone)
+	return err
+}
+
+// GetName returns the name of this format instance
+func (c *Cef) GetName() string {
+	return c.Name
+}
+
+// SetName sets the name of this format instance
+func (c *Cef) SetName(name string) {
+	c.Name = name
+}
+
+// GetDescription returns the description of this format instance
+func (c *Cef) GetDescription() string {
+	return c.Description
+}
+
+// GetProperties returns the format properties as a string map - used for introspection
+func (c *Cef) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	if c.Timezone != nil {
+		properties["timezone"] = *c.Timezone
+	}
+	return properties
+}
+
+// Identifier returns the format type identifier
+func (c *Cef) Identifier() string {
+	return CefFormatIdentifier
+}
+
+func (c *Cef) GetRegex() (string, error) {
+	// the CEF format does not support regex
+	return "N/A", nil
+}
+
+func (c *Cef) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	location, err := timezoneLocation(c.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &CefMapper{parser: &securityEventParser{location: location}}, nil
+}
+
+// CefMapper maps a CEF event to a DynamicRow
+type CefMapper struct {
+	parser *securityEventParser
+}
+
+func (m *CefMapper) Identifier() string {
+	return "cef_mapper"
+}
+
+func (m *CefMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var line string
+	switch data := a.(type) {
+	case string:
+		line = data
+	case []byte:
+		line = string(data)
+	default:
+		return nil, fmt.Errorf("expected string, got %T", a)
+	}
+
+	fields, err := m.parser.parseCef(line)
+	if err != nil {
+		return nil, err
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from CEF event: %w", err)
+	}
+	return row, nil
+}
+
+// parseCef parses a CEF event, which may follow a syslog header, into a map of fields
+func (p *securityEventParser) parseCef(line string) (map[string]string, error) {
+	start := strings.Index(line, "CEF:")
+	if start == -1 {
+		return nil, fmt.Errorf("invalid CEF event: missing CEF header")
+	}
+	header, extension, ok := splitSecurityEventHeader(line[start+len("CEF:"):], len(cefHeaderColumns))
+	if !ok {
+		return nil, fmt.Errorf("invalid CEF header: expected %d fields", len(cefHeaderColumns))
+	}
+
+	fields, err := parseCefExtension(strings.TrimRight(extension, "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		if _, ok := cefTimestampKeys[key]; ok {
+			if timestamp, ok := p.parseTimestamp(value); ok {
+				fields[key] = timestamp
+			}
+		}
+	}
+	mapCefCustomLabels(fields)
+
+	for i, column := range cefHeaderColumns {
+		fields[column] = strings.TrimSpace(header[i])
+	}
+	p.parsePrefix(line[:start], fields)
+	return fields, nil
+}
+
+// mapCefCustomLabels replaces each custom field which has a label (e.g. cs1 and cs1Label) with a column named after
+// the label - a custom field is kept if its label is not a valid column name, or the column already exists
+func mapCefCustomLabels(fields map[string]string) {
+	var labelKeys []string
+	for key := range fields {
+		if strings.HasSuffix(key, "Label") {
+			labelKeys = append(labelKeys, key)
+		}
+	}
+	// sort the keys, so the columns are the same for every event if labels are duplicated
+	sort.Strings(labelKeys)
+	for _, labelKey := range labelKeys {
+		key := strings.TrimSuffix(labelKey, "Label")
+		value, ok := fields[key]
+		if !ok {
+			continue
+		}
+		column := normalizeColumnName(fields[labelKey])
+		if _, exists := fields[column]; column == "" || exists {
+			continue
+		}
+		fields[column] = value
+		delete(fields, key)
+		delete(fields, labelKey)
+	}
+}
+
+// normalizeColumnName returns the column name for a CEF custom field label or a W3C field identifier - this is lower
+// case, with each run of characters other than letters and digits replaced by '_', e.g. "Rule Name" -> "rule_name"
+// and "cs(User-Agent)" -> "cs_user_agent"
+func normalizeColumnName(label string) string {
+	var sb strings.Builder
+	separator := false
+	for _, c := range strings.ToLower(label) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			if separator && sb.Len() > 0 {
+				sb.WriteByte('_')
+			}
+			separator = false
+			sb.WriteRune(c)
+			continue
+		}
+		separator = true
+	}
+	return sb.String()
+}
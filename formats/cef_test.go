@@ -0,0 +1,144 @@
+package formats
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCefMapper_Map(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		expected    map[string]string
+		excluded    []string
+		expectedErr string
+	}{
+		{
+			name: "header and extension",
+			line: `CEF:0|Security|threatmanager|1.0|100|worm successfully stopped|10|src=10.0.0.1 dst=2.1.2.2 spt=1232`,
+			expected: map[string]string{
+				"cef_version":           "0",
+				"device_vendor":         "Security",
+				"device_product":        "threatmanager",
+				"device_version":        "1.0",
+				"device_event_class_id": "100",
+				"name":                  "worm successfully stopped",
+				"severity":              "10",
+				"src":                   "10.0.0.1",
+				"dst":                   "2.1.2.2",
+				"spt":                   "1232",
+			},
+		},
+		{
+			name: "escaped header and extension values",
+			line: `CEF:0|security|threat\|manager|1.0|100|detected a \\ in the packet|10|act=blocked a \= sign msg=line one\nline two fname=C:\\temp\\a.txt`,
+			expected: map[string]string{
+				"device_product": "threat|manager",
+				"name":           `detected a \ in the packet`,
+				"act":            "blocked a = sign",
+				"msg":            "line one\nline two",
+				"fname":          `C:\temp\a.txt`,
+			},
+		},
+		{
+			name: "custom labels",
+			line: `CEF:0|Palo Alto Networks|PAN-OS|10.1|end|TRAFFIC|1|cs1Label=Rule cs1=allow web cn1Label=Session ID cn1=4242 cs2=unlabelled cs3Label=Rule cs3=duplicate`,
+			expected: map[string]string{
+				"rule":       "allow web",
+				"session_id": "4242",
+				"cs2":        "unlabelled",
+				"cs3":        "duplicate",
+				"cs3Label":   "Rule",
+			},
+			excluded: []string{"cs1", "cs1Label", "cn1", "cn1Label"},
+		},
+		{
+			name: "timestamps",
+			line: `CEF:0|Vendor|Product|1.0|1|Event|Low|rt=1700000000123 start=Nov 14 2023 22:13:20 end=not a date`,
+			expected: map[string]string{
+				"severity": "Low",
+				"rt":       "2023-11-14T22:13:20.123Z",
+				"start":    "2023-11-14T22:13:20Z",
+				"end":      "not a date",
+			},
+		},
+		{
+			name: "syslog prefix",
+			line: `<134>Feb 14 19:04:54 fw01 CEF:0|Vendor|Product|1.0|1|Event|5|`,
+			expected: map[string]string{
+				"syslog_priority": "134",
+				"syslog_hostname": "fw01",
+				"name":            "Event",
+			},
+		},
+		{
+			name: "no extension",
+			line: `CEF:1|Vendor|Product|1.0|1|Event|5`,
+			expected: map[string]string{
+				"cef_version": "1",
+				"severity":    "5",
+			},
+		},
+		{
+			name:        "not cef",
+			line:        `Feb 14 19:04:54 fw01 sshd[1]: accepted`,
+			expectedErr: "missing CEF header",
+		},
+		{
+			name:        "short header",
+			line:        `CEF:0|Vendor|Product|1.0`,
+			expectedErr: "invalid CEF header",
+		},
+		{
+			name:        "invalid extension",
+			line:        `CEF:0|Vendor|Product|1.0|1|Event|5|no pairs here`,
+			expectedErr: "invalid CEF extension",
+		},
+	}
+	mapper, err := (&Cef{}).GetMapper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := mapper.Map(context.Background(), tt.line)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Map() error = %v", err)
+			}
+			fields := make(map[string]string)
+			for key := range tt.expected {
+				if value, ok := row.GetSourceValue(key); ok {
+					fields[key] = value
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.expected) {
+				t.Errorf("expected fields %q, got %q", tt.expected, fields)
+			}
+			for _, key := range tt.excluded {
+				if _, ok := row.GetSourceValue(key); ok {
+					t.Errorf("expected %s to be excluded", key)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCefExtension(t *testing.T) {
+	fields, err := parseCefExtension(`msg=a=b c  request=http://example.com/?q\=1  `)
+	if err != nil {
+		t.Fatalf("parseCefExtension() error = %v", err)
+	}
+	// an '=' which does not follow a key is part of the value
+	expected := map[string]string{"msg": "a=b c", "request": "http://example.com/?q=1"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected fields %q, got %q", expected, fields)
+	}
+}
@@ -0,0 +1,123 @@
+package formats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const (
+	EvtxFormatIdentifier = "evtx"
+	EvtxLoaderIdentifier = "evtx_loader"
+)
+
+// DefaultEvtx is the default EVTX format - this is exported by the core plugin
+var DefaultEvtx = &Evtx{
+	Name:        "default",
+	Description: "Windows event log (EVTX) format",
+}
+
+// Evtx is a format for Windows event log (EVTX) files
+// Each event record is a row, with the fields of the System element as columns (EventID, Channel, Provider,
+// TimeCreated etc.) and the EventData values as EventData.<Name> columns - see flattenEvent for details
+type Evtx struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+}
+
+func NewEvtx() formats.Format {
+	return &Evtx{}
+}
+
+func (e *Evtx) Validate() error {
+	return nil
+}
+
+// GetName returns the name of this format instance
+func (e *Evtx) GetName() string {
+	return e.Name
+}
+
+// SetName sets the name of this format instance
+func (e *Evtx) SetName(name string) {
+	e.Name = name
+}
+
+// GetDescription returns the description of this format instance
+func (e *Evtx) GetDescription() string {
+	return e.Description
+}
+
+// GetProperties returns the format properties as a string map - used for introspection
+func (e *Evtx) GetProperties() map[string]string {
+	// the EVTX format has no properties
+	return map[string]string{}
+}
+
+// Identifier returns the format type identifier
+func (e *Evtx) Identifier() string {
+	return EvtxFormatIdentifier
+}
+
+func (e *Evtx) GetRegex() (string, error) {
+	// the EVTX format is binary so does not support regex
+	return "N/A", nil
+}
+
+func (e *Evtx) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	return &FieldsMapper{}, nil
+}
+
+// GetSourceOptions returns the options for the source - EVTX files are binary so must be read by the evtx loader
+func (e *Evtx) GetSourceOptions() []row_source.RowSourceOption {
+	return []row_source.RowSourceOption{
+		artifact_source.WithArtifactLoader(&EvtxLoader{}),
+	}
+}
+
+// EvtxLoader is a Loader which reads the event records from an EVTX file
+// each record is sent as a map of flattened fields
+type EvtxLoader struct{}
+
+func (l *EvtxLoader) Identifier() string {
+	return EvtxLoaderIdentifier
+}
+
+// Load implements Loader
+// The file header is validated before returning - corrupt chunks and records are logged and skipped
+func (l *EvtxLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	slog.Debug("EvtxLoader Load", "path", info.LocalName)
+
+	r, err := openEvtxFile(info.LocalName)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer func() {
+			r.Close()
+			close(dataChan)
+		}()
+
+		err := r.records(func(record *evtxRecord) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			dataChan <- &types.RowData{
+				Data: record.fields,
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("EvtxLoader error reading file", "path", info.LocalName, "error", err)
+		}
+		slog.Debug("EvtxLoader Load complete", "path", info.LocalName)
+	}()
+	return nil
+}
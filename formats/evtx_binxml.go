@@ -0,0 +1,684 @@
+package formats
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// BinXML tokens
+// the more data flag indicates an element has attributes, or an attribute is followed by further attributes
+const (
+	binXmlTokenEOF                  = 0x00
+	binXmlTokenOpenStartElement     = 0x01
+	binXmlTokenCloseStartElement    = 0x02
+	binXmlTokenCloseEmptyElement    = 0x03
+	binXmlTokenEndElement           = 0x04
+	binXmlTokenValue                = 0x05
+	binXmlTokenAttribute            = 0x06
+	binXmlTokenCDataSection         = 0x07
+	binXmlTokenCharRef              = 0x08
+	binXmlTokenEntityRef            = 0x09
+	binXmlTokenPITarget             = 0x0a
+	binXmlTokenPIData               = 0x0b
+	binXmlTokenTemplateInstance     = 0x0c
+	binXmlTokenNormalSubstitution   = 0x0d
+	binXmlTokenOptionalSubstitution = 0x0e
+	binXmlTokenFragmentHeader       = 0x0f
+
+	binXmlTokenMoreDataFlag = 0x40
+)
+
+// BinXML value types
+const (
+	evtxTypeNull       = 0x00
+	evtxTypeString     = 0x01
+	evtxTypeAnsiString = 0x02
+	evtxTypeInt8       = 0x03
+	evtxTypeUInt8      = 0x04
+	evtxTypeInt16      = 0x05
+	evtxTypeUInt16     = 0x06
+	evtxTypeInt32      = 0x07
+	evtxTypeUInt32     = 0x08
+	evtxTypeInt64      = 0x09
+	evtxTypeUInt64     = 0x0a
+	evtxTypeReal32     = 0x0b
+	evtxTypeReal64     = 0x0c
+	evtxTypeBool       = 0x0d
+	evtxTypeBinary     = 0x0e
+	evtxTypeGuid       = 0x0f
+	evtxTypeSizeT      = 0x10
+	evtxTypeFileTime   = 0x11
+	evtxTypeSysTime    = 0x12
+	evtxTypeSid        = 0x13
+	evtxTypeHexInt32   = 0x14
+	evtxTypeHexInt64   = 0x15
+	evtxTypeBinXml     = 0x21
+
+	evtxTypeArrayFlag = 0x80
+)
+
+const (
+	// the size of a template definition header - the next definition offset, the template guid and the data size
+	binXmlTemplateHeaderSize = 24
+	// the maximum depth of nested templates and BinXML values - this protects against corrupt offsets
+	maxBinXmlDepth = 32
+)
+
+// the format used for timestamps - this is the format used by the Windows event viewer
+const evtxTimeFormat = "2006-01-02T15:04:05.0000000Z"
+
+// xmlNode is a node of a parsed BinXML fragment - one of
+// *xmlElement, xmlText, xmlSubstitution or *xmlTemplateInstance
+// (once rendered, only *xmlElement and xmlText nodes remain)
+type xmlNode any
+
+type xmlElement struct {
+	name     string
+	attrs    []xmlAttr
+	children []xmlNode
+}
+
+type xmlAttr struct {
+	name  string
+	value []xmlNode
+}
+
+type xmlText string
+
+// xmlSubstitution is a placeholder in a template for the value with the given index
+type xmlSubstitution struct {
+	index    int
+	optional bool
+}
+
+type xmlTemplateInstance struct {
+	nodes  []xmlNode
+	values []binXmlValue
+}
+
+type binXmlValue struct {
+	valueType uint8
+	// the offset of the value data in the chunk - BinXML values contain offsets relative to the chunk
+	offset int
+	data   []byte
+}
+
+type binXmlTemplate struct {
+	nodes []xmlNode
+	// the size of the definition, including the header
+	size int
+}
+
+// binXmlParser parses the BinXML events of a chunk
+// all offsets in BinXML are relative to the start of the chunk, and names and template definitions are
+// stored once per chunk, so the parsed names and templates are cached by offset
+type binXmlParser struct {
+	chunk     []byte
+	names     map[uint32]binXmlName
+	templates map[uint32]*binXmlTemplate
+}
+
+type binXmlName struct {
+	name string
+	size int
+}
+
+func newBinXmlParser(chunk []byte) *binXmlParser {
+	return &binXmlParser{
+		chunk:     chunk,
+		names:     make(map[uint32]binXmlName),
+		templates: make(map[uint32]*binXmlTemplate),
+	}
+}
+
+// parseEvent parses and renders the BinXML event between the given chunk offsets, returning the Event element
+func (p *binXmlParser) parseEvent(start, end int) (*xmlElement, error) {
+	nodes, _, err := p.parseFragment(start, end, 0)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := p.render(nodes, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range rendered {
+		if el, ok := node.(*xmlElement); ok {
+			return el, nil
+		}
+	}
+	return nil, fmt.Errorf("no event element at offset %d", start)
+}
+
+// parseFragment parses the BinXML tokens from start until the end of fragment token, or the end offset
+// it returns the parsed nodes and the offset after the last token
+func (p *binXmlParser) parseFragment(start, end, depth int) ([]xmlNode, int, error) {
+	if depth > maxBinXmlDepth {
+		return nil, 0, fmt.Errorf("BinXML nested too deeply at offset %d", start)
+	}
+	le := binary.LittleEndian
+
+	var root []xmlNode
+	var stack []*xmlElement
+	// the attribute which values are currently being added to
+	var attr *xmlAttr
+	add := func(node xmlNode) {
+		switch {
+		case attr != nil:
+			attr.value = append(attr.value, node)
+		case len(stack) > 0:
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+		default:
+			root = append(root, node)
+		}
+	}
+
+	pos := start
+	for pos < end {
+		token := p.chunk[pos]
+		switch token &^ binXmlTokenMoreDataFlag {
+		case binXmlTokenEOF:
+			return root, pos + 1, nil
+
+		case binXmlTokenFragmentHeader:
+			// the header contains the major and minor version and flags
+			if err := p.need(pos, 4, end); err != nil {
+				return nil, 0, err
+			}
+			pos += 4
+
+		case binXmlTokenOpenStartElement:
+			// token, dependency id (2), data size (4), name offset (4), [attribute list size (4)]
+			if err := p.need(pos, 11, end); err != nil {
+				return nil, 0, err
+			}
+			name, next, err := p.readNameRef(pos+7, end)
+			if err != nil {
+				return nil, 0, err
+			}
+			if token&binXmlTokenMoreDataFlag != 0 {
+				if err := p.need(next, 4, end); err != nil {
+					return nil, 0, err
+				}
+				next += 4
+			}
+			el := &xmlElement{name: name}
+			attr = nil
+			add(el)
+			stack = append(stack, el)
+			pos = next
+
+		case binXmlTokenCloseStartElement:
+			attr = nil
+			pos++
+
+		case binXmlTokenCloseEmptyElement, binXmlTokenEndElement:
+			if len(stack) == 0 {
+				return nil, 0, fmt.Errorf("unexpected end of element at offset %d", pos)
+			}
+			attr = nil
+			stack = stack[:len(stack)-1]
+			pos++
+
+		case binXmlTokenValue:
+			// token, value type (1), string
+			if err := p.need(pos, 4, end); err != nil {
+				return nil, 0, err
+			}
+			text, next, err := p.readString(pos+2, end)
+			if err != nil {
+				return nil, 0, err
+			}
+			add(xmlText(text))
+			pos = next
+
+		case binXmlTokenAttribute:
+			if len(stack) == 0 {
+				return nil, 0, fmt.Errorf("unexpected attribute at offset %d", pos)
+			}
+			name, next, err := p.readNameRef(pos+1, end)
+			if err != nil {
+				return nil, 0, err
+			}
+			el := stack[len(stack)-1]
+			el.attrs = append(el.attrs, xmlAttr{name: name})
+			attr = &el.attrs[len(el.attrs)-1]
+			pos = next
+
+		case binXmlTokenCDataSection:
+			text, next, err := p.readString(pos+1, end)
+			if err != nil {
+				return nil, 0, err
+			}
+			add(xmlText(text))
+			pos = next
+
+		case binXmlTokenCharRef:
+			if err := p.need(pos, 3, end); err != nil {
+				return nil, 0, err
+			}
+			add(xmlText(rune(le.Uint16(p.chunk[pos+1:]))))
+			pos += 3
+
+		case binXmlTokenEntityRef:
+			name, next, err := p.readNameRef(pos+1, end)
+			if err != nil {
+				return nil, 0, err
+			}
+			add(xmlText(resolveEntity(name)))
+			pos = next
+
+		case binXmlTokenPITarget:
+			// processing instructions are ignored
+			_, next, err := p.readNameRef(pos+1, end)
+			if err != nil {
+				return nil, 0, err
+			}
+			pos = next
+
+		case binXmlTokenPIData:
+			_, next, err := p.readString(pos+1, end)
+			if err != nil {
+				return nil, 0, err
+			}
+			pos = next
+
+		case binXmlTokenTemplateInstance:
+			instance, next, err := p.parseTemplateInstance(pos, end, depth)
+			if err != nil {
+				return nil, 0, err
+			}
+			add(instance)
+			pos = next
+
+		case binXmlTokenNormalSubstitution, binXmlTokenOptionalSubstitution:
+			// token, value index (2), value type (1)
+			if err := p.need(pos, 4, end); err != nil {
+				return nil, 0, err
+			}
+			add(xmlSubstitution{
+				index:    int(le.Uint16(p.chunk[pos+1:])),
+				optional: token == binXmlTokenOptionalSubstitution,
+			})
+			pos += 4
+
+		default:
+			return nil, 0, fmt.Errorf("unknown BinXML token %#x at offset %d", token, pos)
+		}
+	}
+	return root, pos, nil
+}
+
+// parseTemplateInstance parses the template instance token at pos, which references the template definition and
+// is followed by the template values
+func (p *binXmlParser) parseTemplateInstance(pos, end, depth int) (*xmlTemplateInstance, int, error) {
+	le := binary.LittleEndian
+	// token, unknown (1), template id (4), template definition offset (4)
+	if err := p.need(pos, 10, end); err != nil {
+		return nil, 0, err
+	}
+	definitionOffset := le.Uint32(p.chunk[pos+6:])
+	template, err := p.readTemplate(definitionOffset, depth)
+	if err != nil {
+		return nil, 0, err
+	}
+	next := pos + 10
+	// the definition is stored inline the first time the template is used in a chunk
+	if int(definitionOffset) == next {
+		next += template.size
+	}
+
+	// the number of values, followed by the size and type of each value, followed by the value data
+	if err := p.need(next, 4, end); err != nil {
+		return nil, 0, err
+	}
+	count := int(le.Uint32(p.chunk[next:]))
+	next += 4
+	if err := p.need(next, 4*count, end); err != nil {
+		return nil, 0, err
+	}
+	values := make([]binXmlValue, count)
+	dataPos := next + 4*count
+	for i := range values {
+		descriptor := p.chunk[next+4*i:]
+		size := int(le.Uint16(descriptor))
+		if err := p.need(dataPos, size, end); err != nil {
+			return nil, 0, err
+		}
+		values[i] = binXmlValue{
+			valueType: descriptor[2],
+			offset:    dataPos,
+			data:      p.chunk[dataPos : dataPos+size],
+		}
+		dataPos += size
+	}
+	return &xmlTemplateInstance{nodes: template.nodes, values: values}, dataPos, nil
+}
+
+// readTemplate reads the template definition at the given chunk offset
+func (p *binXmlParser) readTemplate(offset uint32, depth int) (*binXmlTemplate, error) {
+	if template, ok := p.templates[offset]; ok {
+		return template, nil
+	}
+
+	// next definition offset (4), template guid (16), data size (4), data
+	pos := int(offset)
+	if err := p.need(pos, binXmlTemplateHeaderSize, len(p.chunk)); err != nil {
+		return nil, err
+	}
+	size := int(binary.LittleEndian.Uint32(p.chunk[pos+20:]))
+	start := pos + binXmlTemplateHeaderSize
+	if err := p.need(start, size, len(p.chunk)); err != nil {
+		return nil, err
+	}
+	nodes, _, err := p.parseFragment(start, start+size, depth+1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template definition at offset %d: %w", offset, err)
+	}
+
+	template := &binXmlTemplate{nodes: nodes, size: binXmlTemplateHeaderSize + size}
+	p.templates[offset] = template
+	return template, nil
+}
+
+// readNameRef reads the name offset at pos, returning the name and the offset after the reference
+// names are stored inline the first time they are used in a chunk - later uses reference the earlier copy
+func (p *binXmlParser) readNameRef(pos, end int) (string, int, error) {
+	if err := p.need(pos, 4, end); err != nil {
+		return "", 0, err
+	}
+	offset := binary.LittleEndian.Uint32(p.chunk[pos:])
+	name, err := p.readName(offset)
+	if err != nil {
+		return "", 0, err
+	}
+	next := pos + 4
+	if int(offset) == next {
+		next += name.size
+	}
+	return name.name, next, nil
+}
+
+// readName reads the name at the given chunk offset
+func (p *binXmlParser) readName(offset uint32) (binXmlName, error) {
+	if name, ok := p.names[offset]; ok {
+		return name, nil
+	}
+
+	// next name offset (4), hash (2), string, null terminator (2)
+	pos := int(offset)
+	if err := p.need(pos, 4, len(p.chunk)); err != nil {
+		return binXmlName{}, err
+	}
+	text, next, err := p.readString(pos+6, len(p.chunk))
+	if err != nil {
+		return binXmlName{}, err
+	}
+	name := binXmlName{name: text, size: next + 2 - pos}
+	p.names[offset] = name
+	return name, nil
+}
+
+// readString reads the string at pos, which is prefixed with the number of UTF-16 characters
+func (p *binXmlParser) readString(pos, end int) (string, int, error) {
+	if err := p.need(pos, 2, end); err != nil {
+		return "", 0, err
+	}
+	size := 2 * int(binary.LittleEndian.Uint16(p.chunk[pos:]))
+	if err := p.need(pos+2, size, end); err != nil {
+		return "", 0, err
+	}
+	return decodeUtf16(p.chunk[pos+2 : pos+2+size]), pos + 2 + size, nil
+}
+
+func (p *binXmlParser) need(pos, size, end int) error {
+	if pos < 0 || size < 0 || pos+size > end {
+		return fmt.Errorf("BinXML truncated at offset %d", pos)
+	}
+	return nil
+}
+
+// render substitutes the template values into the nodes, returning the nodes with only elements and text
+func (p *binXmlParser) render(nodes []xmlNode, values []binXmlValue, depth int) ([]xmlNode, error) {
+	if depth > maxBinXmlDepth {
+		return nil, fmt.Errorf("BinXML nested too deeply")
+	}
+
+	var res []xmlNode
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case xmlText:
+			res = append(res, n)
+
+		case *xmlElement:
+			// an element which only contains an optional substitution without a value is omitted
+			if isEmptyOptional(n.children, values) {
+				continue
+			}
+			el := &xmlElement{name: n.name}
+			for _, a := range n.attrs {
+				if isEmptyOptional(a.value, values) {
+					continue
+				}
+				value, err := p.render(a.value, values, depth)
+				if err != nil {
+					return nil, err
+				}
+				el.attrs = append(el.attrs, xmlAttr{name: a.name, value: value})
+			}
+			children, err := p.render(n.children, values, depth)
+			if err != nil {
+				return nil, err
+			}
+			el.children = children
+			res = append(res, el)
+
+		case xmlSubstitution:
+			// a missing value is treated as null
+			if n.index >= len(values) {
+				continue
+			}
+			value := values[n.index]
+			// BinXML values are fragments, which usually contain a template instance
+			if value.valueType == evtxTypeBinXml {
+				nested, _, err := p.parseFragment(value.offset, value.offset+len(value.data), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				rendered, err := p.render(nested, nil, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, rendered...)
+				continue
+			}
+			if text, ok := renderValue(value); ok {
+				res = append(res, xmlText(text))
+			}
+
+		case *xmlTemplateInstance:
+			rendered, err := p.render(n.nodes, n.values, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, rendered...)
+		}
+	}
+	return res, nil
+}
+
+// isEmptyOptional returns whether the nodes are a single optional substitution which has no value
+func isEmptyOptional(nodes []xmlNode, values []binXmlValue) bool {
+	if len(nodes) != 1 {
+		return false
+	}
+	s, ok := nodes[0].(xmlSubstitution)
+	if !ok || !s.optional {
+		return false
+	}
+	return s.index >= len(values) || values[s.index].valueType == evtxTypeNull
+}
+
+// renderValue returns the value as a string, in the same form as the Windows event viewer
+// returns false if the value is null
+func renderValue(v binXmlValue) (string, bool) {
+	if v.valueType == evtxTypeNull {
+		return "", false
+	}
+	if v.valueType&evtxTypeArrayFlag == 0 {
+		return renderScalar(v.valueType, v.data), true
+	}
+
+	// arrays of strings are null terminated, other arrays are of fixed size values
+	valueType := v.valueType &^ evtxTypeArrayFlag
+	var items []string
+	switch valueType {
+	case evtxTypeString:
+		items = strings.Split(strings.TrimSuffix(decodeUtf16(v.data), "\x00"), "\x00")
+	case evtxTypeAnsiString:
+		items = strings.Split(strings.TrimSuffix(string(v.data), "\x00"), "\x00")
+	default:
+		size := evtxValueSize(valueType)
+		if size == 0 {
+			return strings.ToUpper(hex.EncodeToString(v.data)), true
+		}
+		for i := 0; i+size <= len(v.data); i += size {
+			items = append(items, renderScalar(valueType, v.data[i:i+size]))
+		}
+	}
+	return strings.Join(items, ", "), true
+}
+
+// evtxValueSize returns the size of values of the given type, or 0 if the type is not of fixed size
+func evtxValueSize(valueType uint8) int {
+	switch valueType {
+	case evtxTypeInt8, evtxTypeUInt8:
+		return 1
+	case evtxTypeInt16, evtxTypeUInt16:
+		return 2
+	case evtxTypeInt32, evtxTypeUInt32, evtxTypeReal32, evtxTypeBool, evtxTypeHexInt32:
+		return 4
+	case evtxTypeInt64, evtxTypeUInt64, evtxTypeReal64, evtxTypeFileTime, evtxTypeHexInt64, evtxTypeSizeT:
+		return 8
+	case evtxTypeGuid, evtxTypeSysTime:
+		return 16
+	}
+	return 0
+}
+
+// renderScalar returns the value as a string - values which cannot be decoded are rendered as hex
+func renderScalar(valueType uint8, data []byte) string {
+	le := binary.LittleEndian
+	if size := evtxValueSize(valueType); size > len(data) && !(valueType == evtxTypeSizeT && len(data) == 4) {
+		return strings.ToUpper(hex.EncodeToString(data))
+	}
+
+	switch valueType {
+	case evtxTypeString:
+		return strings.TrimRight(decodeUtf16(data), "\x00")
+	case evtxTypeAnsiString:
+		return strings.TrimRight(string(data), "\x00")
+	case evtxTypeInt8:
+		return strconv.FormatInt(int64(int8(data[0])), 10)
+	case evtxTypeUInt8:
+		return strconv.FormatUint(uint64(data[0]), 10)
+	case evtxTypeInt16:
+		return strconv.FormatInt(int64(int16(le.Uint16(data))), 10)
+	case evtxTypeUInt16:
+		return strconv.FormatUint(uint64(le.Uint16(data)), 10)
+	case evtxTypeInt32:
+		return strconv.FormatInt(int64(int32(le.Uint32(data))), 10)
+	case evtxTypeUInt32:
+		return strconv.FormatUint(uint64(le.Uint32(data)), 10)
+	case evtxTypeInt64:
+		return strconv.FormatInt(int64(le.Uint64(data)), 10) //nolint:gosec // signed value
+	case evtxTypeUInt64:
+		return strconv.FormatUint(le.Uint64(data), 10)
+	case evtxTypeReal32:
+		return strconv.FormatFloat(float64(math.Float32frombits(le.Uint32(data))), 'g', -1, 32)
+	case evtxTypeReal64:
+		return strconv.FormatFloat(math.Float64frombits(le.Uint64(data)), 'g', -1, 64)
+	case evtxTypeBool:
+		return strconv.FormatBool(le.Uint32(data) != 0)
+	case evtxTypeGuid:
+		return fmt.Sprintf("{%08X-%04X-%04X-%X-%X}", le.Uint32(data), le.Uint16(data[4:]), le.Uint16(data[6:]), data[8:10], data[10:16])
+	case evtxTypeSizeT:
+		if len(data) == 4 {
+			return fmt.Sprintf("0x%x", le.Uint32(data))
+		}
+		return fmt.Sprintf("0x%x", le.Uint64(data))
+	case evtxTypeFileTime:
+		return fileTimeToTime(le.Uint64(data)).Format(evtxTimeFormat)
+	case evtxTypeSysTime:
+		// year, month, day of week, day, hour, minute, second, milliseconds
+		t := time.Date(int(le.Uint16(data)), time.Month(le.Uint16(data[2:])), int(le.Uint16(data[6:])),
+			int(le.Uint16(data[8:])), int(le.Uint16(data[10:])), int(le.Uint16(data[12:])),
+			int(le.Uint16(data[14:]))*int(time.Millisecond), time.UTC)
+		return t.Format(evtxTimeFormat)
+	case evtxTypeSid:
+		if sid, ok := formatSid(data); ok {
+			return sid
+		}
+	case evtxTypeHexInt32:
+		return fmt.Sprintf("0x%x", le.Uint32(data))
+	case evtxTypeHexInt64:
+		return fmt.Sprintf("0x%x", le.Uint64(data))
+	}
+	return strings.ToUpper(hex.EncodeToString(data))
+}
+
+// fileTimeToTime converts a FILETIME (the number of 100ns intervals since 1601-01-01) to a time
+func fileTimeToTime(fileTime uint64) time.Time {
+	// the number of 100ns intervals between 1601-01-01 and 1970-01-01
+	const unixEpoch = 116444736000000000
+	intervals := int64(fileTime - unixEpoch) //nolint:gosec // times before 1970 are negative
+	return time.Unix(intervals/1e7, (intervals%1e7)*100).UTC()
+}
+
+// formatSid returns the SID in its string form, e.g. S-1-5-18
+func formatSid(data []byte) (string, bool) {
+	// revision (1), number of sub authorities (1), authority (6 - big endian), sub authorities (4 each)
+	if len(data) < 8 || len(data) < 8+4*int(data[1]) {
+		return "", false
+	}
+	var authority uint64
+	for _, b := range data[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "S-%d-%d", data[0], authority)
+	for i := 0; i < int(data[1]); i++ {
+		fmt.Fprintf(&sb, "-%d", binary.LittleEndian.Uint32(data[8+4*i:]))
+	}
+	return sb.String(), true
+}
+
+func decodeUtf16(data []byte) string {
+	chars := make([]uint16, len(data)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+func resolveEntity(name string) string {
+	switch name {
+	case "amp":
+		return "&"
+	case "lt":
+		return "<"
+	case "gt":
+		return ">"
+	case "quot":
+		return "\""
+	case "apos":
+		return "'"
+	}
+	return "&" + name + ";"
+}
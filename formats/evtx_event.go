@@ -0,0 +1,148 @@
+package formats
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the field names for the attributes of the elements of the System element
+// other attributes are named <Element>.<Attribute>
+var evtxSystemAttributeFields = map[string]string{
+	"Provider.Name":                 "Provider",
+	"Provider.Guid":                 "ProviderGuid",
+	"Provider.EventSourceName":      "EventSourceName",
+	"EventID.Qualifiers":            "Qualifiers",
+	"TimeCreated.SystemTime":        "TimeCreated",
+	"Correlation.ActivityID":        "ActivityID",
+	"Correlation.RelatedActivityID": "RelatedActivityID",
+	"Execution.ProcessID":           "ProcessID",
+	"Execution.ThreadID":            "ThreadID",
+	"Security.UserID":               "UserID",
+}
+
+// flattenEvent flattens the rendered Event element to a map of fields:
+// - the text of each element of the System element is a field named after the element, e.g. EventID, Channel, Computer
+// - the attributes of the System elements are fields named <Element>.<Attribute>, except for the common attributes
+// which have their own names, e.g. Provider, TimeCreated, ProcessID (see evtxSystemAttributeFields)
+// - each EventData value is a field named EventData.<Name>, or EventData.Data[<index>] for unnamed values
+// - other elements (e.g. UserData) are flattened to fields named by their path, e.g. UserData.LogFileCleared.SubjectUserName
+// if a field name occurs more than once, the later fields have an index suffix, e.g. UserData.Item.Value[1]
+func flattenEvent(event *xmlElement) map[string]string {
+	fields := make(map[string]string)
+	for _, section := range event.elements() {
+		switch section.name {
+		case "System":
+			for _, el := range section.elements() {
+				if text, ok := el.text(); ok {
+					setField(fields, el.name, text)
+				}
+				for _, a := range el.attrs {
+					if isNamespace(a.name) {
+						continue
+					}
+					name := el.name + "." + a.name
+					if field, ok := evtxSystemAttributeFields[name]; ok {
+						name = field
+					}
+					setField(fields, name, a.text())
+				}
+			}
+		case "EventData":
+			var unnamed int
+			for _, el := range section.elements() {
+				if el.name != "Data" {
+					flattenElement(fields, "EventData."+el.name, el)
+					continue
+				}
+				text, _ := el.text()
+				if name, ok := el.attr("Name"); ok {
+					setField(fields, "EventData."+name, text)
+				} else {
+					setField(fields, fmt.Sprintf("EventData.Data[%d]", unnamed), text)
+					unnamed++
+				}
+			}
+		default:
+			flattenElement(fields, section.name, section)
+		}
+	}
+	return fields
+}
+
+// flattenElement adds the text, attributes and child elements of the element as fields prefixed with the given path
+func flattenElement(fields map[string]string, path string, el *xmlElement) {
+	if text, ok := el.text(); ok {
+		setField(fields, path, text)
+	}
+	for _, a := range el.attrs {
+		if !isNamespace(a.name) {
+			setField(fields, path+"."+a.name, a.text())
+		}
+	}
+	for _, child := range el.elements() {
+		flattenElement(fields, path+"."+child.name, child)
+	}
+}
+
+// setField sets the field, adding an index suffix to the name if the field is already set
+func setField(fields map[string]string, name, value string) {
+	if _, exists := fields[name]; !exists {
+		fields[name] = value
+		return
+	}
+	for i := 1; ; i++ {
+		indexed := fmt.Sprintf("%s[%d]", name, i)
+		if _, exists := fields[indexed]; !exists {
+			fields[indexed] = value
+			return
+		}
+	}
+}
+
+// isNamespace returns whether the attribute is a namespace declaration
+func isNamespace(name string) bool {
+	return name == "xmlns" || strings.HasPrefix(name, "xmlns:")
+}
+
+// elements returns the child elements of the element
+func (e *xmlElement) elements() []*xmlElement {
+	var res []*xmlElement
+	for _, child := range e.children {
+		if el, ok := child.(*xmlElement); ok {
+			res = append(res, el)
+		}
+	}
+	return res
+}
+
+// text returns the text content of the element (excluding child elements), and whether it has any text
+func (e *xmlElement) text() (string, bool) {
+	return nodesText(e.children)
+}
+
+// attr returns the value of the named attribute
+func (e *xmlElement) attr(name string) (string, bool) {
+	for _, a := range e.attrs {
+		if a.name == name {
+			return a.text(), true
+		}
+	}
+	return "", false
+}
+
+func (a xmlAttr) text() string {
+	text, _ := nodesText(a.value)
+	return text
+}
+
+func nodesText(nodes []xmlNode) (string, bool) {
+	var sb strings.Builder
+	var ok bool
+	for _, node := range nodes {
+		if text, isText := node.(xmlText); isText {
+			sb.WriteString(string(text))
+			ok = true
+		}
+	}
+	return sb.String(), ok
+}
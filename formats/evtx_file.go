@@ -0,0 +1,146 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// the EVTX file format is documented at
+// https://github.com/libyal/libevtx/blob/main/documentation/Windows%20XML%20Event%20Log%20(EVTX).asciidoc
+// all integers are little endian
+
+var (
+	evtxFileSignature   = []byte("ElfFile\x00")
+	evtxChunkSignature  = []byte("ElfChnk\x00")
+	evtxRecordSignature = []byte("**\x00\x00")
+)
+
+const (
+	// the file header is padded to a 4KiB block, which is followed by 64KiB chunks
+	evtxFileHeaderBlockSize = 4096
+	evtxChunkSize           = 64 * 1024
+	// the chunk header (including the string and template offset tables) is followed by the event records
+	evtxChunkHeaderSize = 512
+	// the record header is followed by the BinXML event, and the record ends with a copy of the size
+	evtxRecordHeaderSize = 24
+	evtxMinRecordSize    = evtxRecordHeaderSize + 4
+)
+
+// evtxFile reads the event records from an EVTX file
+type evtxFile struct {
+	path string
+	f    *os.File
+	size int64
+}
+
+// evtxRecord is a single event record read from an EVTX file
+type evtxRecord struct {
+	id uint64
+	// the event, flattened to a map of fields
+	fields map[string]string
+}
+
+func openEvtxFile(path string) (*evtxFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &evtxFile{
+		path: path,
+		f:    f,
+	}
+	if err := e.readHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *evtxFile) Close() error {
+	return e.f.Close()
+}
+
+func (e *evtxFile) readHeader() error {
+	info, err := e.f.Stat()
+	if err != nil {
+		return err
+	}
+	e.size = info.Size()
+
+	buf := make([]byte, len(evtxFileSignature))
+	if _, err := e.f.ReadAt(buf, 0); err != nil || !bytes.Equal(buf, evtxFileSignature) {
+		return fmt.Errorf("%s: not an EVTX file", filepath.Base(e.path))
+	}
+	return nil
+}
+
+// records calls fn for each event record in the file, in order
+// the number of chunks in the file header is not updated until the file is closed by the event log service,
+// so all complete chunks are read - chunks which are unused or corrupt are skipped
+func (e *evtxFile) records(fn func(record *evtxRecord) error) error {
+	buf := make([]byte, evtxChunkSize)
+	for offset := int64(evtxFileHeaderBlockSize); offset+evtxChunkSize <= e.size; offset += evtxChunkSize {
+		if _, err := e.f.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return fmt.Errorf("%s: unable to read chunk at offset %d: %w", filepath.Base(e.path), offset, err)
+		}
+		if !bytes.Equal(buf[:len(evtxChunkSignature)], evtxChunkSignature) {
+			continue
+		}
+		if err := e.chunkRecords(buf, offset, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkRecords calls fn for each event record in the chunk
+// errors parsing a record are logged and the record is skipped - only errors returned by fn are returned
+func (e *evtxFile) chunkRecords(chunk []byte, chunkOffset int64, fn func(record *evtxRecord) error) error {
+	le := binary.LittleEndian
+	// records are written up to the free space offset
+	end := int(le.Uint32(chunk[48:]))
+	if end > len(chunk) {
+		end = len(chunk)
+	}
+
+	// templates are defined once per chunk, so each chunk has its own parser
+	p := newBinXmlParser(chunk)
+	for pos := evtxChunkHeaderSize; pos+evtxMinRecordSize <= end; {
+		if !bytes.Equal(chunk[pos:pos+len(evtxRecordSignature)], evtxRecordSignature) {
+			slog.Warn("EvtxLoader invalid record signature", "path", e.path, "offset", chunkOffset+int64(pos))
+			return nil
+		}
+		size := int(le.Uint32(chunk[pos+4:]))
+		if size < evtxMinRecordSize || pos+size > end {
+			slog.Warn("EvtxLoader invalid record size", "path", e.path, "offset", chunkOffset+int64(pos), "size", size)
+			return nil
+		}
+		id := le.Uint64(chunk[pos+8:])
+
+		event, err := p.parseEvent(pos+evtxRecordHeaderSize, pos+size-4)
+		if err != nil {
+			slog.Warn("EvtxLoader error parsing record", "path", e.path, "record", id, "error", err)
+		} else {
+			fields := flattenEvent(event)
+			// the record id is always present in the record header
+			if _, ok := fields["EventRecordID"]; !ok {
+				fields["EventRecordID"] = strconv.FormatUint(id, 10)
+			}
+			record := &evtxRecord{
+				id:     id,
+				fields: fields,
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		pos += size
+	}
+	return nil
+}
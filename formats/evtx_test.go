@@ -0,0 +1,319 @@
+package formats
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+var updateTestData = flag.Bool("update", false, "regenerate the sample files in test_data")
+
+const eventNamespace = "http://schemas.microsoft.com/win/2004/08/events/event"
+
+var (
+	// a logon event, with a named EventData value for each substitution - the last three values are optional
+	logonTemplate = &testTemplate{id: 0x1001, root: &testElement{
+		name:  "Event",
+		attrs: []testAttr{{name: "xmlns", value: testText(eventNamespace)}},
+		children: []testNode{
+			&testElement{name: "System", children: []testNode{
+				&testElement{name: "Provider", attrs: []testAttr{
+					{name: "Name", value: testSubstitution{index: 0, valueType: evtxTypeString}},
+					{name: "Guid", value: testSubstitution{index: 1, valueType: evtxTypeGuid}},
+				}},
+				&testElement{name: "EventID", children: []testNode{testSubstitution{index: 2, valueType: evtxTypeUInt16}}},
+				&testElement{name: "Version", children: []testNode{testSubstitution{index: 3, valueType: evtxTypeUInt8}}},
+				&testElement{name: "Level", children: []testNode{testSubstitution{index: 4, valueType: evtxTypeUInt8}}},
+				&testElement{name: "Task", children: []testNode{testSubstitution{index: 5, valueType: evtxTypeUInt16}}},
+				&testElement{name: "Opcode", children: []testNode{testSubstitution{index: 6, valueType: evtxTypeUInt8}}},
+				&testElement{name: "Keywords", children: []testNode{testSubstitution{index: 7, valueType: evtxTypeHexInt64}}},
+				&testElement{name: "TimeCreated", attrs: []testAttr{
+					{name: "SystemTime", value: testSubstitution{index: 8, valueType: evtxTypeFileTime}},
+				}},
+				&testElement{name: "EventRecordID", children: []testNode{testSubstitution{index: 9, valueType: evtxTypeUInt64}}},
+				&testElement{name: "Correlation", attrs: []testAttr{
+					{name: "ActivityID", value: testSubstitution{index: 10, valueType: evtxTypeGuid, optional: true}},
+				}},
+				&testElement{name: "Execution", attrs: []testAttr{
+					{name: "ProcessID", value: testSubstitution{index: 11, valueType: evtxTypeUInt32}},
+					{name: "ThreadID", value: testSubstitution{index: 12, valueType: evtxTypeUInt32}},
+				}},
+				&testElement{name: "Channel", children: []testNode{testText("Security")}},
+				&testElement{name: "Computer", children: []testNode{testSubstitution{index: 13, valueType: evtxTypeString}}},
+				&testElement{name: "Security"},
+			}},
+			&testElement{name: "EventData", children: []testNode{
+				&testElement{name: "Data", attrs: []testAttr{{name: "Name", value: testText("SubjectUserSid")}},
+					children: []testNode{testSubstitution{index: 14, valueType: evtxTypeSid}}},
+				&testElement{name: "Data", attrs: []testAttr{{name: "Name", value: testText("TargetUserName")}},
+					children: []testNode{testSubstitution{index: 15, valueType: evtxTypeString}}},
+				&testElement{name: "Data", attrs: []testAttr{{name: "Name", value: testText("LogonType")}},
+					children: []testNode{testSubstitution{index: 16, valueType: evtxTypeUInt32}}},
+				&testElement{name: "Data", attrs: []testAttr{{name: "Name", value: testText("IpAddress")}},
+					children: []testNode{testSubstitution{index: 17, valueType: evtxTypeString, optional: true}}},
+			}},
+		},
+	}}
+
+	// an event with unnamed EventData values, array and binary values
+	processTemplate = &testTemplate{id: 0x1002, root: &testElement{
+		name: "Event",
+		children: []testNode{
+			&testElement{name: "System", children: []testNode{
+				&testElement{name: "Provider", attrs: []testAttr{
+					{name: "Name", value: testSubstitution{index: 0, valueType: evtxTypeString}},
+				}},
+				&testElement{name: "EventID", children: []testNode{testSubstitution{index: 1, valueType: evtxTypeUInt16}}},
+				&testElement{name: "TimeCreated", attrs: []testAttr{
+					{name: "SystemTime", value: testSubstitution{index: 2, valueType: evtxTypeFileTime}},
+				}},
+				&testElement{name: "Channel", children: []testNode{testSubstitution{index: 3, valueType: evtxTypeString}}},
+			}},
+			&testElement{name: "EventData", children: []testNode{
+				&testElement{name: "Data", children: []testNode{testSubstitution{index: 4, valueType: evtxTypeString}}},
+				&testElement{name: "Data", children: []testNode{testSubstitution{index: 5, valueType: evtxTypeString | evtxTypeArrayFlag}}},
+				&testElement{name: "Binary", children: []testNode{testSubstitution{index: 6, valueType: evtxTypeBinary}}},
+			}},
+		},
+	}}
+
+	// a system event, with the UserData element in a BinXML value
+	logClearedTemplate = &testTemplate{id: 0x2001, root: &testElement{
+		name: "Event",
+		children: []testNode{
+			&testElement{name: "System", children: []testNode{
+				&testElement{name: "Provider", attrs: []testAttr{
+					{name: "Name", value: testSubstitution{index: 0, valueType: evtxTypeString}},
+				}},
+				&testElement{name: "EventID", attrs: []testAttr{
+					{name: "Qualifiers", value: testSubstitution{index: 1, valueType: evtxTypeUInt16, optional: true}},
+				}, children: []testNode{testSubstitution{index: 2, valueType: evtxTypeUInt16}}},
+				&testElement{name: "TimeCreated", attrs: []testAttr{
+					{name: "SystemTime", value: testSubstitution{index: 3, valueType: evtxTypeFileTime}},
+				}},
+				&testElement{name: "Channel", children: []testNode{testSubstitution{index: 4, valueType: evtxTypeString}}},
+				&testElement{name: "Computer", children: []testNode{testSubstitution{index: 5, valueType: evtxTypeString}}},
+			}},
+			testSubstitution{index: 6, valueType: evtxTypeBinXml},
+		},
+	}}
+	userDataTemplate = &testTemplate{id: 0x2002, root: &testElement{
+		name: "UserData",
+		children: []testNode{
+			&testElement{name: "LogFileCleared", attrs: []testAttr{{name: "xmlns", value: testText("http://manifests.microsoft.com/win/2004/08/windows/eventlog")}},
+				children: []testNode{
+					&testElement{name: "SubjectUserName", children: []testNode{testSubstitution{index: 0, valueType: evtxTypeString}}},
+					&testElement{name: "SubjectDomainName", children: []testNode{testSubstitution{index: 1, valueType: evtxTypeString}}},
+				}},
+		},
+	}}
+)
+
+var testEventTime = time.Date(2024, 5, 1, 12, 0, 0, 123456700, time.UTC)
+
+// the sample files - the first chunk of security.evtx contains two instances of the same template,
+// and the last chunk is unused
+var testEvtxFiles = map[string][][]testRecord{
+	"security.evtx": {
+		{
+			{id: 1, written: testEventTime, template: logonTemplate, values: []testValue{
+				stringValue("Microsoft-Windows-Security-Auditing"), guidValue("{54849625-5478-4994-A5BA-3E3B0328C30D}"),
+				uint16Value(4624), uint8Value(2), uint8Value(0), uint16Value(12544), uint8Value(0), hexInt64Value(0x8020000000000000),
+				fileTimeValue(testEventTime), uint64Value(1), guidValue("{0D4CAB71-9B6C-0000-A0AB-4C0D6C9BDA01}"),
+				uint32Value(636), uint32Value(5772), stringValue("WIN-TEST"),
+				sidValue(18), stringValue("alice"), uint32Value(3), stringValue("10.0.0.5"),
+			}},
+			{id: 2, written: testEventTime.Add(time.Second), template: logonTemplate, values: []testValue{
+				stringValue("Microsoft-Windows-Security-Auditing"), guidValue("{54849625-5478-4994-A5BA-3E3B0328C30D}"),
+				uint16Value(4624), uint8Value(2), uint8Value(0), uint16Value(12544), uint8Value(0), hexInt64Value(0x8020000000000000),
+				fileTimeValue(testEventTime.Add(time.Second)), uint64Value(2), nullValue(),
+				uint32Value(636), uint32Value(5776), stringValue("WIN-TEST"),
+				sidValue(21, 1004336348, 1177238915, 682003330, 512), stringValue("bob & co"), uint32Value(2), nullValue(),
+			}},
+		},
+		{
+			{id: 3, written: testEventTime.Add(2 * time.Second), template: processTemplate, values: []testValue{
+				stringValue("Test-Provider"), uint16Value(100), fileTimeValue(testEventTime.Add(2 * time.Second)), stringValue("Application"),
+				stringValue("first"), stringArrayValue("a", "b"), binaryValue([]byte{0xde, 0xad, 0xbe, 0xef}),
+			}},
+		},
+		nil,
+	},
+	"system.evtx": {
+		{
+			{id: 10, written: testEventTime, template: logClearedTemplate, values: []testValue{
+				stringValue("Microsoft-Windows-Eventlog"), nullValue(), uint16Value(104), fileTimeValue(testEventTime),
+				stringValue("System"), stringValue("WIN-TEST"),
+				binXmlFragmentValue(userDataTemplate, stringValue("admin"), stringValue("CORP")),
+			}},
+		},
+	},
+}
+
+var testEvtxRows = map[string][]map[string]string{
+	"security.evtx": {
+		{
+			"Provider":                 "Microsoft-Windows-Security-Auditing",
+			"ProviderGuid":             "{54849625-5478-4994-A5BA-3E3B0328C30D}",
+			"EventID":                  "4624",
+			"Version":                  "2",
+			"Level":                    "0",
+			"Task":                     "12544",
+			"Opcode":                   "0",
+			"Keywords":                 "0x8020000000000000",
+			"TimeCreated":              "2024-05-01T12:00:00.1234567Z",
+			"EventRecordID":            "1",
+			"ActivityID":               "{0D4CAB71-9B6C-0000-A0AB-4C0D6C9BDA01}",
+			"ProcessID":                "636",
+			"ThreadID":                 "5772",
+			"Channel":                  "Security",
+			"Computer":                 "WIN-TEST",
+			"EventData.SubjectUserSid": "S-1-5-18",
+			"EventData.TargetUserName": "alice",
+			"EventData.LogonType":      "3",
+			"EventData.IpAddress":      "10.0.0.5",
+		},
+		{
+			"Provider":                 "Microsoft-Windows-Security-Auditing",
+			"ProviderGuid":             "{54849625-5478-4994-A5BA-3E3B0328C30D}",
+			"EventID":                  "4624",
+			"Version":                  "2",
+			"Level":                    "0",
+			"Task":                     "12544",
+			"Opcode":                   "0",
+			"Keywords":                 "0x8020000000000000",
+			"TimeCreated":              "2024-05-01T12:00:01.1234567Z",
+			"EventRecordID":            "2",
+			"ProcessID":                "636",
+			"ThreadID":                 "5776",
+			"Channel":                  "Security",
+			"Computer":                 "WIN-TEST",
+			"EventData.SubjectUserSid": "S-1-5-21-1004336348-1177238915-682003330-512",
+			"EventData.TargetUserName": "bob & co",
+			"EventData.LogonType":      "2",
+		},
+		{
+			"Provider":          "Test-Provider",
+			"EventID":           "100",
+			"TimeCreated":       "2024-05-01T12:00:02.1234567Z",
+			"EventRecordID":     "3",
+			"Channel":           "Application",
+			"EventData.Data[0]": "first",
+			"EventData.Data[1]": "a, b",
+			"EventData.Binary":  "DEADBEEF",
+		},
+	},
+	"system.evtx": {
+		{
+			"Provider":      "Microsoft-Windows-Eventlog",
+			"EventID":       "104",
+			"TimeCreated":   "2024-05-01T12:00:00.1234567Z",
+			"EventRecordID": "10",
+			"Channel":       "System",
+			"Computer":      "WIN-TEST",
+			"UserData.LogFileCleared.SubjectUserName":   "admin",
+			"UserData.LogFileCleared.SubjectDomainName": "CORP",
+		},
+	},
+}
+
+func TestEvtxLoader_Load(t *testing.T) {
+	for name, chunks := range testEvtxFiles {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("test_data", "evtx", name)
+			data := writeTestEvtx(chunks)
+			if *updateTestData {
+				if err := os.WriteFile(path, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rows, err := loadTestEvtx(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(rows, testEvtxRows[name]) {
+				t.Errorf("expected rows %v, got %v", testEvtxRows[name], rows)
+			}
+		})
+	}
+}
+
+func TestEvtxLoader_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	notEvtx := filepath.Join(dir, "not.evtx")
+	if err := os.WriteFile(notEvtx, []byte("this is not an evtx file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadTestEvtx(notEvtx); err == nil || !strings.Contains(err.Error(), "not an EVTX file") {
+		t.Errorf("expected not an EVTX file error, got %v", err)
+	}
+
+	// corrupt the template definition in the first chunk - the records in the second chunk should still be read
+	data, err := os.ReadFile(filepath.Join("test_data", "evtx", "security.evtx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventOffset := evtxFileHeaderBlockSize + bytes.Index(data[evtxFileHeaderBlockSize:], []byte{'E', 0, 'v', 0, 'e', 0, 'n', 0, 't', 0})
+	data[eventOffset+20] = 0xff
+	corrupt := filepath.Join(dir, "corrupt.evtx")
+	if err := os.WriteFile(corrupt, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := loadTestEvtx(corrupt)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(rows) != 1 || rows[0]["EventRecordID"] != "3" {
+		t.Errorf("expected only record 3, got %v", rows)
+	}
+}
+
+func TestRenderScalar(t *testing.T) {
+	tests := []struct {
+		name      string
+		valueType uint8
+		data      []byte
+		expected  string
+	}{
+		{"int8", evtxTypeInt8, []byte{0xff}, "-1"},
+		{"int32", evtxTypeInt32, []byte{0xfe, 0xff, 0xff, 0xff}, "-2"},
+		{"real64", evtxTypeReal64, []byte{0, 0, 0, 0, 0, 0, 0xf8, 0x3f}, "1.5"},
+		{"bool", evtxTypeBool, []byte{1, 0, 0, 0}, "true"},
+		{"size_t", evtxTypeSizeT, []byte{0x10, 0, 0, 0}, "0x10"},
+		{"hex int32", evtxTypeHexInt32, []byte{0xff, 0, 0, 0}, "0xff"},
+		{"ansi string", evtxTypeAnsiString, []byte("ansi\x00"), "ansi"},
+		{"systemtime", evtxTypeSysTime, []byte{0xe8, 0x07, 5, 0, 3, 0, 1, 0, 12, 0, 30, 0, 15, 0, 0xf4, 0x01}, "2024-05-01T12:30:15.5000000Z"},
+		{"truncated", evtxTypeUInt32, []byte{1, 2}, "0102"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderScalar(tt.valueType, tt.data); got != tt.expected {
+				t.Errorf("renderScalar() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// loadTestEvtx loads the file with the evtx loader, returning the rows
+func loadTestEvtx(path string) ([]map[string]string, error) {
+	info := types.NewDownloadedArtifactInfo(&types.ArtifactInfo{Name: path}, path, 0)
+	dataChan := make(chan *types.RowData)
+	if err := (&EvtxLoader{}).Load(context.Background(), info, dataChan); err != nil {
+		return nil, err
+	}
+	var rows []map[string]string
+	for data := range dataChan {
+		rows = append(rows, data.Data.(map[string]string))
+	}
+	return rows, nil
+}
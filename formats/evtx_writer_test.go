@@ -0,0 +1,338 @@
+package formats
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// this file contains a minimal EVTX writer, used to generate the sample files in test_data/evtx
+// (run the tests with -update to regenerate them)
+
+// testNode is a node of a test template
+type testNode interface {
+	write(b *binXmlBuilder)
+}
+
+type testElement struct {
+	name     string
+	attrs    []testAttr
+	children []testNode
+}
+
+type testAttr struct {
+	name  string
+	value testNode
+}
+
+type testText string
+
+type testSubstitution struct {
+	index     int
+	valueType uint8
+	optional  bool
+}
+
+type testTemplate struct {
+	id   uint32
+	root *testElement
+}
+
+type testValue struct {
+	valueType uint8
+	// data returns the value data, given the chunk offset it is written at (BinXML values contain chunk offsets)
+	data func(offset int) []byte
+}
+
+type testRecord struct {
+	id       uint64
+	written  time.Time
+	template *testTemplate
+	values   []testValue
+}
+
+// binXmlBuilder writes BinXML to a buffer which starts at the given chunk offset
+type binXmlBuilder struct {
+	base int
+	buf  []byte
+	// the offsets of the template definitions already written to the chunk - if nil, definitions are always inline
+	templates map[*testTemplate]int
+}
+
+func (b *binXmlBuilder) pos() int {
+	return b.base + len(b.buf)
+}
+
+func (b *binXmlBuilder) u8(v uint8) {
+	b.buf = append(b.buf, v)
+}
+
+func (b *binXmlBuilder) u16(v int) {
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(v))
+}
+
+func (b *binXmlBuilder) u32(v int) {
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v))
+}
+
+func (b *binXmlBuilder) putU32(pos, v int) {
+	binary.LittleEndian.PutUint32(b.buf[pos-b.base:], uint32(v))
+}
+
+func (b *binXmlBuilder) utf16(s string) {
+	for _, c := range utf16.Encode([]rune(s)) {
+		b.u16(int(c))
+	}
+}
+
+// name writes a name reference followed by the inline name
+func (b *binXmlBuilder) name(s string) {
+	b.u32(b.pos() + 4)
+	b.u32(0)
+	b.u16(0)
+	b.u16(len(utf16.Encode([]rune(s))))
+	b.utf16(s)
+	b.u16(0)
+}
+
+func (b *binXmlBuilder) fragment(write func()) {
+	b.buf = append(b.buf, binXmlTokenFragmentHeader, 1, 1, 0)
+	write()
+	b.u8(binXmlTokenEOF)
+}
+
+func (b *binXmlBuilder) templateInstance(t *testTemplate, values []testValue) {
+	b.u8(binXmlTokenTemplateInstance)
+	b.u8(1)
+	b.u32(int(t.id))
+	if offset, ok := b.templates[t]; ok {
+		b.u32(offset)
+	} else {
+		offset := b.pos() + 4
+		if b.templates != nil {
+			b.templates[t] = offset
+		}
+		b.u32(offset)
+		// next definition offset, guid (starting with the template id), data size
+		b.u32(0)
+		b.u32(int(t.id))
+		b.buf = append(b.buf, make([]byte, 12)...)
+		sizePos := b.pos()
+		b.u32(0)
+		b.fragment(func() { t.root.write(b) })
+		b.putU32(sizePos, b.pos()-sizePos-4)
+	}
+
+	// the value descriptors are followed by the value data
+	b.u32(len(values))
+	dataPos := b.pos() + 4*len(values)
+	var data [][]byte
+	for _, v := range values {
+		d := v.data(dataPos)
+		data = append(data, d)
+		dataPos += len(d)
+	}
+	for i, v := range values {
+		b.u16(len(data[i]))
+		b.u8(v.valueType)
+		b.u8(0)
+	}
+	for _, d := range data {
+		b.buf = append(b.buf, d...)
+	}
+}
+
+func (e *testElement) write(b *binXmlBuilder) {
+	token := uint8(binXmlTokenOpenStartElement)
+	if len(e.attrs) > 0 {
+		token |= binXmlTokenMoreDataFlag
+	}
+	b.u8(token)
+	b.u16(0xffff)
+	// the data size is not used by the reader
+	b.u32(0)
+	b.name(e.name)
+	if len(e.attrs) > 0 {
+		// the attribute list size is not used by the reader
+		b.u32(0)
+	}
+	for i, a := range e.attrs {
+		token := uint8(binXmlTokenAttribute)
+		if i < len(e.attrs)-1 {
+			token |= binXmlTokenMoreDataFlag
+		}
+		b.u8(token)
+		b.name(a.name)
+		a.value.write(b)
+	}
+	if len(e.children) == 0 {
+		b.u8(binXmlTokenCloseEmptyElement)
+		return
+	}
+	b.u8(binXmlTokenCloseStartElement)
+	for _, child := range e.children {
+		child.write(b)
+	}
+	b.u8(binXmlTokenEndElement)
+}
+
+func (t testText) write(b *binXmlBuilder) {
+	b.u8(binXmlTokenValue)
+	b.u8(evtxTypeString)
+	b.u16(len(utf16.Encode([]rune(string(t)))))
+	b.utf16(string(t))
+}
+
+func (s testSubstitution) write(b *binXmlBuilder) {
+	token := uint8(binXmlTokenNormalSubstitution)
+	if s.optional {
+		token = binXmlTokenOptionalSubstitution
+	}
+	b.u8(token)
+	b.u16(s.index)
+	b.u8(s.valueType)
+}
+
+// writeTestEvtx returns an EVTX file containing the given chunks of records - a nil chunk is written as an unused chunk
+func writeTestEvtx(chunks [][]testRecord) []byte {
+	le := binary.LittleEndian
+	file := make([]byte, evtxFileHeaderBlockSize)
+
+	var nextRecordId uint64 = 1
+	for _, records := range chunks {
+		chunk := make([]byte, evtxChunkSize)
+		if records != nil {
+			b := &binXmlBuilder{buf: make([]byte, evtxChunkHeaderSize), templates: make(map[*testTemplate]int)}
+			var lastRecordOffset int
+			for _, r := range records {
+				lastRecordOffset = b.pos()
+				b.buf = append(b.buf, evtxRecordSignature...)
+				b.u32(0)
+				b.buf = le.AppendUint64(b.buf, r.id)
+				b.buf = le.AppendUint64(b.buf, timeToFileTime(r.written))
+				b.fragment(func() { b.templateInstance(r.template, r.values) })
+				size := b.pos() - lastRecordOffset + 4
+				b.u32(size)
+				b.putU32(lastRecordOffset+4, size)
+				nextRecordId = r.id + 1
+			}
+			copy(chunk, b.buf)
+
+			copy(chunk, evtxChunkSignature)
+			le.PutUint64(chunk[8:], records[0].id)
+			le.PutUint64(chunk[16:], records[len(records)-1].id)
+			le.PutUint64(chunk[24:], records[0].id)
+			le.PutUint64(chunk[32:], records[len(records)-1].id)
+			le.PutUint32(chunk[40:], 128)
+			le.PutUint32(chunk[44:], uint32(lastRecordOffset))
+			le.PutUint32(chunk[48:], uint32(len(b.buf)))
+			le.PutUint32(chunk[52:], crc32.ChecksumIEEE(b.buf[evtxChunkHeaderSize:]))
+			le.PutUint32(chunk[124:], crc32.ChecksumIEEE(append(append([]byte{}, chunk[:120]...), chunk[128:evtxChunkHeaderSize]...)))
+		}
+		file = append(file, chunk...)
+	}
+
+	copy(file, evtxFileSignature)
+	le.PutUint64(file[16:], uint64(len(chunks)-1))
+	le.PutUint64(file[24:], nextRecordId)
+	le.PutUint32(file[32:], 128)
+	le.PutUint16(file[36:], 1)
+	le.PutUint16(file[38:], 3)
+	le.PutUint16(file[40:], evtxFileHeaderBlockSize)
+	le.PutUint16(file[42:], uint16(len(chunks)))
+	le.PutUint32(file[124:], crc32.ChecksumIEEE(file[:120]))
+	return file
+}
+
+func timeToFileTime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
+}
+
+func fixedValue(valueType uint8, data []byte) testValue {
+	return testValue{valueType: valueType, data: func(int) []byte { return data }}
+}
+
+func stringValue(s string) testValue {
+	b := &binXmlBuilder{}
+	b.utf16(s)
+	return fixedValue(evtxTypeString, b.buf)
+}
+
+func stringArrayValue(items ...string) testValue {
+	b := &binXmlBuilder{}
+	for _, s := range items {
+		b.utf16(s)
+		b.u16(0)
+	}
+	return fixedValue(evtxTypeString|evtxTypeArrayFlag, b.buf)
+}
+
+func uint8Value(v uint8) testValue {
+	return fixedValue(evtxTypeUInt8, []byte{v})
+}
+
+func uint16Value(v uint16) testValue {
+	return fixedValue(evtxTypeUInt16, binary.LittleEndian.AppendUint16(nil, v))
+}
+
+func uint32Value(v uint32) testValue {
+	return fixedValue(evtxTypeUInt32, binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func uint64Value(v uint64) testValue {
+	return fixedValue(evtxTypeUInt64, binary.LittleEndian.AppendUint64(nil, v))
+}
+
+func hexInt64Value(v uint64) testValue {
+	return fixedValue(evtxTypeHexInt64, binary.LittleEndian.AppendUint64(nil, v))
+}
+
+func fileTimeValue(t time.Time) testValue {
+	return fixedValue(evtxTypeFileTime, binary.LittleEndian.AppendUint64(nil, timeToFileTime(t)))
+}
+
+func binaryValue(data []byte) testValue {
+	return fixedValue(evtxTypeBinary, data)
+}
+
+func nullValue() testValue {
+	return fixedValue(evtxTypeNull, nil)
+}
+
+// guidValue returns a GUID value, given the GUID in the form {xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx}
+func guidValue(guid string) testValue {
+	parts := strings.Split(strings.Trim(guid, "{}"), "-")
+	var data []byte
+	for i, part := range parts {
+		b, _ := hex.DecodeString(part)
+		// the first three parts are little endian
+		if i < 3 {
+			for l, r := 0, len(b)-1; l < r; l, r = l+1, r-1 {
+				b[l], b[r] = b[r], b[l]
+			}
+		}
+		data = append(data, b...)
+	}
+	return fixedValue(evtxTypeGuid, data)
+}
+
+// sidValue returns a SID value with an NT authority (5) and the given sub authorities
+func sidValue(subAuthorities ...uint32) testValue {
+	data := []byte{1, uint8(len(subAuthorities)), 0, 0, 0, 0, 0, 5}
+	for _, s := range subAuthorities {
+		data = binary.LittleEndian.AppendUint32(data, s)
+	}
+	return fixedValue(evtxTypeSid, data)
+}
+
+// binXmlFragmentValue returns a BinXML value containing an instance of the template
+func binXmlFragmentValue(t *testTemplate, values ...testValue) testValue {
+	return testValue{valueType: evtxTypeBinXml, data: func(offset int) []byte {
+		b := &binXmlBuilder{base: offset}
+		b.fragment(func() { b.templateInstance(t, values) })
+		return b.buf
+	}}
+}
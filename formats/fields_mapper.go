@@ -0,0 +1,30 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// FieldsMapper maps the fields of a record, as read by a loader which reads each record into a map of field name to
+// value (e.g. the json, evtx and w3c loaders), to a DynamicRow
+type FieldsMapper struct{}
+
+func (m *FieldsMapper) Identifier() string {
+	return "fields_mapper"
+}
+
+func (m *FieldsMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	fields, ok := a.(map[string]string)
+	if !ok {
+		return nil, fmt.Errorf("expected map[string]string, got %T", a)
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from record: %w", err)
+	}
+	return row, nil
+}
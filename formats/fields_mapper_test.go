@@ -0,0 +1,20 @@
+package formats
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFieldsMapper_Map(t *testing.T) {
+	row, err := (&FieldsMapper{}).Map(context.Background(), map[string]string{"EventID": "4624"})
+	if err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	if value, ok := row.GetSourceValue("EventID"); !ok || value != "4624" {
+		t.Errorf("expected EventID 4624, got %q", value)
+	}
+
+	if _, err := (&FieldsMapper{}).Map(context.Background(), "a line"); err == nil {
+		t.Errorf("expected error mapping a string")
+	}
+}
@@ -0,0 +1,106 @@
+package formats
+
+import (
+	"maps"
+
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// Grok is the SDK grok format, extended to support multiline records
+// this is registered in place of the SDK format, so has the same identifier
+type Grok struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+	// the layout of the log line
+	// NOTE that as will contain grok patterns, this property is included in constants.GrokConfigProperties
+	// meaning and '{' will be auto-escaped in the hcl
+	Layout string `hcl:"layout"`
+
+	// grok patterns to add to the grok parser used to parse the layout
+	Patterns map[string]string `hcl:"patterns,optional"`
+
+	// how each artifact is split into records: "line" (the default) - each line is a record, or "file" - each
+	// artifact is a single record
+	ArtifactMode *string `hcl:"artifact_mode,optional"`
+
+	// if set, lines are grouped into multiline records before they are parsed (line mode only)
+	Multiline *Multiline `hcl:"multiline,block"`
+}
+
+func NewGrok() formats.Format {
+	return &Grok{}
+}
+
+func (g *Grok) Validate() error {
+	return validateArtifactMode(g.GetArtifactMode(), g.Multiline)
+}
+
+// GetArtifactMode returns how each artifact is split into records (default "line")
+func (g *Grok) GetArtifactMode() string {
+	if g.ArtifactMode == nil {
+		return ArtifactModeLine
+	}
+	return *g.ArtifactMode
+}
+
+// Identifier returns the format type identifier
+func (g *Grok) Identifier() string {
+	return constants.SourceFormatGrok
+}
+
+// GetName returns the name of this format instance
+func (g *Grok) GetName() string {
+	return g.Name
+}
+
+// SetName sets the name of this format instance
+func (g *Grok) SetName(name string) {
+	g.Name = name
+}
+
+func (g *Grok) GetDescription() string {
+	return g.Description
+}
+
+func (g *Grok) GetProperties() map[string]string {
+	properties := g.sdkFormat().GetProperties()
+	if g.ArtifactMode != nil {
+		properties["artifact_mode"] = *g.ArtifactMode
+	}
+	if g.Multiline != nil {
+		maps.Copy(properties, g.Multiline.GetProperties())
+	}
+	return properties
+}
+
+func (g *Grok) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	mapper, err := g.sdkFormat().GetMapper()
+	if err != nil {
+		return nil, err
+	}
+	return &textMapper{Mapper: mapper}, nil
+}
+
+func (g *Grok) GetRegex() (string, error) {
+	return g.sdkFormat().GetRegex()
+}
+
+// GetSourceOptions returns the options for the source, based on the artifact mode - if multiline is set, the lines are
+// loaded by the multiline loader, which groups them into records
+func (g *Grok) GetSourceOptions() []row_source.RowSourceOption {
+	return lineSourceOptions(g.GetArtifactMode(), g.Multiline)
+}
+
+// sdkFormat returns the SDK grok format, which parses the layout
+func (g *Grok) sdkFormat() *formats.Grok {
+	return &formats.Grok{
+		Name:        g.Name,
+		Description: g.Description,
+		Layout:      g.Layout,
+		Patterns:    g.Patterns,
+	}
+}
@@ -0,0 +1,24 @@
+package formats
+
+import (
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// SourceOptionsProvider is implemented by formats which need to configure the source which reads their artifacts,
+// for example formats which read binary files or JSON documents and so provide their own artifact loader, or formats
+// which group lines into multiline records or read each file as a single record
+// (the row per line loader is used for formats which do not implement this)
+type SourceOptionsProvider interface {
+	GetSourceOptions() []row_source.RowSourceOption
+}
+
+// ArtifactModTimeProvider is implemented by loaders which load artifacts whose modification time is not that of the
+// local file, for example the file source loader, which reads the members of archives directly from the archive
+// (loaders which decorate another loader use this to obtain the modification time of the artifact)
+type ArtifactModTimeProvider interface {
+	// ArtifactModTime returns the modification time of the artifact, if it is known
+	ArtifactModTime(info *types.DownloadedArtifactInfo) (time.Time, bool)
+}
@@ -0,0 +1,98 @@
+package formats
+
+import (
+	"fmt"
+
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const JsonFormatIdentifier = "json"
+
+// DefaultJson is the default JSON format - each JSON document is a record
+var DefaultJson = &Json{
+	Name:        "default",
+	Description: "JSON document format",
+}
+
+// Json is a format for files containing JSON documents
+// By default each document is a record - if records_path is set, the values at that path are the records, for example
+// "Records[*]" for the elements of the Records array of each document
+// The records are decoded as they are read, so a file containing a large array does not need to fit in memory
+// Each record must be an object - its top level fields are the columns, with objects and arrays as JSON text
+type Json struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+	// the path of the records within each document - a "." separated list of object keys, each of which may be
+	// followed by "[*]" to select every element of an array, e.g. "Records[*]", "data.items[*]" or "[*]"
+	RecordsPath *string `hcl:"records_path,optional"`
+}
+
+func NewJson() formats.Format {
+	return &Json{}
+}
+
+func (j *Json) Validate() error {
+	if j.RecordsPath != nil {
+		if _, err := parseJsonPath(*j.RecordsPath); err != nil {
+			return fmt.Errorf("invalid records_path %s: %w", *j.RecordsPath, err)
+		}
+	}
+	return nil
+}
+
+// GetName returns the name of this format instance
+func (j *Json) GetName() string {
+	return j.Name
+}
+
+// SetName sets the name of this format instance
+func (j *Json) SetName(name string) {
+	j.Name = name
+}
+
+// GetDescription returns the description of this format instance
+func (j *Json) GetDescription() string {
+	return j.Description
+}
+
+// GetProperties returns the format properties as a string map - used for introspection
+func (j *Json) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	if j.RecordsPath != nil {
+		properties["records_path"] = *j.RecordsPath
+	}
+	return properties
+}
+
+// Identifier returns the format type identifier
+func (j *Json) Identifier() string {
+	return JsonFormatIdentifier
+}
+
+func (j *Json) GetRegex() (string, error) {
+	// the JSON format does not support regex
+	return "N/A", nil
+}
+
+func (j *Json) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	return &FieldsMapper{}, nil
+}
+
+// GetSourceOptions returns the options for the source - JSON documents are read by the json loader
+func (j *Json) GetSourceOptions() []row_source.RowSourceOption {
+	return []row_source.RowSourceOption{
+		artifact_source.WithArtifactLoader(NewJsonLoader(j.GetRecordsPath())),
+	}
+}
+
+// GetRecordsPath returns the path of the records within each document ("" if each document is a record)
+func (j *Json) GetRecordsPath() string {
+	if j.RecordsPath == nil {
+		return ""
+	}
+	return *j.RecordsPath
+}
@@ -0,0 +1,247 @@
+package formats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/turbot/tailpipe-plugin-core/compression"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const JsonLoaderIdentifier = "json_loader"
+
+// errJsonRecordNotObject is sent in place of a record which is not a JSON object, so it is reported as a row error
+var errJsonRecordNotObject = errors.New("JSON record is not an object")
+
+// JsonLoader is a Loader which reads the records from files containing JSON documents
+// The documents are decoded as they are read, and each record is sent as a map of its top level fields as soon as
+// it has been decoded - so only one record at a time is held in memory
+type JsonLoader struct {
+	recordsPath string
+	// opens the artifact data - if this is not set, the local file is opened and decompressed if it is compressed
+	open func(*types.DownloadedArtifactInfo) (io.ReadCloser, error)
+}
+
+// NewJsonLoader returns a JsonLoader which reads the records at the given path of each document
+// (if the path is empty, each document is a record)
+func NewJsonLoader(recordsPath string) *JsonLoader {
+	return &JsonLoader{recordsPath: recordsPath}
+}
+
+func (l *JsonLoader) Identifier() string {
+	return JsonLoaderIdentifier
+}
+
+// SetArtifactOpener sets the function which opens the artifact data
+// this allows a source which reads the artifact data itself (e.g. decompressing or transcoding it) to provide the data
+func (l *JsonLoader) SetArtifactOpener(open func(*types.DownloadedArtifactInfo) (io.ReadCloser, error)) {
+	l.open = open
+}
+
+// Load implements Loader
+// A document which can not be decoded is reported as a row error - any records read before the error are loaded
+func (l *JsonLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	path, err := parseJsonPath(l.recordsPath)
+	if err != nil {
+		return err
+	}
+
+	var r io.ReadCloser
+	if l.open != nil {
+		r, err = l.open(info)
+	} else {
+		r, err = compression.Open(info.LocalName)
+	}
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", info.LocalName, err)
+	}
+
+	go func() {
+		defer func() {
+			r.Close()
+			close(dataChan)
+		}()
+
+		err := readJsonRecords(r, path, func(record json.RawMessage) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var data any = errJsonRecordNotObject
+			if fields, ok := jsonRecordFields(record); ok {
+				data = fields
+			}
+			dataChan <- &types.RowData{
+				Data: data,
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("JsonLoader error reading file", "path", info.LocalName, "error", err)
+			dataChan <- &types.RowData{
+				Data: fmt.Errorf("error decoding JSON: %w", err),
+			}
+		}
+	}()
+	return nil
+}
+
+// jsonPathSegment is a segment of a records path - either an object key, or "[*]", which selects every element of
+// an array
+type jsonPathSegment struct {
+	key      string
+	wildcard bool
+}
+
+// parseJsonPath parses a records path, e.g. "Records[*]", "data.items[*]" or "[*]"
+func parseJsonPath(path string) ([]jsonPathSegment, error) {
+	if path == "" {
+		return nil, nil
+	}
+	var segments []jsonPathSegment
+	for i, part := range strings.Split(path, ".") {
+		key := part
+		var wildcards int
+		for strings.HasSuffix(key, "[*]") {
+			key = strings.TrimSuffix(key, "[*]")
+			wildcards++
+		}
+		if strings.ContainsAny(key, "[]") {
+			return nil, fmt.Errorf("only [*] is supported to select array elements")
+		}
+		// only the first segment may select the elements of a top level array, e.g. "[*]"
+		if key == "" && (i > 0 || wildcards == 0) {
+			return nil, fmt.Errorf("path contains an empty key")
+		}
+		if key != "" {
+			segments = append(segments, jsonPathSegment{key: key})
+		}
+		for range wildcards {
+			segments = append(segments, jsonPathSegment{wildcard: true})
+		}
+	}
+	return segments, nil
+}
+
+// readJsonRecords decodes the JSON documents read from the reader, calling fn with each record at the path
+func readJsonRecords(r io.Reader, path []jsonPathSegment, fn func(json.RawMessage) error) error {
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		if err := walkJson(dec, path, fn); err != nil {
+			// the input ended part way through a document
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// walkJson reads the next value from the decoder, calling fn with each record at the path within the value
+// values which are not at the path are skipped without being decoded
+func walkJson(dec *json.Decoder, path []jsonPathSegment, fn func(json.RawMessage) error) error {
+	if len(path) == 0 {
+		var record json.RawMessage
+		if err := dec.Decode(&record); err != nil {
+			return err
+		}
+		return fn(record)
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	segment := path[0]
+	if segment.wildcard {
+		if tok != json.Delim('[') {
+			return fmt.Errorf("expected an array at offset %d", dec.InputOffset())
+		}
+		for dec.More() {
+			if err := walkJson(dec, path[1:], fn); err != nil {
+				return err
+			}
+		}
+	} else {
+		if tok != json.Delim('{') {
+			return fmt.Errorf("expected an object containing %s at offset %d", segment.key, dec.InputOffset())
+		}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if key == segment.key {
+				err = walkJson(dec, path[1:], fn)
+			} else {
+				err = skipJsonValue(dec)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// read the closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// skipJsonValue reads the next value from the decoder without decoding it
+func skipJsonValue(dec *json.Decoder) error {
+	var depth int
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// jsonRecordFields returns the top level fields of a JSON object as strings - objects and arrays are returned as
+// (compact) JSON text, and null fields are omitted
+// it returns false if the record is not an object
+func jsonRecordFields(record json.RawMessage) (map[string]string, bool) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(record, &object); err != nil || object == nil {
+		return nil, false
+	}
+
+	fields := make(map[string]string, len(object))
+	for key, value := range object {
+		switch value[0] {
+		case '"':
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return nil, false
+			}
+			fields[key] = s
+		case 'n':
+			// null
+		case '{', '[':
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, value); err != nil {
+				return nil, false
+			}
+			fields[key] = buf.String()
+		default:
+			// numbers and booleans are used as is
+			fields[key] = string(value)
+		}
+	}
+	return fields, true
+}
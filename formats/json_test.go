@@ -0,0 +1,291 @@
+package formats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pierrec/lz4/v4"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+	"github.com/ulikunitz/xz"
+)
+
+func TestParseJsonPath(t *testing.T) {
+	tests := []struct {
+		path        string
+		expected    []jsonPathSegment
+		expectedErr string
+	}{
+		{path: "", expected: nil},
+		{path: "Records[*]", expected: []jsonPathSegment{{key: "Records"}, {wildcard: true}}},
+		{path: "[*]", expected: []jsonPathSegment{{wildcard: true}}},
+		{path: "data.items[*][*]", expected: []jsonPathSegment{{key: "data"}, {key: "items"}, {wildcard: true}, {wildcard: true}}},
+		{path: "data.event", expected: []jsonPathSegment{{key: "data"}, {key: "event"}}},
+		{path: "Records[0]", expectedErr: "only [*] is supported"},
+		{path: "data..items", expectedErr: "empty key"},
+		{path: "data.[*]", expectedErr: "empty key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			segments, err := parseJsonPath(tt.path)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJsonPath() error = %v", err)
+			}
+			if !reflect.DeepEqual(segments, tt.expected) {
+				t.Errorf("expected segments %+v, got %+v", tt.expected, segments)
+			}
+		})
+	}
+}
+
+func TestReadJsonRecords(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		data        string
+		expected    []string
+		expectedErr string
+	}{
+		{
+			name:     "whole document",
+			data:     `{"a": 1, "b": {"c": [1, 2]}}`,
+			expected: []string{`{"a": 1, "b": {"c": [1, 2]}}`},
+		},
+		{
+			name:     "concatenated documents",
+			data:     "{\"a\": 1}\n{\"a\": 2}\n",
+			expected: []string{`{"a": 1}`, `{"a": 2}`},
+		},
+		{
+			name:     "records array",
+			path:     "Records[*]",
+			data:     `{"Version": "1.0", "Records": [{"a": 1}, {"a": 2}], "Other": {"Records": [{"a": 3}]}}`,
+			expected: []string{`{"a": 1}`, `{"a": 2}`},
+		},
+		{
+			name:     "top level array",
+			path:     "[*]",
+			data:     `[{"a": 1}, {"a": 2}]`,
+			expected: []string{`{"a": 1}`, `{"a": 2}`},
+		},
+		{
+			name:     "nested path",
+			path:     "data.items[*].event",
+			data:     `{"data": {"items": [{"event": {"a": 1}}, {"other": 2}, {"event": {"a": 3}}]}}`,
+			expected: []string{`{"a": 1}`, `{"a": 3}`},
+		},
+		{
+			name: "missing key",
+			path: "Records[*]",
+			data: `{"Version": "1.0"}`,
+		},
+		{
+			name:        "not an array",
+			path:        "Records[*]",
+			data:        `{"Records": {"a": 1}}`,
+			expectedErr: "expected an array",
+		},
+		{
+			name:        "truncated",
+			path:        "Records[*]",
+			data:        `{"Records": [{"a": 1}, {"a": 2}`,
+			expected:    []string{`{"a": 1}`, `{"a": 2}`},
+			expectedErr: "unexpected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := parseJsonPath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var records []string
+			err = readJsonRecords(strings.NewReader(tt.data), path, func(record json.RawMessage) error {
+				records = append(records, string(record))
+				return nil
+			})
+			if tt.expectedErr == "" && err != nil {
+				t.Fatalf("readJsonRecords() error = %v", err)
+			}
+			if tt.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectedErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+			}
+			if !reflect.DeepEqual(records, tt.expected) {
+				t.Errorf("expected records %q, got %q", tt.expected, records)
+			}
+		})
+	}
+}
+
+func TestJsonRecordFields(t *testing.T) {
+	fields, ok := jsonRecordFields(json.RawMessage(`{"s": "text", "n": 1.50, "b": true, "z": null, "o": {"a": [1, 2]}}`))
+	if !ok {
+		t.Fatal("expected the record to be an object")
+	}
+	expected := map[string]string{"s": "text", "n": "1.50", "b": "true", "o": `{"a":[1,2]}`}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected fields %v, got %v", expected, fields)
+	}
+
+	for _, record := range []string{`[1, 2]`, `"text"`, `null`} {
+		if _, ok := jsonRecordFields(json.RawMessage(record)); ok {
+			t.Errorf("expected %s not to be an object", record)
+		}
+	}
+}
+
+func TestJsonLoader_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.json.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	_, _ = gz.Write([]byte(`{"Records": [{"eventName": "A"}, "not an object", {"eventName": "B"}]}`))
+	_ = gz.Close()
+	_ = f.Close()
+
+	rows, err := loadTestJson(NewJsonLoader("Records[*]"), path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	expected := []any{
+		map[string]string{"eventName": "A"},
+		errJsonRecordNotObject,
+		map[string]string{"eventName": "B"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, rows)
+	}
+}
+
+func TestJsonLoader_CompressedFiles(t *testing.T) {
+	dir := t.TempDir()
+	compress := func(w func(io.Writer) io.WriteCloser, data string) []byte {
+		var buf bytes.Buffer
+		cw := w(&buf)
+		_, _ = io.WriteString(cw, data)
+		_ = cw.Close()
+		return buf.Bytes()
+	}
+	// the standard library has no bzip2 writer, so the bzip2 file is checked in
+	bzip2Data, err := os.ReadFile("test_data/export.json.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"export.json.bz2": bzip2Data,
+		"export.json.xz": compress(func(w io.Writer) io.WriteCloser {
+			xzWriter, _ := xz.NewWriter(w)
+			return xzWriter
+		}, `{"Records": [{"eventName": "xz"}]}`),
+		"export.json.lz4": compress(func(w io.Writer) io.WriteCloser {
+			return lz4.NewWriter(w)
+		}, `{"Records": [{"eventName": "lz4"}]}`),
+		// the compression is detected from the magic bytes if the file does not have a compressed extension
+		"export.json.1": compress(func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		}, `{"Records": [{"eventName": "gzip"}]}`),
+	}
+	expected := map[string]string{"export.json.bz2": "bz2", "export.json.xz": "xz", "export.json.lz4": "lz4", "export.json.1": "gzip"}
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := loadTestJson(NewJsonLoader("Records[*]"), path)
+		if err != nil {
+			t.Fatalf("%s: Load() error = %v", name, err)
+		}
+		if !reflect.DeepEqual(rows, []any{map[string]string{"eventName": expected[name]}}) {
+			t.Errorf("%s: unexpected rows %v", name, rows)
+		}
+	}
+}
+
+func TestJsonLoader_Streaming(t *testing.T) {
+	const recordCount = 100000
+
+	// the artifact opener streams a large array - the records are loaded as the array is written
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(pw, `{"Records": [`)
+		for i := range recordCount {
+			if i > 0 {
+				_, _ = io.WriteString(pw, ",")
+			}
+			_, _ = fmt.Fprintf(pw, `{"id": %d, "padding": "%s"}`, i, strings.Repeat("x", 100))
+		}
+		_, _ = io.WriteString(pw, `]}`)
+		_ = pw.Close()
+	}()
+	loader := NewJsonLoader("Records[*]")
+	loader.SetArtifactOpener(func(*types.DownloadedArtifactInfo) (io.ReadCloser, error) {
+		return pr, nil
+	})
+
+	rows, err := loadTestJson(loader, "export.json")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(rows) != recordCount || rows[recordCount-1].(map[string]string)["id"] != fmt.Sprintf("%d", recordCount-1) {
+		t.Errorf("expected %d records, got %d", recordCount, len(rows))
+	}
+}
+
+func TestJsonLoader_InvalidDocument(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte(`[{"a": 1}, {"a": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := loadTestJson(NewJsonLoader("[*]"), path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	// the records before the error are loaded, and the error is sent as a row error
+	if len(rows) != 2 || !reflect.DeepEqual(rows[0], map[string]string{"a": "1"}) {
+		t.Fatalf("expected a record and an error, got %v", rows)
+	}
+	if err, ok := rows[1].(error); !ok || !strings.Contains(err.Error(), "error decoding JSON") {
+		t.Errorf("expected a decoding error, got %v", rows[1])
+	}
+}
+
+func TestJson_Validate(t *testing.T) {
+	valid := "Records[*]"
+	if err := (&Json{RecordsPath: &valid}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	invalid := "Records[1]"
+	if err := (&Json{RecordsPath: &invalid}).Validate(); err == nil || !strings.Contains(err.Error(), "invalid records_path") {
+		t.Errorf("expected invalid records_path error, got %v", err)
+	}
+}
+
+func loadTestJson(loader *JsonLoader, path string) ([]any, error) {
+	info := types.NewDownloadedArtifactInfo(&types.ArtifactInfo{Name: path}, path, 0)
+	dataChan := make(chan *types.RowData)
+	if err := loader.Load(context.Background(), info, dataChan); err != nil {
+		return nil, err
+	}
+	var rows []any
+	for data := range dataChan {
+		rows = append(rows, data.Data)
+	}
+	return rows, nil
+}
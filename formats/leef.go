@@ -0,0 +1,316 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const LeefFormatIdentifier = "leef"
+
+// the columns of the LEEF header fields
+var leefHeaderColumns = []string{"leef_version", "device_vendor", "device_product", "device_version", "event_id"}
+
+// DefaultLeef is the default LEEF format - this is exported by the core plugin
+var DefaultLeef = &Leef{
+	Name:        "default",
+	Description: "IBM Log Event Extended Format (LEEF)",
+}
+
+// Leef is a format for IBM Log Event Extended Format (LEEF) 1.0 and 2.0 events, e.g.
+// `LEEF:2.0|Vendor|Product|1.0|Login|^|src=10.0.0.1^usrName=alice^devTime=1700000000000`
+// The columns are:
+//   - leef_version, device_vendor, device_product, device_version and event_id (the header)
+//   - a column for each attribute - devTime is converted to RFC 3339 (using devTimeFormat if it is set)
+//   - syslog_timestamp, syslog_hostname etc. if the event follows a syslog header
+//
+// The attributes are separated by a tab, or by the delimiter in the LEEF 2.0 header. Attributes which are separated
+// by spaces rather than tabs (as sent by some devices) are parsed in the same way as CEF extension fields
+type Leef struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+	// the time zone of timestamps which do not include one, e.g. "Europe/London" (default "UTC")
+	Timezone *string `hcl:"timezone,optional"`
+}
+
+func NewLeef() formats.Format {
+	return &Leef{}
+}
+
+func (l *Leef) Validate() error {
+	_, err := timezoneLocation(l.Timezone)
+	return err
+}
+
+// GetName returns the name of this format instance
+func (l *Leef) GetName() string {
+	return l.Name
+}
+
+// SetName sets the name of this format instance
+func (l *Leef) SetName(name string) {
+	l.Name = name
+}
+
+// GetDescription returns the description of this format instance
+func (l *Leef) GetDescription() string {
+	return l.Description
+}
+
+// GetProperties returns the format properties as a string map - used for introspection
+func (l *Leef) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	if l.Timezone != nil {
+		properties["timezone"] = *l.Timezone
+	}
+	return properties
+}
+
+// Identifier returns the format type identifier
+func (l *Leef) Identifier() string {
+	return LeefFormatIdentifier
+}
+
+func (l *Leef) GetRegex() (string, error) {
+	// the LEEF format does not support regex
+	return "N/A", nil
+}
+
+func (l *Leef) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	location, err := timezoneLocation(l.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &LeefMapper{parser: &securityEventParser{location: location}}, nil
+}
+
+// LeefMapper maps a LEEF event to a DynamicRow
+type LeefMapper struct {
+	parser *securityEventParser
+}
+
+func (m *LeefMapper) Identifier() string {
+	return "leef_mapper"
+}
+
+func (m *LeefMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var line string
+	switch data := a.(type) {
+	case string:
+		line = data
+	case []byte:
+		line = string(data)
+	default:
+		return nil, fmt.Errorf("expected string, got %T", a)
+	}
+
+	fields, err := m.parser.parseLeef(line)
+	if err != nil {
+		return nil, err
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from LEEF event: %w", err)
+	}
+	return row, nil
+}
+
+// parseLeef parses a LEEF event, which may follow a syslog header, into a map of fields
+func (p *securityEventParser) parseLeef(line string) (map[string]string, error) {
+	start := strings.Index(line, "LEEF:")
+	if start == -1 {
+		return nil, fmt.Errorf("invalid LEEF event: missing LEEF header")
+	}
+	header, attributes, ok := splitSecurityEventHeader(line[start+len("LEEF:"):], len(leefHeaderColumns))
+	if !ok {
+		return nil, fmt.Errorf("invalid LEEF header: expected %d fields", len(leefHeaderColumns))
+	}
+	attributes = strings.TrimRight(attributes, "\r\n")
+
+	// a LEEF 2.0 header may have the attribute delimiter as an additional field
+	delimiter := "\t"
+	if strings.HasPrefix(header[0], "2") {
+		if field, after, ok := strings.Cut(attributes, "|"); ok {
+			if d, ok := parseLeefDelimiter(field); ok {
+				delimiter, attributes = d, after
+			}
+		}
+	}
+
+	var fields map[string]string
+	var err error
+	if delimiter == "\t" && !strings.Contains(attributes, "\t") && strings.Count(attributes, "=") > 1 {
+		fields, err = parseCefExtension(attributes)
+	} else {
+		fields, err = parseLeefAttributes(attributes, delimiter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if devTime, ok := fields["devTime"]; ok {
+		if timestamp, ok := p.parseLeefDevTime(devTime, fields["devTimeFormat"]); ok {
+			fields["devTime"] = timestamp
+		}
+	}
+
+	for i, column := range leefHeaderColumns {
+		fields[column] = strings.TrimSpace(header[i])
+	}
+	p.parsePrefix(line[:start], fields)
+	return fields, nil
+}
+
+// parseLeefDelimiter parses the delimiter field of a LEEF 2.0 header - a single character, or a hex character code
+// (e.g. "x5E" or "0x5E")
+// it returns false if the field is not a delimiter (the delimiter field is optional)
+func parseLeefDelimiter(field string) (string, bool) {
+	switch {
+	case field == "":
+		return "\t", true
+	case len([]rune(field)) == 1:
+		return field, true
+	}
+	hex, ok := strings.CutPrefix(strings.TrimPrefix(strings.ToLower(field), "0"), "x")
+	if !ok || len(hex) == 0 || len(hex) > 4 {
+		return "", false
+	}
+	code, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || code == 0 {
+		return "", false
+	}
+	return string(rune(code)), true
+}
+
+// parseLeefAttributes parses the key=value attributes of a LEEF event, separated by the delimiter
+// text between delimiters which is not a key=value pair is assumed to be part of the previous value
+func parseLeefAttributes(text, delimiter string) (map[string]string, error) {
+	fields := make(map[string]string)
+	var previous string
+	for _, attribute := range strings.Split(text, delimiter) {
+		key, value, ok := strings.Cut(attribute, "=")
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			if previous == "" {
+				if strings.TrimSpace(attribute) == "" {
+					continue
+				}
+				return nil, fmt.Errorf("invalid LEEF attributes: expected key=value pairs")
+			}
+			fields[previous] += delimiter + attribute
+			continue
+		}
+		fields[key] = value
+		previous = key
+	}
+	return fields, nil
+}
+
+// parseLeefDevTime parses the devTime attribute, using the devTimeFormat attribute (a Java SimpleDateFormat pattern)
+// if it is set
+func (p *securityEventParser) parseLeefDevTime(value, format string) (string, bool) {
+	if format == "" {
+		return p.parseTimestamp(value)
+	}
+	layout, err := javaDateLayout(format)
+	if err != nil {
+		return "", false
+	}
+	t, err := time.ParseInLocation(layout, value, p.location)
+	if err != nil {
+		return "", false
+	}
+	if t.Year() == 0 {
+		t = inferSyslogYear(t, time.Now())
+	}
+	return t.Format(time.RFC3339Nano), true
+}
+
+// javaDateLayout converts a Java SimpleDateFormat pattern (e.g. "MMM dd yyyy HH:mm:ss.SSS zzz") to a Go time layout
+func javaDateLayout(format string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); {
+		c := format[i]
+		// quoted literal text - '' is a quote, both in and outside quoted text
+		if c == '\'' {
+			if i+1 < len(format) && format[i+1] == '\'' {
+				sb.WriteByte('\'')
+				i += 2
+				continue
+			}
+			for i++; ; i++ {
+				if i >= len(format) {
+					return "", fmt.Errorf("unterminated quote in date format")
+				}
+				if format[i] == '\'' {
+					if i+1 < len(format) && format[i+1] == '\'' {
+						sb.WriteByte('\'')
+						i++
+						continue
+					}
+					break
+				}
+				sb.WriteByte(format[i])
+			}
+			i++
+			continue
+		}
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+
+		count := 1
+		for i+count < len(format) && format[i+count] == c {
+			count++
+		}
+		i += count
+		var layout string
+		switch c {
+		case 'y':
+			layout = "2006"
+			if count == 2 {
+				layout = "06"
+			}
+		case 'M':
+			layout = [...]string{"1", "01", "Jan", "January"}[min(count, 4)-1]
+		case 'd':
+			layout = [...]string{"2", "02"}[min(count, 2)-1]
+		case 'H':
+			layout = "15"
+		case 'h':
+			layout = [...]string{"3", "03"}[min(count, 2)-1]
+		case 'm':
+			layout = [...]string{"4", "04"}[min(count, 2)-1]
+		case 's':
+			layout = [...]string{"5", "05"}[min(count, 2)-1]
+		case 'S':
+			// fractional seconds - the preceding '.' is part of the layout
+			layout = strings.Repeat("0", count)
+		case 'a':
+			layout = "PM"
+		case 'E':
+			layout = "Mon"
+			if count >= 4 {
+				layout = "Monday"
+			}
+		case 'z':
+			layout = "MST"
+		case 'Z':
+			layout = "-0700"
+		case 'X':
+			layout = [...]string{"Z07", "Z0700", "Z07:00"}[min(count, 3)-1]
+		default:
+			return "", fmt.Errorf("unsupported date format character %q", c)
+		}
+		sb.WriteString(layout)
+	}
+	return sb.String(), nil
+}
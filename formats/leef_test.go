@@ -0,0 +1,168 @@
+package formats
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLeefMapper_Map(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		expected    map[string]string
+		expectedErr string
+	}{
+		{
+			name: "leef 1.0",
+			line: "LEEF:1.0|Microsoft|MSExchange|4.0 SP1|15345|src=192.0.2.0\tdst=172.50.123.1\tsev=5\tcat=anomaly\tmsg=user logged in",
+			expected: map[string]string{
+				"leef_version":   "1.0",
+				"device_vendor":  "Microsoft",
+				"device_product": "MSExchange",
+				"device_version": "4.0 SP1",
+				"event_id":       "15345",
+				"src":            "192.0.2.0",
+				"dst":            "172.50.123.1",
+				"sev":            "5",
+				"msg":            "user logged in",
+			},
+		},
+		{
+			name: "leef 2.0 with delimiter character",
+			line: "LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.1.8^dst=10.0.0.5^sev=5^srcPort=81",
+			expected: map[string]string{
+				"leef_version": "2.0",
+				"event_id":     "41",
+				"src":          "10.0.1.8",
+				"srcPort":      "81",
+			},
+		},
+		{
+			name: "leef 2.0 with hex delimiter",
+			line: "LEEF:2.0|Vendor|Product|1.0|Login|x7C|usrName=alice|role=admin",
+			expected: map[string]string{
+				"usrName": "alice",
+				"role":    "admin",
+			},
+		},
+		{
+			name: "leef 2.0 without delimiter",
+			line: "LEEF:2.0|Vendor|Product|1.0|Login|usrName=alice\trole=admin",
+			expected: map[string]string{
+				"usrName": "alice",
+				"role":    "admin",
+			},
+		},
+		{
+			name: "space separated attributes",
+			line: "LEEF:1.0|Vendor|Product|1.0|Login|usrName=alice smith role=admin",
+			expected: map[string]string{
+				"usrName": "alice smith",
+				"role":    "admin",
+			},
+		},
+		{
+			name: "value containing the delimiter",
+			line: "LEEF:2.0|Vendor|Product|1.0|Login|^|msg=a^b^role=admin",
+			expected: map[string]string{
+				"msg":  "a^b",
+				"role": "admin",
+			},
+		},
+		{
+			name: "dev time with format",
+			line: "LEEF:1.0|Vendor|Product|1.0|Login|devTime=Nov 14 2023 22:13:20.123 +0100\tdevTimeFormat=MMM dd yyyy HH:mm:ss.SSS Z",
+			expected: map[string]string{
+				"devTime": "2023-11-14T22:13:20.123+01:00",
+			},
+		},
+		{
+			name: "dev time in milliseconds",
+			line: "LEEF:1.0|Vendor|Product|1.0|Login|devTime=1700000000000",
+			expected: map[string]string{
+				"devTime": "2023-11-14T22:13:20Z",
+			},
+		},
+		{
+			name: "syslog prefix",
+			line: "<13>Jan 18 11:07:53 192.168.1.1 LEEF:1.0|QRadar|QRM|1.0|NEW_PORT_DISCOVERD|src=172.5.6.67\tdst=172.50.123.1",
+			expected: map[string]string{
+				"syslog_severity_name": "notice",
+				"syslog_hostname":      "192.168.1.1",
+				"dst":                  "172.50.123.1",
+			},
+		},
+		{
+			name:        "not leef",
+			line:        "CEF:0|Vendor|Product|1.0|1|Event|5|",
+			expectedErr: "missing LEEF header",
+		},
+		{
+			name:        "invalid attributes",
+			line:        "LEEF:1.0|Vendor|Product|1.0|Login|no pairs here",
+			expectedErr: "invalid LEEF attributes",
+		},
+	}
+	mapper, err := (&Leef{}).GetMapper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := mapper.Map(context.Background(), tt.line)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Map() error = %v", err)
+			}
+			fields := make(map[string]string)
+			for key := range tt.expected {
+				if value, ok := row.GetSourceValue(key); ok {
+					fields[key] = value
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.expected) {
+				t.Errorf("expected fields %q, got %q", tt.expected, fields)
+			}
+		})
+	}
+}
+
+func TestJavaDateLayout(t *testing.T) {
+	tests := []struct {
+		format      string
+		expected    string
+		expectedErr string
+	}{
+		{format: "MMM dd yyyy HH:mm:ss.SSS zzz", expected: "Jan 02 2006 15:04:05.000 MST"},
+		{format: "yyyy-MM-dd'T'HH:mm:ssXXX", expected: "2006-01-02T15:04:05Z07:00"},
+		{format: "EEE, d MMMM yy h:mm a", expected: "Mon, 2 January 06 3:04 PM"},
+		{format: "HH 'o''clock'", expected: "15 o'clock"},
+		{format: "yyyy-MM-dd ''", expected: "2006-01-02 '"},
+		{format: "dd/MM/yyyy kk:mm", expectedErr: "unsupported date format character 'k'"},
+		{format: "yyyy 'unterminated", expectedErr: "unterminated quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			layout, err := javaDateLayout(tt.format)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("javaDateLayout() error = %v", err)
+			}
+			if layout != tt.expected {
+				t.Errorf("expected layout %q, got %q", tt.expected, layout)
+			}
+		})
+	}
+}
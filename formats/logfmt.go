@@ -0,0 +1,308 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const LogfmtFormatIdentifier = "logfmt"
+
+// the type hints supported by the logfmt format - the value of a key with a type hint is converted to that type
+const (
+	LogfmtTypeString  = "string"
+	LogfmtTypeInteger = "integer"
+	LogfmtTypeFloat   = "float"
+	// true/false, yes/no, on/off, 1/0 (case insensitive)
+	LogfmtTypeBoolean = "boolean"
+	// a Go duration (e.g. "1.5s" or "24ms"), converted to milliseconds
+	LogfmtTypeDuration = "duration"
+)
+
+var logfmtTypes = []string{LogfmtTypeString, LogfmtTypeInteger, LogfmtTypeFloat, LogfmtTypeBoolean, LogfmtTypeDuration}
+
+// the value of a bare key (a key with no value) if bare_key_value is not set
+const defaultLogfmtBareKeyValue = "true"
+
+// DefaultLogfmt is the default logfmt format - this is exported by the core plugin
+var DefaultLogfmt = &Logfmt{
+	Name:        "default",
+	Description: "Logfmt (key=value) format",
+}
+
+// Logfmt is a format for logfmt lines, e.g. `level=info msg="request complete" status=200 duration=24ms`
+// Each key is a column - quoted values may contain spaces and escaped quotes, and a key with no value (a bare key)
+// has the value of bare_key_value. If a key appears more than once, the last value is used
+type Logfmt struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+	// if set, only these keys are included as columns
+	Keys []string `hcl:"keys,optional"`
+	// type hints for the values of keys, e.g. { status = "integer", duration = "duration" }
+	// a value which can not be converted to its type is a row error
+	Types map[string]string `hcl:"types,optional"`
+	// the value of a bare key (default "true")
+	BareKeyValue *string `hcl:"bare_key_value,optional"`
+}
+
+func NewLogfmt() formats.Format {
+	return &Logfmt{}
+}
+
+func (l *Logfmt) Validate() error {
+	for _, key := range l.Keys {
+		if key == "" {
+			return fmt.Errorf("keys can not contain an empty key")
+		}
+	}
+	for key, typeHint := range l.Types {
+		if !slices.Contains(logfmtTypes, typeHint) {
+			return fmt.Errorf("invalid type %s for key %s: must be one of %s", typeHint, key, strings.Join(logfmtTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// GetName returns the name of this format instance
+func (l *Logfmt) GetName() string {
+	return l.Name
+}
+
+// SetName sets the name of this format instance
+func (l *Logfmt) SetName(name string) {
+	l.Name = name
+}
+
+// GetDescription returns the description of this format instance
+func (l *Logfmt) GetDescription() string {
+	return l.Description
+}
+
+// GetProperties returns the format properties as a string map - used for introspection
+func (l *Logfmt) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	if len(l.Keys) > 0 {
+		properties["keys"] = strings.Join(l.Keys, ", ")
+	}
+	for key, typeHint := range l.Types {
+		properties[fmt.Sprintf("type: %s", key)] = typeHint
+	}
+	if l.BareKeyValue != nil {
+		properties["bare_key_value"] = *l.BareKeyValue
+	}
+	return properties
+}
+
+// Identifier returns the format type identifier
+func (l *Logfmt) Identifier() string {
+	return LogfmtFormatIdentifier
+}
+
+func (l *Logfmt) GetRegex() (string, error) {
+	// the logfmt format does not support regex
+	return "N/A", nil
+}
+
+func (l *Logfmt) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	bareKeyValue := defaultLogfmtBareKeyValue
+	if l.BareKeyValue != nil {
+		bareKeyValue = *l.BareKeyValue
+	}
+	var keys map[string]struct{}
+	if len(l.Keys) > 0 {
+		keys = make(map[string]struct{}, len(l.Keys))
+		for _, key := range l.Keys {
+			keys[key] = struct{}{}
+		}
+	}
+	return &LogfmtMapper{
+		keys:         keys,
+		types:        maps.Clone(l.Types),
+		bareKeyValue: bareKeyValue,
+	}, nil
+}
+
+// LogfmtMapper maps a logfmt line to a DynamicRow
+type LogfmtMapper struct {
+	// the keys to include (all keys are included if this is nil)
+	keys         map[string]struct{}
+	types        map[string]string
+	bareKeyValue string
+}
+
+func (m *LogfmtMapper) Identifier() string {
+	return "logfmt_mapper"
+}
+
+func (m *LogfmtMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var line string
+	switch data := a.(type) {
+	case string:
+		line = data
+	case []byte:
+		line = string(data)
+	default:
+		return nil, fmt.Errorf("expected string, got %T", a)
+	}
+
+	fields := make(map[string]string)
+	err := parseLogfmt(line, func(key string, value *string) error {
+		if m.keys != nil {
+			if _, ok := m.keys[key]; !ok {
+				return nil
+			}
+		}
+		if value == nil {
+			fields[key] = m.bareKeyValue
+			return nil
+		}
+		converted, err := convertLogfmtValue(*value, m.types[key])
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		fields[key] = converted
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from logfmt line: %w", err)
+	}
+	return row, nil
+}
+
+// parseLogfmt parses a logfmt line, calling fn with each key and its value (nil for a bare key)
+func parseLogfmt(line string, fn func(key string, value *string) error) error {
+	for i := 0; i < len(line); {
+		// skip the whitespace between pairs
+		if isLogfmtSpace(line[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(line) && !isLogfmtSpace(line[i]) && line[i] != '=' && line[i] != '"' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return fmt.Errorf("unexpected %q in logfmt line", line[i])
+		}
+		if i == len(line) || line[i] != '=' {
+			if i < len(line) && line[i] == '"' {
+				return fmt.Errorf("unexpected '\"' in logfmt line")
+			}
+			// a bare key
+			if err := fn(key, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// skip the '='
+		i++
+		var value string
+		if i < len(line) && line[i] == '"' {
+			end, err := logfmtQuotedValueEnd(line, i)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			value = unquoteLogfmtValue(line[i:end])
+			i = end
+		} else {
+			start = i
+			for i < len(line) && !isLogfmtSpace(line[i]) {
+				i++
+			}
+			value = line[start:i]
+		}
+		if err := fn(key, &value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logfmtQuotedValueEnd returns the index following the closing quote of the quoted value starting at start
+func logfmtQuotedValueEnd(line string, start int) (int, error) {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			// skip the escaped character
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted value")
+}
+
+// unquoteLogfmtValue removes the quotes from a quoted value, unescaping any escape sequences
+// if the value is not a valid Go quoted string, the backslash is removed from each escaped character
+func unquoteLogfmtValue(quoted string) string {
+	if value, err := strconv.Unquote(quoted); err == nil {
+		return value
+	}
+	var sb strings.Builder
+	inner := quoted[1 : len(quoted)-1]
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+		}
+		sb.WriteByte(inner[i])
+	}
+	return sb.String()
+}
+
+func isLogfmtSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// convertLogfmtValue converts the value to the given type hint, returning it as a string
+// (the error does not include the value, so the row errors for an artifact are aggregated)
+func convertLogfmtValue(value, typeHint string) (string, error) {
+	switch typeHint {
+	case LogfmtTypeInteger:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("expected an integer")
+		}
+		return strconv.FormatInt(i, 10), nil
+	case LogfmtTypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("expected a float")
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case LogfmtTypeBoolean:
+		switch strings.ToLower(value) {
+		case "true", "yes", "on", "1", "t", "y":
+			return "true", nil
+		case "false", "no", "off", "0", "f", "n":
+			return "false", nil
+		}
+		return "", fmt.Errorf("expected a boolean")
+	case LogfmtTypeDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return "", fmt.Errorf("expected a duration")
+		}
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), nil
+	default:
+		return value, nil
+	}
+}
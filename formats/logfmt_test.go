@@ -0,0 +1,123 @@
+package formats
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogfmtMapper_Map(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      Logfmt
+		line        string
+		expected    map[string]string
+		expectedErr string
+	}{
+		{
+			name:     "simple",
+			line:     `level=info msg="request complete" status=200`,
+			expected: map[string]string{"level": "info", "msg": "request complete", "status": "200"},
+		},
+		{
+			name:     "escaped quotes",
+			line:     `msg="say \"hi\"" path="C:\\temp" empty="" unquoted=a"b`,
+			expected: map[string]string{"msg": `say "hi"`, "path": `C:\temp`, "empty": "", "unquoted": `a"b`},
+		},
+		{
+			name:     "invalid escape sequence",
+			line:     `msg="bad \q escape"`,
+			expected: map[string]string{"msg": "bad q escape"},
+		},
+		{
+			name:     "bare keys and empty values",
+			line:     "debug  key= \tother=1 trailing",
+			expected: map[string]string{"debug": "true", "key": "", "other": "1", "trailing": "true"},
+		},
+		{
+			name:     "bare key value",
+			format:   Logfmt{BareKeyValue: stringPtr("")},
+			line:     "debug level=info",
+			expected: map[string]string{"debug": "", "level": "info"},
+		},
+		{
+			name:     "duplicate keys",
+			line:     "a=1 a=2",
+			expected: map[string]string{"a": "2"},
+		},
+		{
+			name:     "heroku router",
+			format:   Logfmt{Keys: []string{"method", "status", "connect", "service"}, Types: map[string]string{"status": "integer", "connect": "duration", "service": "duration"}},
+			line:     `at=info method=GET path="/" host=example.herokuapp.com fwd="1.2.3.4" dyno=web.1 connect=1ms service=1.5s status=200 bytes=1548`,
+			expected: map[string]string{"method": "GET", "status": "200", "connect": "1", "service": "1500"},
+		},
+		{
+			name:     "type hints",
+			format:   Logfmt{Types: map[string]string{"ok": "boolean", "ratio": "float", "name": "string"}},
+			line:     "ok=yes ratio=0.50 name=007",
+			expected: map[string]string{"ok": "true", "ratio": "0.5", "name": "007"},
+		},
+		{
+			name:        "invalid typed value",
+			format:      Logfmt{Types: map[string]string{"status": "integer"}},
+			line:        "status=OK",
+			expectedErr: "invalid value for status: expected an integer",
+		},
+		{
+			name:        "unterminated quote",
+			line:        `msg="incomplete`,
+			expectedErr: "msg: unterminated quoted value",
+		},
+		{
+			name:        "missing key",
+			line:        "a=1 =2",
+			expectedErr: `unexpected '='`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := tt.format.GetMapper()
+			if err != nil {
+				t.Fatalf("GetMapper() error = %v", err)
+			}
+			row, err := mapper.Map(context.Background(), tt.line)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Map() error = %v", err)
+			}
+			fields := make(map[string]string)
+			for key := range tt.expected {
+				if value, ok := row.GetSourceValue(key); ok {
+					fields[key] = value
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.expected) {
+				t.Errorf("expected fields %q, got %q", tt.expected, fields)
+			}
+			// only the allowed keys are included
+			if len(tt.format.Keys) > 0 {
+				if _, ok := row.GetSourceValue("path"); ok {
+					t.Errorf("expected keys not in the allow list to be excluded")
+				}
+			}
+		})
+	}
+}
+
+func TestLogfmt_Validate(t *testing.T) {
+	if err := (&Logfmt{Types: map[string]string{"status": "int"}}).Validate(); err == nil || !strings.Contains(err.Error(), "invalid type int for key status") {
+		t.Errorf("expected invalid type error, got %v", err)
+	}
+	if err := (&Logfmt{Keys: []string{"a", ""}}).Validate(); err == nil {
+		t.Errorf("expected empty key error")
+	}
+	if err := (&Logfmt{Keys: []string{"a"}, Types: map[string]string{"a": "duration"}}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package formats
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	typehelpers "github.com/turbot/go-kit/types"
+)
+
+const (
+	defaultMultilineMaxLines     = 500
+	defaultMultilineMaxBytes     = 1024 * 1024
+	defaultMultilineFlushTimeout = 5 * time.Second
+)
+
+// Multiline configures the grouping of the lines of a log file into multiline records, for example stack traces
+// The first line of each record is identified either by start_pattern, which matches the first line of a record,
+// or by continuation_pattern, which matches the lines which continue a record - exactly one of these must be set
+// The lines of a record are joined by a newline before the record is parsed, so the layout must match across lines
+type Multiline struct {
+	// a regex matching the first line of a record - lines which do not match are appended to the current record
+	StartPattern *string `hcl:"start_pattern,optional"`
+	// a regex matching the lines which continue a record - a line which does not match starts a new record
+	ContinuationPattern *string `hcl:"continuation_pattern,optional"`
+	// if set, the pattern identifies the lines which do NOT match it
+	Negate *bool `hcl:"negate,optional"`
+	// the maximum number of lines in a record (default 500) - a line which would exceed this starts a new record
+	MaxLines *int `hcl:"max_lines,optional"`
+	// the maximum size of a record in bytes (default 1MiB) - a line which would exceed this starts a new record
+	MaxBytes *int `hcl:"max_bytes,optional"`
+	// the time to wait for the next line before the current record is completed, e.g. "500ms" (default "5s")
+	// When a file is followed, the last record of the lines appended since the previous collection is not collected
+	// until the file has not been written to for the flush timeout, so lines written later complete the record.
+	// It also applies to a stream such as a named pipe - otherwise the records of a file are only completed by the
+	// next record or the end of the file
+	FlushTimeout *string `hcl:"flush_timeout,optional"`
+}
+
+func (m *Multiline) Validate() error {
+	if (m.StartPattern == nil) == (m.ContinuationPattern == nil) {
+		return fmt.Errorf("multiline must set exactly one of start_pattern or continuation_pattern")
+	}
+	if _, err := m.pattern(); err != nil {
+		return err
+	}
+	if m.MaxLines != nil && *m.MaxLines < 1 {
+		return fmt.Errorf("multiline max_lines must be at least 1")
+	}
+	if m.MaxBytes != nil && *m.MaxBytes < 1 {
+		return fmt.Errorf("multiline max_bytes must be at least 1")
+	}
+	if m.FlushTimeout != nil {
+		timeout, err := time.ParseDuration(*m.FlushTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid multiline flush_timeout %s: %w", *m.FlushTimeout, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("multiline flush_timeout must be positive")
+		}
+	}
+	return nil
+}
+
+// GetProperties returns the multiline properties as a string map, for inclusion in the format properties
+func (m *Multiline) GetProperties() map[string]string {
+	properties := map[string]string{
+		"multiline: max_lines":     fmt.Sprintf("%d", m.GetMaxLines()),
+		"multiline: max_bytes":     fmt.Sprintf("%d", m.GetMaxBytes()),
+		"multiline: flush_timeout": m.GetFlushTimeout().String(),
+	}
+	if m.StartPattern != nil {
+		properties["multiline: start_pattern"] = *m.StartPattern
+	}
+	if m.ContinuationPattern != nil {
+		properties["multiline: continuation_pattern"] = *m.ContinuationPattern
+	}
+	if m.Negate != nil {
+		properties["multiline: negate"] = fmt.Sprintf("%t", *m.Negate)
+	}
+	return properties
+}
+
+// GetMaxLines returns the maximum number of lines in a record
+func (m *Multiline) GetMaxLines() int {
+	if m.MaxLines == nil {
+		return defaultMultilineMaxLines
+	}
+	return *m.MaxLines
+}
+
+// GetMaxBytes returns the maximum size of a record in bytes
+func (m *Multiline) GetMaxBytes() int {
+	if m.MaxBytes == nil {
+		return defaultMultilineMaxBytes
+	}
+	return *m.MaxBytes
+}
+
+// GetFlushTimeout returns the time to wait for the next line before the current record is completed
+// NOTE: the flush timeout is validated, so an invalid value results in the default
+func (m *Multiline) GetFlushTimeout() time.Duration {
+	if m.FlushTimeout == nil {
+		return defaultMultilineFlushTimeout
+	}
+	timeout, err := time.ParseDuration(*m.FlushTimeout)
+	if err != nil || timeout <= 0 {
+		return defaultMultilineFlushTimeout
+	}
+	return timeout
+}
+
+// pattern compiles the start or continuation pattern
+func (m *Multiline) pattern() (*regexp.Regexp, error) {
+	name, pattern := "start_pattern", typehelpers.SafeString(m.StartPattern)
+	if m.ContinuationPattern != nil {
+		name, pattern = "continuation_pattern", *m.ContinuationPattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multiline %s: %w", name, err)
+	}
+	return re, nil
+}
+
+// recordAssembler groups lines into multiline records
+// Lines are added in order - when a line completes the current record, that record is returned
+type recordAssembler struct {
+	pattern  *regexp.Regexp
+	isStart  bool
+	negate   bool
+	maxLines int
+	maxBytes int
+	lines    []string
+	size     int
+}
+
+func newRecordAssembler(m *Multiline) (*recordAssembler, error) {
+	pattern, err := m.pattern()
+	if err != nil {
+		return nil, err
+	}
+	return &recordAssembler{
+		pattern:  pattern,
+		isStart:  m.StartPattern != nil,
+		negate:   typehelpers.BoolValue(m.Negate),
+		maxLines: m.GetMaxLines(),
+		maxBytes: m.GetMaxBytes(),
+	}, nil
+}
+
+// add adds a line, returning the previous record if the line starts a new record
+func (a *recordAssembler) add(line string) (string, bool) {
+	var record string
+	var complete bool
+	if len(a.lines) > 0 && a.startsRecord(line) {
+		record, complete = a.flush()
+	}
+	a.lines = append(a.lines, line)
+	if len(a.lines) > 1 {
+		// the lines are joined by a newline
+		a.size++
+	}
+	a.size += len(line)
+	return record, complete
+}
+
+// startsRecord returns whether the line starts a new record (the current record is not empty)
+func (a *recordAssembler) startsRecord(line string) bool {
+	// a line which would take the record over the limits starts a new record
+	if len(a.lines) >= a.maxLines || a.size+1+len(line) > a.maxBytes {
+		return true
+	}
+	matched := a.pattern.MatchString(line) != a.negate
+	if a.isStart {
+		return matched
+	}
+	// a line which does not match the continuation pattern starts a new record
+	return !matched
+}
+
+// flush returns the current record (if there is one), and starts a new record
+func (a *recordAssembler) flush() (string, bool) {
+	if len(a.lines) == 0 {
+		return "", false
+	}
+	record := strings.Join(a.lines, "\n")
+	a.lines = a.lines[:0]
+	a.size = 0
+	return record, true
+}
@@ -0,0 +1,188 @@
+package formats
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_loader"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const MultilineLoaderIdentifier = "multiline_loader"
+
+// MultilineLoader is a Loader which groups the lines loaded by another loader into multiline records
+// A record is completed when a line starts a new record, when the record reaches the line or byte limit,
+// or when the artifact has been read. If the artifact is a stream (e.g. a named pipe) rather than a file, a record is
+// also completed when no line is received within the flush timeout - the records of a file do not depend on how
+// quickly it is read. A source which reads a file as it is written (e.g. a followed file) uses LastRecordStart and
+// FlushTimeout to leave the last record to be read later, until the file has not been written to for the flush timeout
+// Any row which is not a line (e.g. an error sent in place of an invalid line) completes the current record and is
+// sent as is
+type MultilineLoader struct {
+	multiline *Multiline
+	// the loader which loads the lines of the artifact - if this is not set, the SDK row loader for the file
+	// extension is used
+	inner artifact_loader.Loader
+}
+
+func NewMultilineLoader(multiline *Multiline) *MultilineLoader {
+	return &MultilineLoader{multiline: multiline}
+}
+
+func (l *MultilineLoader) Identifier() string {
+	return MultilineLoaderIdentifier
+}
+
+// SetInnerLoader sets the loader which loads the lines of the artifact
+// this allows a source which loads the artifact data itself to load the lines of the record
+func (l *MultilineLoader) SetInnerLoader(inner artifact_loader.Loader) {
+	l.inner = inner
+}
+
+// Load implements Loader
+func (l *MultilineLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	assembler, err := newRecordAssembler(l.multiline)
+	if err != nil {
+		return err
+	}
+
+	inner := l.inner
+	if inner == nil {
+		inner = defaultRowLoader(info.LocalName)
+	}
+	lineChan := make(chan *types.RowData)
+	if err := inner.Load(ctx, info, lineChan); err != nil {
+		return err
+	}
+
+	// the flush timeout only applies to a stream - a record in a file is only completed by the lines which follow it
+	stream := isStream(info.LocalName)
+
+	go func() {
+		defer close(dataChan)
+
+		// the timer is nil for a file, so the timeout case is never selected
+		var timer *time.Timer
+		var timeoutChan <-chan time.Time
+		flushTimeout := l.multiline.GetFlushTimeout()
+		if stream {
+			timer = time.NewTimer(flushTimeout)
+			defer timer.Stop()
+			timeoutChan = timer.C
+		}
+		resetTimer := func() {
+			if timer != nil {
+				timer.Reset(flushTimeout)
+			}
+		}
+
+		// send sends the row, unless the context is cancelled
+		send := func(row *types.RowData) {
+			select {
+			case dataChan <- row:
+			case <-ctx.Done():
+			}
+		}
+		sendRecord := func(record string, complete bool) {
+			if complete {
+				send(&types.RowData{Data: record})
+			}
+		}
+
+		for {
+			select {
+			case row, ok := <-lineChan:
+				if !ok {
+					// the artifact has been read - send the last record
+					if ctx.Err() == nil {
+						sendRecord(assembler.flush())
+					}
+					slog.Debug("MultilineLoader Load complete", "path", info.LocalName)
+					return
+				}
+				// NOTE: the inner loader stops sending if the context is cancelled, so keep reading until it closes
+				// the channel, but do not send any more records
+				if ctx.Err() != nil {
+					continue
+				}
+				if line, ok := row.Data.(string); ok {
+					sendRecord(assembler.add(line))
+				} else {
+					sendRecord(assembler.flush())
+					send(row)
+				}
+				resetTimer()
+			case <-timeoutChan:
+				// no line has been received from the stream within the flush timeout - complete the current record
+				sendRecord(assembler.flush())
+				resetTimer()
+			}
+		}
+	}()
+	return nil
+}
+
+// FlushTimeout returns the time to wait for the next line before the current record is completed
+func (l *MultilineLoader) FlushTimeout() time.Duration {
+	return l.multiline.GetFlushTimeout()
+}
+
+// LastRecordStart returns the position of the first line of the last record in the text read from the reader
+// If the text is still being written, the last record may be completed by lines which have not been written yet,
+// so a source which reads the text as it is written can leave this record to be read later
+func (l *MultilineLoader) LastRecordStart(r io.Reader) (int64, error) {
+	assembler, err := newRecordAssembler(l.multiline)
+	if err != nil {
+		return 0, err
+	}
+
+	br := bufio.NewReader(r)
+	var pos, start int64
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			// as for the row loaders, the line ending is not part of the line
+			if _, complete := assembler.add(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")); complete {
+				start = pos
+			}
+			pos += int64(len(line))
+		}
+		if err == io.EOF {
+			return start, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// defaultRowLoader returns the SDK loader which loads the artifact a line at a time, based on the file extension
+// (this is the loader the SDK uses for a row per line artifact if the table does not specify a loader)
+func defaultRowLoader(localName string) artifact_loader.Loader {
+	switch strings.ToLower(filepath.Ext(localName)) {
+	case ".gz":
+		return artifact_loader.NewGzipRowLoader()
+	case ".zst":
+		return artifact_loader.NewZstdRowLoader()
+	case ".zip":
+		return artifact_loader.NewZipRowLoader()
+	default:
+		return artifact_loader.NewFileRowLoader()
+	}
+}
+
+// isStream returns whether the artifact is a stream (e.g. a named pipe) rather than a file, i.e. whether the data may
+// still be being written while it is loaded
+func isStream(localName string) bool {
+	info, err := os.Stat(localName)
+	if err != nil {
+		return false
+	}
+	return info.Mode()&(os.ModeNamedPipe|os.ModeSocket|os.ModeCharDevice) != 0
+}
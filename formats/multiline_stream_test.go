@@ -0,0 +1,35 @@
+//go:build !windows
+
+package formats
+
+import (
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMultilineLoader_StreamFlushTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pipe")
+	if err := syscall.Mkfifo(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewMultilineLoader(&Multiline{StartPattern: stringPtr(`^start`), FlushTimeout: stringPtr("50ms")})
+	loader.SetInnerLoader(&testLineLoader{rows: []any{
+		"start 1", "more 1",
+		// the record is completed once no line is received from the stream within the flush timeout
+		200 * time.Millisecond,
+		"more 2",
+	}})
+
+	records, err := loadTestRecords(loader, path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	expected := []any{"start 1\nmore 1", "more 2"}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected records %q, got %q", expected, records)
+	}
+}
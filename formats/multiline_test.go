@@ -0,0 +1,304 @@
+package formats
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const javaStackTrace = `2024-05-01 10:00:00 ERROR request failed
+java.lang.IllegalStateException: boom
+	at com.example.Handler.handle(Handler.java:42)
+	at com.example.Server.run(Server.java:7)
+2024-05-01 10:00:01 INFO request complete`
+
+func TestRecordAssembler(t *testing.T) {
+	tests := []struct {
+		name      string
+		multiline Multiline
+		lines     []string
+		expected  []string
+	}{
+		{
+			name:      "start pattern",
+			multiline: Multiline{StartPattern: stringPtr(`^\d{4}-`)},
+			lines:     strings.Split(javaStackTrace, "\n"),
+			expected: []string{
+				"2024-05-01 10:00:00 ERROR request failed\njava.lang.IllegalStateException: boom\n\tat com.example.Handler.handle(Handler.java:42)\n\tat com.example.Server.run(Server.java:7)",
+				"2024-05-01 10:00:01 INFO request complete",
+			},
+		},
+		{
+			name:      "continuation pattern",
+			multiline: Multiline{ContinuationPattern: stringPtr(`^(\s+at |java\.)`)},
+			lines:     strings.Split(javaStackTrace, "\n"),
+			expected: []string{
+				"2024-05-01 10:00:00 ERROR request failed\njava.lang.IllegalStateException: boom\n\tat com.example.Handler.handle(Handler.java:42)\n\tat com.example.Server.run(Server.java:7)",
+				"2024-05-01 10:00:01 INFO request complete",
+			},
+		},
+		{
+			name:      "negated start pattern",
+			multiline: Multiline{StartPattern: stringPtr(`^\s`), Negate: boolPtr(true)},
+			lines:     []string{"Traceback (most recent call last):", `  File "app.py", line 1`, "next"},
+			expected:  []string{"Traceback (most recent call last):\n  File \"app.py\", line 1", "next"},
+		},
+		{
+			name:      "negated continuation pattern",
+			multiline: Multiline{ContinuationPattern: stringPtr(`^\d{4}-`), Negate: boolPtr(true)},
+			lines:     strings.Split(javaStackTrace, "\n"),
+			expected: []string{
+				"2024-05-01 10:00:00 ERROR request failed\njava.lang.IllegalStateException: boom\n\tat com.example.Handler.handle(Handler.java:42)\n\tat com.example.Server.run(Server.java:7)",
+				"2024-05-01 10:00:01 INFO request complete",
+			},
+		},
+		{
+			name:      "leading continuation lines",
+			multiline: Multiline{StartPattern: stringPtr(`^start`)},
+			lines:     []string{"orphan 1", "orphan 2", "start", "more"},
+			expected:  []string{"orphan 1\norphan 2", "start\nmore"},
+		},
+		{
+			name:      "max lines",
+			multiline: Multiline{StartPattern: stringPtr(`^start`), MaxLines: intPtr(2)},
+			lines:     []string{"start", "a", "b", "c", "start"},
+			expected:  []string{"start\na", "b\nc", "start"},
+		},
+		{
+			name:      "max bytes",
+			multiline: Multiline{StartPattern: stringPtr(`^start`), MaxBytes: intPtr(10)},
+			lines:     []string{"start", "abcd", "e", "a very long line"},
+			expected:  []string{"start\nabcd", "e", "a very long line"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.multiline.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			assembler, err := newRecordAssembler(&tt.multiline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var records []string
+			for _, line := range tt.lines {
+				if record, ok := assembler.add(line); ok {
+					records = append(records, record)
+				}
+			}
+			if record, ok := assembler.flush(); ok {
+				records = append(records, record)
+			}
+			if !reflect.DeepEqual(records, tt.expected) {
+				t.Errorf("expected records %q, got %q", tt.expected, records)
+			}
+		})
+	}
+}
+
+func TestMultiline_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		multiline   Multiline
+		expectedErr string
+	}{
+		{name: "no pattern", multiline: Multiline{}, expectedErr: "exactly one of"},
+		{name: "both patterns", multiline: Multiline{StartPattern: stringPtr("a"), ContinuationPattern: stringPtr("b")}, expectedErr: "exactly one of"},
+		{name: "invalid pattern", multiline: Multiline{StartPattern: stringPtr("(")}, expectedErr: "invalid multiline start_pattern"},
+		{name: "invalid max lines", multiline: Multiline{StartPattern: stringPtr("a"), MaxLines: intPtr(0)}, expectedErr: "max_lines"},
+		{name: "invalid max bytes", multiline: Multiline{StartPattern: stringPtr("a"), MaxBytes: intPtr(-1)}, expectedErr: "max_bytes"},
+		{name: "invalid flush timeout", multiline: Multiline{StartPattern: stringPtr("a"), FlushTimeout: stringPtr("soon")}, expectedErr: "invalid multiline flush_timeout"},
+		{name: "valid", multiline: Multiline{ContinuationPattern: stringPtr(`^\s`), FlushTimeout: stringPtr("500ms")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.multiline.Validate()
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestMultilineLoader_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(javaStackTrace+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is loaded by the default row loader
+	loader := NewMultilineLoader(&Multiline{StartPattern: stringPtr(`^\d{4}-`)})
+	records, err := loadTestRecords(loader, path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(records) != 2 || !strings.HasSuffix(records[0].(string), "(Server.java:7)") || records[1] != "2024-05-01 10:00:01 INFO request complete" {
+		t.Errorf("unexpected records %q", records)
+	}
+}
+
+func TestMultilineLoader_InnerLoader(t *testing.T) {
+	rowError := errors.New("row contains invalid UTF-16LE sequences")
+	loader := NewMultilineLoader(&Multiline{StartPattern: stringPtr(`^start`), FlushTimeout: stringPtr("50ms")})
+	loader.SetInnerLoader(&testLineLoader{rows: []any{
+		"start 1", "more 1",
+		// the flush timeout does not apply to a file, so a pause in reading does not complete the record
+		100 * time.Millisecond,
+		"more 2",
+		// a row which is not a line completes the current record
+		rowError,
+		"start 3",
+	}})
+
+	records, err := loadTestRecords(loader, "app.log")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	expected := []any{"start 1\nmore 1\nmore 2", rowError, "start 3"}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected records %q, got %q", expected, records)
+	}
+}
+
+func TestMultilineLoader_LastRecordStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected int64
+	}{
+		{name: "empty", text: "", expected: 0},
+		{name: "single record", text: "start 1\nmore 1\n", expected: 0},
+		{name: "several records", text: "start 1\r\nmore 1\r\nstart 2\nmore 2\n", expected: 17},
+		{name: "leading continuation lines", text: "more 0\nstart 1\n", expected: 7},
+	}
+	loader := NewMultilineLoader(&Multiline{StartPattern: stringPtr(`^start`)})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, err := loader.LastRecordStart(strings.NewReader(tt.text))
+			if err != nil {
+				t.Fatalf("LastRecordStart() error = %v", err)
+			}
+			if start != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, start)
+			}
+		})
+	}
+}
+
+func TestMultilineLoader_Cancelled(t *testing.T) {
+	loader := NewMultilineLoader(&Multiline{StartPattern: stringPtr(`^start`)})
+	loader.SetInnerLoader(&testLineLoader{rows: []any{"start 1", "start 2", "start 3", "start 4"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	info := types.NewDownloadedArtifactInfo(&types.ArtifactInfo{Name: "app.log"}, "app.log", 0)
+	dataChan := make(chan *types.RowData)
+	if err := loader.Load(ctx, info, dataChan); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	<-dataChan
+	cancel()
+
+	// once the context is cancelled, the loader must close the channel without sending any more records
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case row, ok := <-dataChan:
+		if ok {
+			t.Fatalf("expected the data channel to be closed, got %v", row.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the data channel was not closed after the context was cancelled")
+	}
+}
+
+func TestGrok_Multiline(t *testing.T) {
+	hclBytes := []byte(`
+layout = "%%{TIMESTAMP_ISO8601:tp_timestamp} %%{LOGLEVEL:level} (?s)%%{GREEDYDATA:message}"
+multiline {
+  start_pattern = "^\\d{4}-"
+  max_lines     = 100
+}
+`)
+	// NOTE: grok patterns are escaped in the hcl
+	formatData := types.NewFormatConfigData(hclBytes, hcl.Range{}, constants.SourceFormatGrok)
+	formatData.Name = "app"
+	format, err := formats.ParseFormat(formatData, map[string]func() formats.Format{constants.SourceFormatGrok: NewGrok})
+	if err != nil {
+		t.Fatalf("ParseFormat() error = %v", err)
+	}
+	grok := format.(*Grok)
+	if grok.Multiline == nil || grok.Multiline.GetMaxLines() != 100 {
+		t.Fatalf("expected multiline with max_lines 100, got %+v", grok.Multiline)
+	}
+	if len(grok.GetSourceOptions()) != 2 {
+		t.Fatal("expected the row per line and multiline loader options")
+	}
+
+	mapper, err := grok.GetMapper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, _ := strings.CutSuffix(javaStackTrace, "\n2024-05-01 10:00:01 INFO request complete")
+	row, err := mapper.Map(context.Background(), record)
+	if err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	if message, _ := row.GetSourceValue("message"); !strings.HasSuffix(message, "(Server.java:7)") {
+		t.Errorf("expected the message to contain the stack trace, got %q", message)
+	}
+}
+
+// testLineLoader is a loader which sends the given rows - a duration sends nothing for that long
+type testLineLoader struct {
+	rows []any
+}
+
+func (l *testLineLoader) Identifier() string {
+	return "test_line_loader"
+}
+
+func (l *testLineLoader) Load(_ context.Context, _ *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	go func() {
+		defer close(dataChan)
+		for _, row := range l.rows {
+			if d, ok := row.(time.Duration); ok {
+				time.Sleep(d)
+				continue
+			}
+			dataChan <- &types.RowData{Data: row}
+		}
+	}()
+	return nil
+}
+
+func loadTestRecords(loader *MultilineLoader, path string) ([]any, error) {
+	info := types.NewDownloadedArtifactInfo(&types.ArtifactInfo{Name: path}, path, 0)
+	dataChan := make(chan *types.RowData)
+	if err := loader.Load(context.Background(), info, dataChan); err != nil {
+		return nil, err
+	}
+	var records []any
+	for data := range dataChan {
+		records = append(records, data.Data)
+	}
+	return records, nil
+}
+
+func stringPtr(s string) *string { return &s }
+func boolPtr(b bool) *bool       { return &b }
+func intPtr(i int) *int          { return &i }
@@ -0,0 +1,100 @@
+package formats
+
+import (
+	"maps"
+
+	"github.com/turbot/tailpipe-plugin-sdk/constants"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// Regex is the SDK regex format, extended to support multiline records
+// this is registered in place of the SDK format, so has the same identifier
+type Regex struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+	// the layout of the log line
+	Layout string `hcl:"layout"`
+
+	// how each artifact is split into records: "line" (the default) - each line is a record, or "file" - each
+	// artifact is a single record
+	ArtifactMode *string `hcl:"artifact_mode,optional"`
+
+	// if set, lines are grouped into multiline records before they are parsed (line mode only)
+	Multiline *Multiline `hcl:"multiline,block"`
+}
+
+func NewRegex() formats.Format {
+	return &Regex{}
+}
+
+func (r *Regex) Validate() error {
+	return validateArtifactMode(r.GetArtifactMode(), r.Multiline)
+}
+
+// GetArtifactMode returns how each artifact is split into records (default "line")
+func (r *Regex) GetArtifactMode() string {
+	if r.ArtifactMode == nil {
+		return ArtifactModeLine
+	}
+	return *r.ArtifactMode
+}
+
+// Identifier returns the format type identifier
+func (r *Regex) Identifier() string {
+	return constants.SourceFormatRegex
+}
+
+// GetName returns the name of this format instance
+func (r *Regex) GetName() string {
+	return r.Name
+}
+
+// SetName sets the name of this format instance
+func (r *Regex) SetName(name string) {
+	r.Name = name
+}
+
+func (r *Regex) GetDescription() string {
+	return r.Description
+}
+
+func (r *Regex) GetRegex() (string, error) {
+	return r.Layout, nil
+}
+
+func (r *Regex) GetProperties() map[string]string {
+	properties := r.sdkFormat().GetProperties()
+	if r.ArtifactMode != nil {
+		properties["artifact_mode"] = *r.ArtifactMode
+	}
+	if r.Multiline != nil {
+		maps.Copy(properties, r.Multiline.GetProperties())
+	}
+	return properties
+}
+
+func (r *Regex) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	mapper, err := r.sdkFormat().GetMapper()
+	if err != nil {
+		return nil, err
+	}
+	return &textMapper{Mapper: mapper}, nil
+}
+
+// GetSourceOptions returns the options for the source, based on the artifact mode - if multiline is set, the lines are
+// loaded by the multiline loader, which groups them into records
+func (r *Regex) GetSourceOptions() []row_source.RowSourceOption {
+	return lineSourceOptions(r.GetArtifactMode(), r.Multiline)
+}
+
+// sdkFormat returns the SDK regex format
+func (r *Regex) sdkFormat() *formats.Regex {
+	return &formats.Regex{
+		Name:        r.Name,
+		Description: r.Description,
+		Layout:      r.Layout,
+	}
+}
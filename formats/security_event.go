@@ -0,0 +1,173 @@
+package formats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the layouts of the timestamps in CEF and LEEF events (other than milliseconds since the epoch)
+// a timestamp without a year is assumed to be in the year which places it before the current time
+var securityEventTimestampLayouts = []struct {
+	layout  string
+	hasYear bool
+}{
+	{"Jan _2 2006 15:04:05.000 MST", true},
+	{"Jan _2 2006 15:04:05 MST", true},
+	{"Jan _2 2006 15:04:05.000", true},
+	{"Jan _2 2006 15:04:05", true},
+	{"Jan _2 15:04:05.000 MST", false},
+	{"Jan _2 15:04:05 MST", false},
+	{"Jan _2 15:04:05.000", false},
+	{"Jan _2 15:04:05", false},
+	{time.RFC3339Nano, true},
+}
+
+// securityEventParser contains the parsing shared by the CEF and LEEF formats
+type securityEventParser struct {
+	// the location of timestamps which do not include a time zone
+	location *time.Location
+}
+
+// parsePrefix parses the text preceding the CEF or LEEF header - if this is a syslog header, its fields are added with
+// the prefix "syslog_" (e.g. syslog_timestamp and syslog_hostname), otherwise it is ignored
+func (p *securityEventParser) parsePrefix(prefix string, fields map[string]string) {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return
+	}
+	syslogFields, err := (&syslogParser{location: p.location}).parse(prefix, time.Now())
+	if err != nil {
+		return
+	}
+	for name, value := range syslogFields {
+		fields["syslog_"+name] = value
+	}
+}
+
+// parseTimestamp parses a CEF or LEEF timestamp, returning it in RFC 3339 format
+// the timestamp is either milliseconds since the epoch or one of securityEventTimestampLayouts
+func (p *securityEventParser) parseTimestamp(value string) (string, bool) {
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(millis).UTC().Format(time.RFC3339Nano), true
+	}
+	for _, l := range securityEventTimestampLayouts {
+		t, err := time.ParseInLocation(l.layout, value, p.location)
+		if err != nil {
+			continue
+		}
+		if !l.hasYear {
+			t = inferSyslogYear(t, time.Now())
+		}
+		return t.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+// splitSecurityEventHeader splits the first count fields of a CEF or LEEF header, which are separated by '|',
+// returning the fields and the text following them
+// '|' and '\' are escaped with a backslash in the header fields
+// it returns false if the header has fewer than count fields
+func splitSecurityEventHeader(text string, count int) ([]string, string, bool) {
+	fields := make([]string, 0, count)
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '\\':
+			if i+1 < len(text) && (text[i+1] == '|' || text[i+1] == '\\') {
+				i++
+			}
+			sb.WriteByte(text[i])
+		case '|':
+			fields = append(fields, sb.String())
+			sb.Reset()
+			if len(fields) == count {
+				return fields, text[i+1:], true
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	// the final header field may not be followed by a '|' if there is no extension
+	if len(fields) == count-1 {
+		return append(fields, sb.String()), "", true
+	}
+	return nil, "", false
+}
+
+// parseCefExtension parses CEF extension fields - space separated key=value pairs
+// A value may contain spaces, so it continues until the next key. '=' and '\' are escaped with a backslash in values,
+// and newlines and carriage returns are escaped as "\n" and "\r"
+func parseCefExtension(text string) (map[string]string, error) {
+	type keyPosition struct {
+		start  int
+		equals int
+	}
+	// find the keys - an unescaped '=' preceded by a key which is at the start of the text or follows a space
+	var keys []keyPosition
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			// skip the escaped character
+			i++
+		case '=':
+			start := i
+			for start > 0 && isCefKeyChar(text[start-1]) {
+				start--
+			}
+			if start < i && (start == 0 || text[start-1] == ' ') {
+				keys = append(keys, keyPosition{start: start, equals: i})
+			}
+		}
+	}
+
+	fields := make(map[string]string, len(keys))
+	firstKey := len(text)
+	if len(keys) > 0 {
+		firstKey = keys[0].start
+	}
+	if strings.TrimSpace(text[:firstKey]) != "" {
+		return nil, fmt.Errorf("invalid CEF extension: expected key=value pairs")
+	}
+	for i, key := range keys {
+		end := len(text)
+		if i+1 < len(keys) {
+			end = keys[i+1].start
+		}
+		fields[text[key.start:key.equals]] = unescapeCefValue(strings.TrimRight(text[key.equals+1:end], " "))
+	}
+	return fields, nil
+}
+
+func isCefKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '.' || c == '[' || c == ']'
+}
+
+// unescapeCefValue unescapes a CEF extension value - a backslash before any other character is kept
+func unescapeCefValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			switch value[i+1] {
+			case '\\', '=', '|':
+				i++
+				sb.WriteByte(value[i])
+				continue
+			case 'n':
+				i++
+				sb.WriteByte('\n')
+				continue
+			case 'r':
+				i++
+				sb.WriteByte('\r')
+				continue
+			}
+		}
+		sb.WriteByte(value[i])
+	}
+	return sb.String()
+}
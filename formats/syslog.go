@@ -0,0 +1,227 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_loader"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const (
+	SyslogFormatIdentifier = "syslog"
+	SyslogLoaderIdentifier = "syslog_loader"
+)
+
+// DefaultSyslog is the default syslog format - this is exported by the core plugin
+var DefaultSyslog = &Syslog{
+	Name:        "default",
+	Description: "Syslog (RFC 3164 and RFC 5424) format",
+}
+
+// Syslog is a format for the lines of syslog files, as written by rsyslog and syslog-ng, in RFC 3164 (BSD) or
+// RFC 5424 format - the format of each line is detected, so a file may contain both
+// The columns are:
+// - priority, facility, facility_name, severity and severity_name (if the line has a PRI)
+// - version (RFC 5424 only)
+// - timestamp (RFC 3339) - a BSD timestamp has no year, so it is assumed to be in the year which places it before the
+// modification time of the file
+// - hostname, app_name, proc_id and msg_id (msg_id is RFC 5424 only)
+// - structured_data (RFC 5424 only), a JSON object mapping each SD-ID to an object of its parameters
+// - message - control characters escaped as '#' followed by 3 octal digits (e.g. "#012") are unescaped
+type Syslog struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+	// the time zone of BSD timestamps, which do not include one, e.g. "Europe/London" (default "UTC")
+	Timezone *string `hcl:"timezone,optional"`
+}
+
+func NewSyslog() formats.Format {
+	return &Syslog{}
+}
+
+func (s *Syslog) Validate() error {
+	_, err := timezoneLocation(s.Timezone)
+	return err
+}
+
+// GetName returns the name of this format instance
+func (s *Syslog) GetName() string {
+	return s.Name
+}
+
+// SetName sets the name of this format instance
+func (s *Syslog) SetName(name string) {
+	s.Name = name
+}
+
+// GetDescription returns the description of this format instance
+func (s *Syslog) GetDescription() string {
+	return s.Description
+}
+
+// GetProperties returns the format properties as a string map - used for introspection
+func (s *Syslog) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	if s.Timezone != nil {
+		properties["timezone"] = *s.Timezone
+	}
+	return properties
+}
+
+// Identifier returns the format type identifier
+func (s *Syslog) Identifier() string {
+	return SyslogFormatIdentifier
+}
+
+func (s *Syslog) GetRegex() (string, error) {
+	// the syslog format does not support regex
+	return "N/A", nil
+}
+
+func (s *Syslog) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	location, err := timezoneLocation(s.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogMapper{parser: &syslogParser{location: location}}, nil
+}
+
+// GetSourceOptions returns the options for the source - each line is a row, loaded by the syslog loader, which adds the
+// modification time of the file to each line
+func (s *Syslog) GetSourceOptions() []row_source.RowSourceOption {
+	return []row_source.RowSourceOption{
+		artifact_source.WithRowPerLine(),
+		artifact_source.WithArtifactLoader(NewSyslogLoader()),
+	}
+}
+
+// timezoneLocation returns the location of the time zone option of a format (UTC if it is not set)
+func timezoneLocation(timezone *string) (*time.Location, error) {
+	if timezone == nil {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %s: %w", *timezone, err)
+	}
+	return location, nil
+}
+
+// SyslogLine is a line of a syslog file, as loaded by the SyslogLoader
+type SyslogLine struct {
+	Line string
+	// the modification time of the file - used to infer the year of BSD timestamps
+	ModTime time.Time
+}
+
+// SyslogLoader is a Loader which sends each line loaded by another loader as a SyslogLine,
+// with the modification time of the file
+type SyslogLoader struct {
+	// the loader which loads the lines of the artifact - if this is not set, the SDK row loader for the file
+	// extension is used
+	inner artifact_loader.Loader
+}
+
+func NewSyslogLoader() *SyslogLoader {
+	return &SyslogLoader{}
+}
+
+func (l *SyslogLoader) Identifier() string {
+	return SyslogLoaderIdentifier
+}
+
+// SetInnerLoader sets the loader which loads the lines of the artifact
+// this allows a source which loads the artifact data itself to load the lines
+func (l *SyslogLoader) SetInnerLoader(inner artifact_loader.Loader) {
+	l.inner = inner
+}
+
+// Load implements Loader
+func (l *SyslogLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	inner := l.inner
+	if inner == nil {
+		inner = defaultRowLoader(info.LocalName)
+	}
+	lineChan := make(chan *types.RowData)
+	if err := inner.Load(ctx, info, lineChan); err != nil {
+		return err
+	}
+
+	modTime := artifactModTime(inner, info)
+	go func() {
+		defer close(dataChan)
+		for row := range lineChan {
+			// any row which is not a line (e.g. an error sent in place of an invalid line) is sent as is
+			if line, ok := row.Data.(string); ok {
+				row = &types.RowData{Data: &SyslogLine{Line: line, ModTime: modTime}, SourceEnrichment: row.SourceEnrichment}
+			}
+			dataChan <- row
+		}
+	}()
+	return nil
+}
+
+// artifactModTime returns the modification time of the artifact
+// if the inner loader knows the modification time (e.g. the artifact is a member of an archive, read directly from the
+// archive), this is used - otherwise it is the modification time of the original file if it exists, or of the local
+// file (a copy of the original file, e.g. a transcoded file or an archive member, has the modification time of the
+// original)
+// if neither can be read, the current time is used
+func artifactModTime(inner artifact_loader.Loader, info *types.DownloadedArtifactInfo) time.Time {
+	if provider, ok := inner.(ArtifactModTimeProvider); ok {
+		if modTime, ok := provider.ArtifactModTime(info); ok {
+			return modTime
+		}
+	}
+	for _, name := range []string{info.Name, info.LocalName} {
+		if fileInfo, err := os.Stat(name); err == nil {
+			return fileInfo.ModTime()
+		}
+	}
+	slog.Debug("unable to read the modification time of the artifact - using the current time", "artifact", info.Name)
+	return time.Now()
+}
+
+// SyslogMapper maps a syslog line to a DynamicRow
+type SyslogMapper struct {
+	parser *syslogParser
+}
+
+func (m *SyslogMapper) Identifier() string {
+	return "syslog_mapper"
+}
+
+func (m *SyslogMapper) Map(_ context.Context, a any, _ ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	var line string
+	// lines which are not loaded by the syslog loader use the current time to infer the year
+	reference := time.Now()
+	switch data := a.(type) {
+	case *SyslogLine:
+		line, reference = data.Line, data.ModTime
+	case string:
+		line = data
+	case []byte:
+		line = string(data)
+	default:
+		return nil, fmt.Errorf("expected string, got %T", a)
+	}
+
+	fields, err := m.parser.parse(line, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	row := &types.DynamicRow{}
+	if err := row.InitialiseFromMap(fields); err != nil {
+		return nil, fmt.Errorf("error initialising row from syslog line: %w", err)
+	}
+	return row, nil
+}
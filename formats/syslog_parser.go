@@ -0,0 +1,299 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the syslog facility names, indexed by facility code
+var syslogFacilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp", "ntp",
+	"security", "console", "solaris-cron", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// the syslog severity names, indexed by severity code
+var syslogSeverityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// the layout of an RFC 3164 (BSD) timestamp, e.g. "Oct  1 22:14:15" - this does not include the year
+const bsdTimestampLayout = "Jan _2 15:04:05"
+
+// a BSD timestamp is assumed to be in the year which places it before the reference time (the modification time of
+// the file) - allowing for this much clock skew or time zone difference
+const syslogYearInferenceSkew = 24 * time.Hour
+
+// syslogParser parses syslog lines in RFC 3164 (BSD) or RFC 5424 format
+type syslogParser struct {
+	// the location of BSD timestamps, which do not include a time zone
+	location *time.Location
+}
+
+// parse parses a syslog line into a map of fields
+// the reference time is used to infer the year of a BSD timestamp
+func (p *syslogParser) parse(line string, reference time.Time) (map[string]string, error) {
+	fields := make(map[string]string)
+	rest := line
+
+	// the PRI is optional in files, e.g. rsyslog does not write it by default
+	hasPri := strings.HasPrefix(rest, "<")
+	if hasPri {
+		end := strings.IndexByte(rest, '>')
+		if end < 2 || end > 4 {
+			return nil, fmt.Errorf("invalid syslog priority")
+		}
+		priority, err := strconv.Atoi(rest[1:end])
+		if err != nil || priority < 0 || priority >= len(syslogFacilityNames)*8 {
+			return nil, fmt.Errorf("invalid syslog priority")
+		}
+		facility, severity := priority/8, priority%8
+		fields["priority"] = strconv.Itoa(priority)
+		fields["facility"] = strconv.Itoa(facility)
+		fields["facility_name"] = syslogFacilityNames[facility]
+		fields["severity"] = strconv.Itoa(severity)
+		fields["severity_name"] = syslogSeverityNames[severity]
+		rest = rest[end+1:]
+	}
+
+	// an RFC 5424 message has a version following the PRI
+	if hasPri {
+		if version, after, ok := cutSyslogVersion(rest); ok {
+			fields["version"] = version
+			if err := p.parseRfc5424(after, fields); err != nil {
+				return nil, err
+			}
+			return fields, nil
+		}
+	}
+	if err := p.parseRfc3164(rest, reference, fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// cutSyslogVersion returns the RFC 5424 version at the start of the text (1-3 digits followed by a space)
+func cutSyslogVersion(text string) (string, string, bool) {
+	version, after, ok := strings.Cut(text, " ")
+	if !ok || len(version) == 0 || len(version) > 3 || version[0] == '0' {
+		return "", "", false
+	}
+	for _, c := range version {
+		if c < '0' || c > '9' {
+			return "", "", false
+		}
+	}
+	return version, after, true
+}
+
+// parseRfc5424 parses the header, structured data and message of an RFC 5424 message (following the version)
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func (p *syslogParser) parseRfc5424(text string, fields map[string]string) error {
+	headerFields := []string{"timestamp", "hostname", "app_name", "proc_id", "msg_id"}
+	for _, name := range headerFields {
+		value, after, ok := strings.Cut(text, " ")
+		if !ok {
+			return fmt.Errorf("invalid RFC 5424 header: missing %s", name)
+		}
+		text = after
+		// the nil value
+		if value == "-" {
+			continue
+		}
+		if name == "timestamp" {
+			timestamp, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return fmt.Errorf("invalid RFC 5424 timestamp")
+			}
+			value = timestamp.Format(time.RFC3339Nano)
+		}
+		fields[name] = value
+	}
+
+	structuredData, text, err := parseStructuredData(text)
+	if err != nil {
+		return err
+	}
+	if structuredData != nil {
+		data, err := json.Marshal(structuredData)
+		if err != nil {
+			return err
+		}
+		fields["structured_data"] = string(data)
+	}
+
+	// the message may start with a byte order mark, indicating it is UTF-8
+	message := strings.TrimPrefix(strings.TrimPrefix(text, " "), "\xef\xbb\xbf")
+	if message != "" {
+		fields["message"] = unescapeSyslogMessage(message)
+	}
+	return nil
+}
+
+// parseStructuredData parses the RFC 5424 structured data at the start of the text, returning the elements as a map
+// of SD-ID to a map of parameters, and the text following the structured data
+func parseStructuredData(text string) (map[string]map[string]string, string, error) {
+	if strings.HasPrefix(text, "-") {
+		return nil, text[1:], nil
+	}
+	if !strings.HasPrefix(text, "[") {
+		return nil, "", fmt.Errorf("invalid RFC 5424 structured data")
+	}
+
+	elements := make(map[string]map[string]string)
+	for strings.HasPrefix(text, "[") {
+		text = text[1:]
+		idEnd := strings.IndexAny(text, " ]")
+		if idEnd <= 0 {
+			return nil, "", fmt.Errorf("invalid RFC 5424 structured data element")
+		}
+		id := text[:idEnd]
+		text = text[idEnd:]
+		params := make(map[string]string)
+		for strings.HasPrefix(text, " ") {
+			text = text[1:]
+			name, after, ok := strings.Cut(text, `="`)
+			if !ok || name == "" {
+				return nil, "", fmt.Errorf("invalid RFC 5424 structured data parameter in %s", id)
+			}
+			value, after, err := cutStructuredDataValue(after)
+			if err != nil {
+				return nil, "", fmt.Errorf("%s: %w", id, err)
+			}
+			params[name] = value
+			text = after
+		}
+		if !strings.HasPrefix(text, "]") {
+			return nil, "", fmt.Errorf("unterminated RFC 5424 structured data element %s", id)
+		}
+		text = text[1:]
+		elements[id] = params
+	}
+	return elements, text, nil
+}
+
+// cutStructuredDataValue returns the parameter value before the closing quote, and the text after it
+// the characters '"', '\' and ']' are escaped with a backslash - a backslash before any other character is kept
+func cutStructuredDataValue(text string) (string, string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '\\':
+			if i+1 < len(text) && strings.IndexByte(`"\]`, text[i+1]) != -1 {
+				i++
+			}
+			sb.WriteByte(text[i])
+		case '"':
+			return sb.String(), text[i+1:], nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated RFC 5424 structured data parameter value")
+}
+
+// parseRfc3164 parses an RFC 3164 (BSD) message (following the PRI, if any)
+// TIMESTAMP HOSTNAME TAG[PID]: MSG
+// As written by rsyslog and syslog-ng, the timestamp may be a BSD timestamp (without a year) or an RFC 3339 timestamp
+func (p *syslogParser) parseRfc3164(text string, reference time.Time, fields map[string]string) error {
+	var timestamp time.Time
+	if len(text) > 0 && text[0] >= '0' && text[0] <= '9' {
+		value, after, _ := strings.Cut(text, " ")
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("invalid syslog timestamp")
+		}
+		timestamp, text = t, after
+	} else {
+		if len(text) < len(bsdTimestampLayout) {
+			return fmt.Errorf("invalid syslog timestamp")
+		}
+		t, err := time.ParseInLocation(bsdTimestampLayout, text[:len(bsdTimestampLayout)], p.location)
+		if err != nil {
+			return fmt.Errorf("invalid syslog timestamp")
+		}
+		timestamp = inferSyslogYear(t, reference)
+		text = strings.TrimPrefix(text[len(bsdTimestampLayout):], " ")
+	}
+	fields["timestamp"] = timestamp.Format(time.RFC3339Nano)
+
+	hostname, after, ok := strings.Cut(text, " ")
+	if !ok || hostname == "" {
+		// the message is just a timestamp and hostname
+		if hostname != "" {
+			fields["hostname"] = hostname
+		}
+		return nil
+	}
+	fields["hostname"] = hostname
+	text = after
+
+	// the tag is the app name, optionally followed by the process id in brackets, and a colon
+	if tag, message, ok := strings.Cut(text, " "); ok || strings.HasSuffix(tag, ":") {
+		if appName, procId, isTag := parseSyslogTag(tag); isTag {
+			fields["app_name"] = appName
+			if procId != "" {
+				fields["proc_id"] = procId
+			}
+			text = message
+		}
+	}
+	if text != "" {
+		fields["message"] = unescapeSyslogMessage(text)
+	}
+	return nil
+}
+
+// parseSyslogTag parses a tag of the form "app:", "app[pid]:" or "app[pid]"
+// it returns false if the text is not a tag (in which case it is part of the message)
+func parseSyslogTag(tag string) (string, string, bool) {
+	tag, hasColon := strings.CutSuffix(tag, ":")
+	if tag == "" {
+		return "", "", false
+	}
+	if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+		return tag[:open], tag[open+1 : len(tag)-1], true
+	}
+	if !hasColon || strings.ContainsAny(tag, "[]") {
+		return "", "", false
+	}
+	return tag, "", true
+}
+
+// inferSyslogYear returns the BSD timestamp in the year which places it before the reference time
+// (allowing for clock skew) - for Feb 29, this is the latest leap year which does so
+func inferSyslogYear(t, reference time.Time) time.Time {
+	reference = reference.In(t.Location())
+	latest := reference.Add(syslogYearInferenceSkew)
+	for year := latest.Year(); year > latest.Year()-8; year-- {
+		candidate := time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		// the date does not exist in this year (i.e. Feb 29 in a non leap year)
+		if candidate.Day() != t.Day() {
+			continue
+		}
+		if !candidate.After(latest) {
+			return candidate
+		}
+	}
+	return time.Date(reference.Year()-1, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// unescapeSyslogMessage replaces the control characters escaped by rsyslog (and the syslog source) as '#' followed by
+// 3 octal digits, e.g. "#012" for a newline, with the original character
+func unescapeSyslogMessage(message string) string {
+	if !strings.Contains(message, "#0") {
+		return message
+	}
+	var sb strings.Builder
+	for i := 0; i < len(message); i++ {
+		if message[i] == '#' && i+4 <= len(message) {
+			if c, err := strconv.ParseUint(message[i+1:i+4], 8, 8); err == nil && c < 0x20 {
+				sb.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(message[i])
+	}
+	return sb.String()
+}
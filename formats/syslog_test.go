@@ -0,0 +1,250 @@
+package formats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+func TestSyslogParser_Parse(t *testing.T) {
+	reference := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		line        string
+		expected    map[string]string
+		expectedErr string
+	}{
+		{
+			name: "rfc 5424",
+			line: `<165>1 2024-03-10T08:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application"][origin ip="192.0.2.1"] ` + "\xef\xbb\xbf" + `An application event`,
+			expected: map[string]string{
+				"priority":        "165",
+				"facility":        "20",
+				"facility_name":   "local4",
+				"severity":        "5",
+				"severity_name":   "notice",
+				"version":         "1",
+				"timestamp":       "2024-03-10T08:14:15.003Z",
+				"hostname":        "mymachine.example.com",
+				"app_name":        "evntslog",
+				"proc_id":         "1234",
+				"msg_id":          "ID47",
+				"structured_data": `{"exampleSDID@32473":{"eventSource":"Application","iut":"3"},"origin":{"ip":"192.0.2.1"}}`,
+				"message":         "An application event",
+			},
+		},
+		{
+			name: "rfc 5424 nil values and escaped parameter",
+			line: `<34>1 2024-03-10T22:14:15+01:00 - su - - [meta text="a \"quoted\" \] value\n"]`,
+			expected: map[string]string{
+				"priority":        "34",
+				"facility_name":   "auth",
+				"severity_name":   "crit",
+				"timestamp":       "2024-03-10T22:14:15+01:00",
+				"app_name":        "su",
+				"structured_data": `{"meta":{"text":"a \"quoted\" ] value\\n"}}`,
+			},
+		},
+		{
+			name: "rfc 5424 without structured data",
+			line: `<13>1 2024-03-10T08:14:15Z host app - - - hello#012world`,
+			expected: map[string]string{
+				"timestamp": "2024-03-10T08:14:15Z",
+				"hostname":  "host",
+				"app_name":  "app",
+				"message":   "hello\nworld",
+			},
+		},
+		{
+			name: "rfc 3164 with priority",
+			line: `<38>Mar  9 22:14:15 mymachine sshd[4123]: Accepted publickey for root`,
+			expected: map[string]string{
+				"priority":      "38",
+				"facility_name": "auth",
+				"severity_name": "info",
+				"timestamp":     "2024-03-09T22:14:15Z",
+				"hostname":      "mymachine",
+				"app_name":      "sshd",
+				"proc_id":       "4123",
+				"message":       "Accepted publickey for root",
+			},
+		},
+		{
+			name: "rfc 3164 without priority",
+			line: `Mar 10 08:00:01 web01 CRON: (root) CMD (run-parts /etc/cron.hourly)`,
+			expected: map[string]string{
+				"timestamp": "2024-03-10T08:00:01Z",
+				"hostname":  "web01",
+				"app_name":  "CRON",
+				"message":   "(root) CMD (run-parts /etc/cron.hourly)",
+			},
+		},
+		{
+			name: "rfc 3164 without tag",
+			line: `Mar 10 08:00:01 web01 last message repeated 3 times`,
+			expected: map[string]string{
+				"hostname": "web01",
+				"message":  "last message repeated 3 times",
+			},
+		},
+		{
+			name: "rfc 3164 with rfc 3339 timestamp",
+			line: `2024-03-10T08:00:01.123456+00:00 web01 kernel: eth0: link up`,
+			expected: map[string]string{
+				"timestamp": "2024-03-10T08:00:01.123456Z",
+				"hostname":  "web01",
+				"app_name":  "kernel",
+				"message":   "eth0: link up",
+			},
+		},
+		{
+			name: "previous year",
+			line: `Dec 31 23:59:59 web01 app: happy new year`,
+			expected: map[string]string{
+				"timestamp": "2023-12-31T23:59:59Z",
+			},
+		},
+		{
+			name: "leap day",
+			line: `Feb 29 10:00:00 web01 app: leap`,
+			expected: map[string]string{
+				"timestamp": "2024-02-29T10:00:00Z",
+			},
+		},
+		{
+			name:        "invalid priority",
+			line:        `<999>Mar 10 08:00:01 web01 app: message`,
+			expectedErr: "invalid syslog priority",
+		},
+		{
+			name:        "invalid timestamp",
+			line:        `not a syslog line`,
+			expectedErr: "invalid syslog timestamp",
+		},
+		{
+			name:        "unterminated structured data",
+			line:        `<13>1 2024-03-10T08:14:15Z host app - - [meta a="1"`,
+			expectedErr: "unterminated RFC 5424 structured data element meta",
+		},
+	}
+	parser := &syslogParser{location: time.UTC}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := parser.parse(tt.line, reference)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse() error = %v", err)
+			}
+			actual := make(map[string]string)
+			for key := range tt.expected {
+				actual[key] = fields[key]
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected fields %q, got %q", tt.expected, fields)
+			}
+		})
+	}
+}
+
+func TestInferSyslogYear(t *testing.T) {
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		reference time.Time
+		expected  int
+	}{
+		{
+			name:      "same year",
+			timestamp: time.Date(0, time.June, 1, 0, 0, 0, 0, time.UTC),
+			reference: time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC),
+			expected:  2024,
+		},
+		{
+			name:      "end of previous year",
+			timestamp: time.Date(0, time.December, 31, 23, 0, 0, 0, time.UTC),
+			reference: time.Date(2025, time.January, 1, 1, 0, 0, 0, time.UTC),
+			expected:  2024,
+		},
+		{
+			name:      "clock skew",
+			timestamp: time.Date(0, time.January, 1, 0, 30, 0, 0, time.UTC),
+			reference: time.Date(2024, time.December, 31, 23, 0, 0, 0, time.UTC),
+			expected:  2025,
+		},
+		{
+			name:      "leap day in an earlier year",
+			timestamp: time.Date(0, time.February, 29, 0, 0, 0, 0, time.UTC),
+			reference: time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC),
+			expected:  2024,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := inferSyslogYear(tt.timestamp, tt.reference)
+			if actual.Year() != tt.expected || actual.Month() != tt.timestamp.Month() || actual.Day() != tt.timestamp.Day() {
+				t.Errorf("expected %d-%02d-%02d, got %s", tt.expected, tt.timestamp.Month(), tt.timestamp.Day(), actual)
+			}
+		})
+	}
+}
+
+func TestSyslogLoader_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages")
+	if err := os.WriteFile(path, []byte("Dec 31 23:59:59 web01 app: one\nJan  1 00:00:01 web01 app: two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2025, time.January, 1, 0, 5, 0, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	info := types.NewDownloadedArtifactInfo(&types.ArtifactInfo{Name: path}, path, 0)
+	dataChan := make(chan *types.RowData)
+	if err := NewSyslogLoader().Load(context.Background(), info, dataChan); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	mapper, err := (&Syslog{}).GetMapper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timestamps []string
+	for data := range dataChan {
+		line, ok := data.Data.(*SyslogLine)
+		if !ok {
+			t.Fatalf("expected a SyslogLine, got %T", data.Data)
+		}
+		if !line.ModTime.Equal(modTime) {
+			t.Errorf("expected mod time %s, got %s", modTime, line.ModTime)
+		}
+		row, err := mapper.Map(context.Background(), line)
+		if err != nil {
+			t.Fatalf("Map() error = %v", err)
+		}
+		timestamp, _ := row.GetSourceValue("timestamp")
+		timestamps = append(timestamps, timestamp)
+	}
+	expected := []string{"2024-12-31T23:59:59Z", "2025-01-01T00:00:01Z"}
+	if !reflect.DeepEqual(timestamps, expected) {
+		t.Errorf("expected timestamps %q, got %q", expected, timestamps)
+	}
+}
+
+func TestSyslog_Validate(t *testing.T) {
+	if err := (&Syslog{Timezone: stringPtr("Europe/London")}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := (&Syslog{Timezone: stringPtr("Mars/Olympus")}).Validate(); err == nil || !strings.Contains(err.Error(), "invalid timezone") {
+		t.Errorf("expected invalid timezone error, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package formats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_loader"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const (
+	W3cFormatIdentifier = "w3c"
+	W3cLoaderIdentifier = "w3c_loader"
+)
+
+var (
+	errW3cMissingFields     = errors.New("W3C row precedes the #Fields directive")
+	errW3cFieldCount        = errors.New("W3C row does not have the number of values in the #Fields directive")
+	errW3cUnterminatedQuote = errors.New("W3C row has an unterminated quoted value")
+)
+
+// DefaultW3c is the default W3C format - this is exported by the core plugin
+var DefaultW3c = &W3c{
+	Name:        "default",
+	Description: "W3C Extended Log File Format (IIS, CloudFront etc.)",
+}
+
+// W3c is a format for W3C Extended Log File Format files, as written by IIS, CloudFront and other web servers and
+// proxies. The fields of each row are declared by the preceding #Fields directive, which may change within a file
+// The columns are:
+//   - a column for each field, named after the field identifier in lower case with any characters other than letters
+//     and digits replaced by '_', e.g. cs-uri-stem -> cs_uri_stem and cs(User-Agent) -> cs_user_agent
+//     values are decoded ('+' is a space and '%' starts an escape sequence) and a value of "-" is null
+//   - timestamp (RFC 3339, UTC), combined from the date and time fields - if there is no date field, the date of the
+//     #Date directive is used
+type W3c struct {
+	Name        string `hcl:",label"`
+	Description string `hcl:"description,optional"`
+	// the fields of the rows which precede the first #Fields directive, e.g. for files whose header has been removed
+	Fields []string `hcl:"fields,optional"`
+}
+
+func NewW3c() formats.Format {
+	return &W3c{}
+}
+
+func (w *W3c) Validate() error {
+	for _, field := range w.Fields {
+		if normalizeColumnName(field) == "" {
+			return fmt.Errorf("invalid field %q: must contain a letter or digit", field)
+		}
+	}
+	return nil
+}
+
+// GetName returns the name of this format instance
+func (w *W3c) GetName() string {
+	return w.Name
+}
+
+// SetName sets the name of this format instance
+func (w *W3c) SetName(name string) {
+	w.Name = name
+}
+
+// GetDescription returns the description of this format instance
+func (w *W3c) GetDescription() string {
+	return w.Description
+}
+
+// GetProperties returns the format properties as a string map - used for introspection
+func (w *W3c) GetProperties() map[string]string {
+	properties := make(map[string]string)
+	if len(w.Fields) > 0 {
+		properties["fields"] = strings.Join(w.Fields, " ")
+	}
+	return properties
+}
+
+// Identifier returns the format type identifier
+func (w *W3c) Identifier() string {
+	return W3cFormatIdentifier
+}
+
+func (w *W3c) GetRegex() (string, error) {
+	// the W3C format does not support regex
+	return "N/A", nil
+}
+
+func (w *W3c) GetMapper() (mappers.Mapper[*types.DynamicRow], error) {
+	return &FieldsMapper{}, nil
+}
+
+// GetSourceOptions returns the options for the source - each line is loaded by the w3c loader, which reads the
+// directives and maps each row to the fields of the current #Fields directive
+// (the SDK header row notification is not used, as this is only sent for the first line of the artifact)
+func (w *W3c) GetSourceOptions() []row_source.RowSourceOption {
+	return []row_source.RowSourceOption{
+		artifact_source.WithRowPerLine(),
+		artifact_source.WithArtifactLoader(NewW3cLoader(w.Fields)),
+	}
+}
+
+// W3cLoader is a Loader which maps the lines loaded by another loader to the fields of the W3C #Fields directive,
+// sending each row as a map of column name to value
+// Directive lines are not sent, and any row which is not a line (e.g. an error sent in place of an invalid line) is
+// sent as is
+type W3cLoader struct {
+	// the fields of the rows which precede the first #Fields directive
+	fields []string
+	// the loader which loads the lines of the artifact - if this is not set, the SDK row loader for the file
+	// extension is used
+	inner artifact_loader.Loader
+}
+
+func NewW3cLoader(fields []string) *W3cLoader {
+	return &W3cLoader{fields: fields}
+}
+
+func (l *W3cLoader) Identifier() string {
+	return W3cLoaderIdentifier
+}
+
+// SetInnerLoader sets the loader which loads the lines of the artifact
+// this allows a source which loads the artifact data itself to load the lines
+func (l *W3cLoader) SetInnerLoader(inner artifact_loader.Loader) {
+	l.inner = inner
+}
+
+// Load implements Loader
+func (l *W3cLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	inner := l.inner
+	if inner == nil {
+		inner = defaultRowLoader(info.LocalName)
+	}
+	lineChan := make(chan *types.RowData)
+	if err := inner.Load(ctx, info, lineChan); err != nil {
+		return err
+	}
+
+	go func() {
+		defer close(dataChan)
+		reader := newW3cReader(l.fields)
+		for row := range lineChan {
+			line, ok := row.Data.(string)
+			if !ok {
+				dataChan <- row
+				continue
+			}
+			fields, err := reader.read(line)
+			switch {
+			case err != nil:
+				dataChan <- &types.RowData{Data: err, SourceEnrichment: row.SourceEnrichment}
+			case fields != nil:
+				dataChan <- &types.RowData{Data: fields, SourceEnrichment: row.SourceEnrichment}
+			}
+		}
+		slog.Debug("W3cLoader Load complete", "path", info.LocalName)
+	}()
+	return nil
+}
+
+// w3cReader reads the lines of a W3C extended log file, tracking the current directives
+type w3cReader struct {
+	// the column names of the fields of the current #Fields directive
+	columns []string
+	// the date of the current #Date directive, used if the rows have a time but no date
+	date string
+}
+
+func newW3cReader(fields []string) *w3cReader {
+	r := &w3cReader{}
+	r.setFields(fields)
+	return r
+}
+
+func (r *w3cReader) setFields(fields []string) {
+	r.columns = make([]string, len(fields))
+	for i, field := range fields {
+		r.columns[i] = normalizeColumnName(field)
+	}
+}
+
+// read reads a line, returning the fields of a row, or nil if the line is a directive or is blank
+func (r *w3cReader) read(line string) (map[string]string, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "#") {
+		r.readDirective(line[1:])
+		return nil, nil
+	}
+	if strings.TrimSpace(line) == "" {
+		return nil, nil
+	}
+	if len(r.columns) == 0 {
+		return nil, errW3cMissingFields
+	}
+
+	values, err := splitW3cValues(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != len(r.columns) {
+		return nil, errW3cFieldCount
+	}
+	fields := make(map[string]string, len(values)+1)
+	for i, value := range values {
+		if value == "-" {
+			continue
+		}
+		fields[r.columns[i]] = value
+	}
+
+	date := fields["date"]
+	if date == "" {
+		date = r.date
+	}
+	if clock, ok := fields["time"]; ok && date != "" {
+		if timestamp, err := time.Parse(time.DateTime, date+" "+clock); err == nil {
+			fields["timestamp"] = timestamp.Format(time.RFC3339Nano)
+		}
+	}
+	return fields, nil
+}
+
+// readDirective reads a directive (following the '#') - the #Fields and #Date directives are used, and any other
+// directive (#Version, #Software, #Remark etc.) is ignored
+func (r *w3cReader) readDirective(directive string) {
+	name, value, ok := strings.Cut(directive, ":")
+	if !ok {
+		return
+	}
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "fields":
+		r.setFields(strings.Fields(value))
+	case "date":
+		// the date and time of the directive, e.g. "2024-03-10 00:00:00"
+		if date, _, _ := strings.Cut(strings.TrimSpace(value), " "); date != "" {
+			r.date = date
+		}
+	}
+}
+
+// splitW3cValues splits a W3C row into its values, separated by spaces or tabs
+// A value may be quoted (with "" for a quote), otherwise it is decoded - '+' is a space and '%' starts an escape
+// sequence (a value which is not a valid escape sequence is kept as is)
+func splitW3cValues(line string) ([]string, error) {
+	var values []string
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+		if line[i] == '"' {
+			var sb strings.Builder
+			closed := false
+			for i++; i < len(line); i++ {
+				if line[i] == '"' {
+					if i+1 < len(line) && line[i+1] == '"' {
+						sb.WriteByte('"')
+						i++
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(line[i])
+			}
+			if !closed {
+				return nil, errW3cUnterminatedQuote
+			}
+			values = append(values, sb.String())
+			continue
+		}
+		start := i
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		value := line[start:i]
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -0,0 +1,170 @@
+package formats
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+func TestW3cReader_Read(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []string
+		lines    []string
+		expected []any
+	}{
+		{
+			name: "iis",
+			lines: []string{
+				"#Software: Microsoft Internet Information Services 10.0",
+				"#Version: 1.0",
+				"#Date: 2024-03-10 00:00:00",
+				"#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query sc-status cs(User-Agent) time-taken",
+				"2024-03-10 08:00:01 10.0.0.1 GET /index.html q=a%26b 200 Mozilla/5.0+(Windows+NT+10.0) 15",
+			},
+			expected: []any{
+				map[string]string{
+					"date":          "2024-03-10",
+					"time":          "08:00:01",
+					"timestamp":     "2024-03-10T08:00:01Z",
+					"s_ip":          "10.0.0.1",
+					"cs_method":     "GET",
+					"cs_uri_stem":   "/index.html",
+					"cs_uri_query":  "q=a&b",
+					"sc_status":     "200",
+					"cs_user_agent": "Mozilla/5.0 (Windows NT 10.0)",
+					"time_taken":    "15",
+				},
+			},
+		},
+		{
+			name: "fields change within the file",
+			lines: []string{
+				"#Fields: date time sc-status",
+				"2024-03-10 08:00:01 200",
+				"#Fields: date time cs-method sc-status",
+				"2024-03-10 09:00:01.250 POST 201",
+			},
+			expected: []any{
+				map[string]string{"date": "2024-03-10", "time": "08:00:01", "timestamp": "2024-03-10T08:00:01Z", "sc_status": "200"},
+				map[string]string{"date": "2024-03-10", "time": "09:00:01.250", "timestamp": "2024-03-10T09:00:01.25Z", "cs_method": "POST", "sc_status": "201"},
+			},
+		},
+		{
+			name: "cloudfront tab separated with null values",
+			lines: []string{
+				"#Version: 1.0",
+				"#Fields: date time x-edge-location sc-bytes cs-uri-query cs(Referer)",
+				"2024-03-10\t08:00:01\tLHR62-C2\t2390\t-\t-",
+			},
+			expected: []any{
+				map[string]string{"date": "2024-03-10", "time": "08:00:01", "timestamp": "2024-03-10T08:00:01Z", "x_edge_location": "LHR62-C2", "sc_bytes": "2390"},
+			},
+		},
+		{
+			name: "time with the date directive",
+			lines: []string{
+				"#Date: 2024-03-10 00:00:00",
+				"#Fields: time c-ip",
+				"08:00:01 10.0.0.2",
+			},
+			expected: []any{
+				map[string]string{"time": "08:00:01", "timestamp": "2024-03-10T08:00:01Z", "c_ip": "10.0.0.2"},
+			},
+		},
+		{
+			name: "quoted values",
+			lines: []string{
+				"#Fields: c-ip x-comment",
+				`10.0.0.2 "a ""quoted"" comment"`,
+			},
+			expected: []any{
+				map[string]string{"c_ip": "10.0.0.2", "x_comment": `a "quoted" comment`},
+			},
+		},
+		{
+			name:   "configured fields",
+			fields: []string{"c-ip", "sc-status"},
+			lines:  []string{"10.0.0.2 404"},
+			expected: []any{
+				map[string]string{"c_ip": "10.0.0.2", "sc_status": "404"},
+			},
+		},
+		{
+			name: "invalid rows",
+			lines: []string{
+				"10.0.0.2 404",
+				"#Fields: c-ip sc-status",
+				"10.0.0.2",
+				`10.0.0.2 "unterminated`,
+				"",
+				"10.0.0.3 %zz",
+			},
+			expected: []any{
+				errW3cMissingFields,
+				errW3cFieldCount,
+				errW3cUnterminatedQuote,
+				map[string]string{"c_ip": "10.0.0.3", "sc_status": "%zz"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newW3cReader(tt.fields)
+			var rows []any
+			for _, line := range tt.lines {
+				fields, err := reader.read(line)
+				switch {
+				case err != nil:
+					rows = append(rows, err)
+				case fields != nil:
+					rows = append(rows, fields)
+				}
+			}
+			if !reflect.DeepEqual(rows, tt.expected) {
+				t.Errorf("expected rows %v, got %v", tt.expected, rows)
+			}
+		})
+	}
+}
+
+func TestW3cLoader_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "u_ex240310.log")
+	data := "#Fields: date time sc-status\r\n2024-03-10 08:00:01 200\r\n2024-03-10 08:00:02\r\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info := types.NewDownloadedArtifactInfo(&types.ArtifactInfo{Name: path}, path, 0)
+	dataChan := make(chan *types.RowData)
+	if err := NewW3cLoader(nil).Load(context.Background(), info, dataChan); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var rows []any
+	for row := range dataChan {
+		rows = append(rows, row.Data)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %v", rows)
+	}
+
+	mapper, err := (&W3c{}).GetMapper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := mapper.Map(context.Background(), rows[0])
+	if err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	if timestamp, _ := row.GetSourceValue("timestamp"); timestamp != "2024-03-10T08:00:01Z" {
+		t.Errorf("expected timestamp 2024-03-10T08:00:01Z, got %q", timestamp)
+	}
+	if err, ok := rows[1].(error); !ok || !errors.Is(err, errW3cFieldCount) {
+		t.Errorf("expected a field count error, got %v", rows[1])
+	}
+}
@@ -0,0 +1,175 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-core/sources/spool"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const (
+	// maxStderrSize is the amount of stderr retained to report errors, when stderr is not collected
+	maxStderrSize = 4096
+	// waitDelay is how long to wait for the output to be closed once the command has exited or been killed
+	// (the output may be held open by a child process)
+	waitDelay = 5 * time.Second
+)
+
+// CommandSource is an artifact source which runs a local command and collects its output
+// The stdout of the command (and optionally stderr) is spooled to a file in the temp dir, which is then processed
+// as an artifact. Timeouts and unsuccessful exit codes are not fatal - they are reported as errors and any output
+// written by the command is still collected
+type CommandSource struct {
+	artifact_source.ArtifactSourceImpl[*CommandSourceConfig, *artifact_source.EmptyConnection]
+}
+
+// Init sets the collection state then calls the base Init
+func (s *CommandSource) Init(ctx context.Context, params *row_source.RowSourceParams, opts ...row_source.RowSourceOption) error {
+	// spooled data is always collected, so there is no collection state to keep
+	s.NewCollectionStateFunc = spool.NewCollectionState
+
+	return s.ArtifactSourceImpl.Init(ctx, params, opts...)
+}
+
+func (s *CommandSource) Identifier() string {
+	return CommandSourceIdentifier
+}
+
+// DiscoverArtifacts runs the command, writing its output to a file in the temp dir,
+// and notifies observers of the file as a discovered artifact
+func (s *CommandSource) DiscoverArtifacts(ctx context.Context) error {
+	executionId, err := context_values.ExecutionIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(s.Config.Command)
+	f, err := spool.CreateFile(s.TempDir, name+".log")
+	if err != nil {
+		slog.Error("CommandSource.DiscoverArtifacts error creating spool file", "error", err)
+		return fmt.Errorf("unable to create spool file: %w", err)
+	}
+	defer f.Close()
+
+	timeout := s.Config.GetTimeout()
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, s.Config.Command, s.Config.Args...)
+	cmd.Env = s.commandEnv()
+	cmd.WaitDelay = waitDelay
+	if s.Config.Dir != nil {
+		cmd.Dir = *s.Config.Dir
+	}
+	stderr := &tailBuffer{limit: maxStderrSize}
+	cmd.Stdout = f
+	if s.Config.IncludeStderrEnabled() {
+		cmd.Stderr = f
+	} else {
+		cmd.Stderr = stderr
+	}
+
+	// NOTE: only the executable is logged (and used as the source location), as the args may contain secrets
+	slog.Info("CommandSource.DiscoverArtifacts running command", "command", s.Config.Command)
+	start := time.Now()
+	err = cmd.Run()
+	slog.Info("CommandSource.DiscoverArtifacts command complete", "command", name, "duration", time.Since(start), "error", err)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	switch {
+	// timing out is not fatal - collect whatever output we have
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		slog.Warn("CommandSource.DiscoverArtifacts command timed out", "command", name, "timeout", timeout)
+		s.NotifyError(ctx, executionId, fmt.Errorf("%s: command timed out after %s", name, timeout))
+	case cmd.ProcessState != nil:
+		// the command ran - check the exit code
+		if code := cmd.ProcessState.ExitCode(); !s.Config.IsSuccessExitCode(code) {
+			slog.Warn("CommandSource.DiscoverArtifacts command failed", "command", name, "exit_code", code, "stderr", stderr.String())
+			s.NotifyError(ctx, executionId, fmt.Errorf("%s: command exited with code %d%s", name, code, stderr.summary()))
+		}
+	case err != nil:
+		// the command could not be started - this is fatal
+		slog.Error("CommandSource.DiscoverArtifacts error running command", "command", name, "error", err)
+		return fmt.Errorf("%s: unable to run command: %w", name, err)
+	}
+
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("%s: unable to obtain file info", filepath.Base(f.Name()))
+	}
+	// if there is no output, there is nothing to collect
+	if fileInfo.Size() == 0 {
+		return nil
+	}
+
+	info, err := spool.NewArtifactInfo(f.Name(), CommandSourceIdentifier, s.Config.Command, nil)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDiscovered(ctx, info)
+}
+
+// DownloadArtifact does nothing as the artifact has already been spooled to the temp dir
+func (s *CommandSource) DownloadArtifact(ctx context.Context, info *types.ArtifactInfo) error {
+	downloadInfo, err := spool.NewDownloadedArtifactInfo(info)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDownloaded(ctx, downloadInfo)
+}
+
+// commandEnv returns the environment for the command - the plugin environment plus the configured variables
+// (configured variables take precedence)
+func (s *CommandSource) commandEnv() []string {
+	env := os.Environ()
+	for _, k := range slices.Sorted(maps.Keys(s.Config.Env)) {
+		env = append(env, k+"="+s.Config.Env[k])
+	}
+	return env
+}
+
+// tailBuffer is an io.Writer which retains the last limit bytes written to it
+type tailBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = slices.Clone(b.buf[len(b.buf)-b.limit:])
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
+
+// summary returns the last line written, formatted to append to an error message
+func (b *tailBuffer) summary() string {
+	s := strings.TrimSpace(b.String())
+	if s == "" {
+		return ""
+	}
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[i+1:])
+	}
+	return ": " + s
+}
@@ -0,0 +1,131 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	typehelpers "github.com/turbot/go-kit/types"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source_config"
+)
+
+const (
+	CommandSourceIdentifier = "command"
+
+	defaultTimeout = 5 * time.Minute
+)
+
+type CommandSourceConfig struct {
+	artifact_source_config.ArtifactSourceConfigImpl
+	// required to allow partial decoding
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// the executable to run - if this is not a path, it is looked up in the PATH
+	// (a relative path is relative to dir, if set)
+	Command string `hcl:"command"`
+	// the arguments to pass to the command
+	Args []string `hcl:"args,optional"`
+	// additional environment variables for the command - the plugin environment is inherited
+	Env map[string]string `hcl:"env,optional"`
+	// the working directory for the command (defaults to the plugin working directory)
+	Dir *string `hcl:"dir,optional"`
+	// the maximum time the command may run for, e.g. "30s" or "10m" (default 5m)
+	// if the command times out, it is killed and any output written so far is collected
+	Timeout *string `hcl:"timeout,optional"`
+	// if true, stderr is collected along with stdout (default false)
+	IncludeStderr *bool `hcl:"include_stderr,optional"`
+	// the exit codes which indicate the command succeeded (default [0])
+	// the output is collected whatever the exit code, but other exit codes are reported as errors
+	SuccessExitCodes []int `hcl:"success_exit_codes,optional"`
+}
+
+func (c *CommandSourceConfig) Validate() error {
+	// validate the base fields
+	if err := c.ArtifactSourceConfigImpl.Validate(); err != nil {
+		return err
+	}
+
+	if c.Command == "" {
+		return fmt.Errorf("required field: command can not be empty")
+	}
+
+	for k := range c.Env {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			return fmt.Errorf("invalid env variable name '%s'", k)
+		}
+	}
+
+	if c.Dir != nil {
+		info, err := os.Stat(*c.Dir)
+		if err != nil {
+			return fmt.Errorf("dir %s does not exist", *c.Dir)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("dir %s is not a directory", *c.Dir)
+		}
+	}
+
+	// NOTE: validate the command after the dir, as a relative path is relative to the dir
+	if _, err := exec.LookPath(c.commandPath()); err != nil {
+		return fmt.Errorf("command %s not found or not executable", c.Command)
+	}
+
+	if c.Timeout != nil {
+		d, err := time.ParseDuration(*c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %s: %w", *c.Timeout, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("timeout must be greater than zero")
+		}
+	}
+
+	return nil
+}
+
+func (c *CommandSourceConfig) Identifier() string {
+	return CommandSourceIdentifier
+}
+
+// commandPath returns the path used to find the command
+// a relative path (i.e. a command containing a path separator) is run relative to the dir, so is resolved against it
+// - any other command is looked up in the PATH
+func (c *CommandSourceConfig) commandPath() string {
+	if c.Dir == nil || filepath.IsAbs(c.Command) || filepath.Base(c.Command) == c.Command {
+		return c.Command
+	}
+	// make the path absolute, so joining a dir of "." does not remove the path separator
+	path, err := filepath.Abs(filepath.Join(*c.Dir, c.Command))
+	if err != nil {
+		return c.Command
+	}
+	return path
+}
+
+// GetTimeout returns the maximum time the command may run for
+func (c *CommandSourceConfig) GetTimeout() time.Duration {
+	if c.Timeout == nil {
+		return defaultTimeout
+	}
+	// we have already validated the timeout
+	d, _ := time.ParseDuration(*c.Timeout)
+	return d
+}
+
+// IncludeStderrEnabled returns whether stderr is collected along with stdout
+func (c *CommandSourceConfig) IncludeStderrEnabled() bool {
+	return typehelpers.BoolValue(c.IncludeStderr)
+}
+
+// IsSuccessExitCode returns whether the exit code indicates the command succeeded
+func (c *CommandSourceConfig) IsSuccessExitCode(code int) bool {
+	if len(c.SuccessExitCodes) == 0 {
+		return code == 0
+	}
+	return slices.Contains(c.SuccessExitCodes, code)
+}
@@ -0,0 +1,190 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/events"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+type testObserver struct {
+	Rows   []string
+	Errors []string
+	mut    sync.Mutex
+}
+
+func (r *testObserver) Notify(_ context.Context, e events.Event) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	switch e := e.(type) {
+	case *events.RowExtracted:
+		r.Rows = append(r.Rows, e.Row.(string))
+	case *events.Error:
+		r.Errors = append(r.Errors, e.Err.Error())
+	}
+	return nil
+}
+
+func TestCommandSource_Collect(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name           string
+		script         string
+		config         string
+		expectedRows   []string
+		expectedErrors []string
+	}{
+		{
+			name:         "stdout",
+			script:       "echo line 1; echo ignored >&2; echo line 2",
+			expectedRows: []string{"line 1", "line 2"},
+		},
+		{
+			name:         "include stderr",
+			script:       "echo line 1; echo line 2 >&2",
+			config:       "include_stderr = true",
+			expectedRows: []string{"line 1", "line 2"},
+		},
+		{
+			name:         "env and dir",
+			script:       `echo "$TEST_VALUE"; pwd`,
+			config:       fmt.Sprintf("env = { TEST_VALUE = \"value\" }\ndir = %q", dir),
+			expectedRows: []string{dir, "value"},
+		},
+		{
+			name:           "failed exit code",
+			script:         "echo line 1; echo first >&2; echo something went wrong >&2; exit 3",
+			expectedRows:   []string{"line 1"},
+			expectedErrors: []string{"sh: command exited with code 3: something went wrong"},
+		},
+		{
+			name:         "success exit code",
+			script:       "echo line 1; exit 1",
+			config:       "success_exit_codes = [0, 1]",
+			expectedRows: []string{"line 1"},
+		},
+		{
+			name:           "timeout",
+			script:         "echo line 1; exec sleep 10",
+			config:         `timeout = "500ms"`,
+			expectedRows:   []string{"line 1"},
+			expectedErrors: []string{"sh: command timed out after 500ms"},
+		},
+		{
+			name:   "no output",
+			script: "true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context_values.WithExecutionId(context.Background(), "test")
+
+			s := &CommandSource{}
+			any(s).(row_source.BaseSource).RegisterSource(s)
+
+			hclBytes := []byte(fmt.Sprintf("command = \"sh\"\nargs = [\"-c\", %q]\n%s", tt.script, tt.config))
+			err := s.Init(ctx, &row_source.RowSourceParams{
+				SourceConfigData:  types.NewSourceConfigData(hclBytes, hcl.Range{}, CommandSourceIdentifier),
+				CollectionTempDir: t.TempDir(),
+			}, artifact_source.WithRowPerLine())
+			if err != nil {
+				t.Fatalf("failed to init: %v", err)
+			}
+
+			var observer testObserver
+			_ = s.AddObserver(&observer)
+
+			if err := s.Collect(ctx); err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			slices.Sort(observer.Rows)
+			if !slices.Equal(observer.Rows, tt.expectedRows) {
+				t.Errorf("expected rows %q, got %q", tt.expectedRows, observer.Rows)
+			}
+			if !slices.Equal(observer.Errors, tt.expectedErrors) {
+				t.Errorf("expected errors %q, got %q", tt.expectedErrors, observer.Errors)
+			}
+		})
+	}
+}
+
+func TestCommandSourceConfig_Validate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "collect.sh"), []byte("#!/bin/sh\necho line 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			config: `command = "sh"`,
+		},
+		{
+			name:    "command not found",
+			config:  `command = "tailpipe-no-such-command"`,
+			wantErr: "not found",
+		},
+		{
+			name:   "command relative to dir",
+			config: fmt.Sprintf("command = \"./collect.sh\"\ndir = %q", dir),
+		},
+		{
+			name:    "command relative to the plugin working directory",
+			config:  `command = "./collect.sh"`,
+			wantErr: "not found",
+		},
+		{
+			name:    "invalid env name",
+			config:  "command = \"sh\"\nenv = { \"A=B\" = \"value\" }",
+			wantErr: "invalid env variable name",
+		},
+		{
+			name:    "missing dir",
+			config:  "command = \"sh\"\ndir = \"/tailpipe/no/such/dir\"",
+			wantErr: "does not exist",
+		},
+		{
+			name:    "invalid timeout",
+			config:  "command = \"sh\"\ntimeout = \"-1s\"",
+			wantErr: "greater than zero",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context_values.WithExecutionId(context.Background(), "test")
+			s := &CommandSource{}
+			any(s).(row_source.BaseSource).RegisterSource(s)
+			err := s.Init(ctx, &row_source.RowSourceParams{
+				SourceConfigData:  types.NewSourceConfigData([]byte(tt.config), hcl.Range{}, CommandSourceIdentifier),
+				CollectionTempDir: t.TempDir(),
+			})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package container_logs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the container runtime log formats
+const (
+	// the Docker json-file logging driver - each line is a JSON object with the log, stream and time
+	runtimeDocker = "docker"
+	// the CRI log format used by Kubernetes - each line is "<time> <stream> <P|F> <log>"
+	runtimeCri = "cri"
+)
+
+const (
+	// the maximum size of a line in a log file
+	maxLineSize = 1024 * 1024
+	// the maximum size of a record reassembled from partial lines - longer records are split
+	maxRecordSize = 1024 * 1024
+)
+
+// containerLog is the log of a single container, which may be split across rotated files
+type containerLog struct {
+	// the key of the cursor for the log
+	key     string
+	runtime string
+	// the log files, oldest first
+	files    []string
+	metadata containerMetadata
+}
+
+// containerMetadata is the metadata of a container, which is added to each record of its log
+type containerMetadata struct {
+	ContainerId   string            `json:"container_id"`
+	ContainerName string            `json:"container_name"`
+	Image         string            `json:"image"`
+	Namespace     string            `json:"namespace"`
+	Pod           string            `json:"pod"`
+	PodUid        string            `json:"pod_uid"`
+	Labels        map[string]string `json:"labels"`
+}
+
+// containerLogRecord is a single record of a container log, reassembled from partial lines if necessary
+type containerLogRecord struct {
+	timestamp time.Time
+	stream    string
+	log       string
+}
+
+// location returns the path of the current log file
+func (l *containerLog) location() string {
+	return l.files[len(l.files)-1]
+}
+
+// records calls fn for each record in the log files, in order, returning the number of lines which could not be parsed
+// lines which have been split by the runtime are reassembled - a partial record at the end of the log is not
+// returned, as the rest of the record may not have been written yet
+func (l *containerLog) records(fn func(record *containerLogRecord) error) (int, error) {
+	parse := parseDockerLine
+	if l.runtime == runtimeCri {
+		parse = parseCriLine
+	}
+
+	// the partial record for each stream
+	pending := make(map[string]*containerLogRecord)
+	var invalid int
+	for _, path := range l.files {
+		err := readLines(path, func(line []byte) error {
+			timestamp, stream, text, partial, err := parse(line)
+			if err != nil {
+				invalid++
+				return nil
+			}
+
+			record, ok := pending[stream]
+			if !ok {
+				record = &containerLogRecord{timestamp: timestamp, stream: stream}
+			}
+			record.log += text
+			if partial && len(record.log) < maxRecordSize {
+				pending[stream] = record
+				return nil
+			}
+			delete(pending, stream)
+			return fn(record)
+		})
+		if err != nil {
+			return invalid, err
+		}
+	}
+	return invalid, nil
+}
+
+// readLines calls fn for each non-empty line of the file, decompressing gzipped (rotated) files
+func readLines(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: unable to decompress: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: unable to read: %w", path, err)
+	}
+	return nil
+}
+
+// parseDockerLine parses a line written by the json-file logging driver:
+// {"log":"message\n","stream":"stdout","time":"2024-05-01T12:00:00.000000001Z"}
+// lines longer than 16KiB are split into several entries - only the last ends with a newline
+func parseDockerLine(line []byte) (timestamp time.Time, stream, text string, partial bool, err error) {
+	var entry struct {
+		Log    string    `json:"log"`
+		Stream string    `json:"stream"`
+		Time   time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return time.Time{}, "", "", false, err
+	}
+	if entry.Time.IsZero() {
+		return time.Time{}, "", "", false, errors.New("missing time")
+	}
+	text, complete := strings.CutSuffix(entry.Log, "\n")
+	return entry.Time, entry.Stream, text, !complete, nil
+}
+
+// parseCriLine parses a line of a CRI log: <time> <stream> <tags> <message>
+// the first tag is P for a partial line, which is continued in the next line of the stream, or F for a full line
+func parseCriLine(line []byte) (timestamp time.Time, stream, text string, partial bool, err error) {
+	parts := strings.SplitN(string(line), " ", 4)
+	if len(parts) < 3 {
+		return time.Time{}, "", "", false, errors.New("invalid CRI log line")
+	}
+	timestamp, err = time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", "", false, err
+	}
+	tag, _, _ := strings.Cut(parts[2], ":")
+	if tag != "P" && tag != "F" {
+		return time.Time{}, "", "", false, fmt.Errorf("invalid CRI log tag %q", parts[2])
+	}
+	if len(parts) == 4 {
+		text = parts[3]
+	}
+	return timestamp, parts[1], text, tag == "P", nil
+}
+
+// findDockerLogs returns the logs of the containers in the Docker containers directory
+// each container has a directory named after the container id, containing <id>-json.log (plus rotated logs
+// <id>-json.log.<n>, which may be gzipped) and the container config, config.v2.json
+func findDockerLogs(root string, notify func(error)) []*containerLog {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		notify(fmt.Errorf("%s: unable to read directory: %w", root, errors.Unwrap(err)))
+		return nil
+	}
+
+	var res []*containerLog
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		id := e.Name()
+		dir := filepath.Join(root, id)
+		files, err := dockerLogFiles(dir, id)
+		if err != nil {
+			notify(err)
+			continue
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		metadata, err := readDockerMetadata(dir, id)
+		if err != nil {
+			// we can still read the log without the metadata
+			notify(err)
+		}
+		res = append(res, &containerLog{
+			key:      runtimeDocker + ":" + id,
+			runtime:  runtimeDocker,
+			files:    files,
+			metadata: metadata,
+		})
+	}
+	return res
+}
+
+// dockerLogFiles returns the log files of a Docker container, oldest first
+// (the rotated logs are numbered from 1, which is the most recent)
+func dockerLogFiles(dir, id string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to read directory: %w", dir, errors.Unwrap(err))
+	}
+
+	current := id + "-json.log"
+	rotated := make(map[int]string)
+	var hasCurrent bool
+	for _, e := range entries {
+		name := e.Name()
+		if name == current {
+			hasCurrent = true
+			continue
+		}
+		suffix, ok := strings.CutPrefix(name, current+".")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(suffix, ".gz")); err == nil {
+			rotated[n] = name
+		}
+	}
+
+	var res []string
+	for _, n := range slices.Backward(slices.Sorted(maps.Keys(rotated))) {
+		res = append(res, filepath.Join(dir, rotated[n]))
+	}
+	if hasCurrent {
+		res = append(res, filepath.Join(dir, current))
+	}
+	return res, nil
+}
+
+// readDockerMetadata reads the metadata of a Docker container from its config.v2.json
+// the metadata always contains the container id, even if the config can not be read
+func readDockerMetadata(dir, id string) (containerMetadata, error) {
+	metadata := containerMetadata{ContainerId: id}
+
+	path := filepath.Join(dir, "config.v2.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return metadata, fmt.Errorf("%s: unable to read container config: %w", path, errors.Unwrap(err))
+	}
+	var config struct {
+		Name   string `json:"Name"`
+		Config struct {
+			Image  string            `json:"Image"`
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return metadata, fmt.Errorf("%s: unable to parse container config: %w", path, err)
+	}
+
+	metadata.ContainerName = strings.TrimPrefix(config.Name, "/")
+	metadata.Image = config.Config.Image
+	metadata.Labels = config.Config.Labels
+	// containers created by Kubernetes have labels identifying the pod
+	metadata.Namespace = config.Config.Labels["io.kubernetes.pod.namespace"]
+	metadata.Pod = config.Config.Labels["io.kubernetes.pod.name"]
+	metadata.PodUid = config.Config.Labels["io.kubernetes.pod.uid"]
+	return metadata, nil
+}
+
+// findPodLogs returns the logs of the containers in the Kubernetes pod log directory
+// each pod has a directory <namespace>_<pod>_<uid>, containing a directory for each container, which contains
+// <restart count>.log (plus rotated logs <restart count>.log.<timestamp>, which may be gzipped)
+// The container ids are read from the symlinks in the containers directory alongside the pods directory
+func findPodLogs(root string, notify func(error)) []*containerLog {
+	podEntries, err := os.ReadDir(root)
+	if err != nil {
+		notify(fmt.Errorf("%s: unable to read directory: %w", root, errors.Unwrap(err)))
+		return nil
+	}
+	containerIds := readContainerIds(filepath.Join(filepath.Dir(root), "containers"))
+
+	var res []*containerLog
+	for _, podEntry := range podEntries {
+		parts := strings.SplitN(podEntry.Name(), "_", 3)
+		if !podEntry.IsDir() || len(parts) != 3 {
+			continue
+		}
+		namespace, pod, podUid := parts[0], parts[1], parts[2]
+
+		podDir := filepath.Join(root, podEntry.Name())
+		containerEntries, err := os.ReadDir(podDir)
+		if err != nil {
+			notify(fmt.Errorf("%s: unable to read directory: %w", podDir, errors.Unwrap(err)))
+			continue
+		}
+		for _, containerEntry := range containerEntries {
+			if !containerEntry.IsDir() {
+				continue
+			}
+			containerName := containerEntry.Name()
+			containerDir := filepath.Join(podDir, containerName)
+			files, err := podLogFiles(containerDir)
+			if err != nil {
+				notify(err)
+				continue
+			}
+			// each restart of the container has its own log
+			for _, restart := range slices.Sorted(maps.Keys(files)) {
+				logFiles := files[restart]
+				res = append(res, &containerLog{
+					key:     fmt.Sprintf("%s:%s/%s/%d", runtimeCri, podUid, containerName, restart),
+					runtime: runtimeCri,
+					files:   logFiles,
+					metadata: containerMetadata{
+						ContainerId:   containerIds[filepath.Clean(logFiles[len(logFiles)-1])],
+						ContainerName: containerName,
+						Namespace:     namespace,
+						Pod:           pod,
+						PodUid:        podUid,
+					},
+				})
+			}
+		}
+	}
+	return res
+}
+
+// podLogFiles returns the log files of each restart of a container, oldest first
+func podLogFiles(dir string) (map[int][]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to read directory: %w", dir, errors.Unwrap(err))
+	}
+
+	current := make(map[int]string)
+	rotated := make(map[int][]string)
+	for _, e := range entries {
+		name := e.Name()
+		prefix, suffix, _ := strings.Cut(name, ".log")
+		restart, err := strconv.Atoi(prefix)
+		if err != nil || !e.Type().IsRegular() {
+			continue
+		}
+		switch {
+		case suffix == "":
+			current[restart] = filepath.Join(dir, name)
+		case strings.HasPrefix(suffix, "."):
+			// the rotated logs have a timestamp suffix, so sort in name order
+			rotated[restart] = append(rotated[restart], filepath.Join(dir, name))
+		}
+	}
+
+	res := make(map[int][]string)
+	for restart, files := range rotated {
+		slices.Sort(files)
+		res[restart] = files
+	}
+	for restart, path := range current {
+		res[restart] = append(res[restart], path)
+	}
+	return res, nil
+}
+
+// readContainerIds returns the container id of each pod log file, from the symlinks created by the kubelet:
+// /var/log/containers/<pod>_<namespace>_<container>-<container id>.log -> /var/log/pods/.../<restart count>.log
+func readContainerIds(dir string) map[string]string {
+	res := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return res
+	}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".log")
+		i := strings.LastIndex(name, "-")
+		if !ok || i == -1 || e.Type()&fs.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		res[filepath.Clean(target)] = name[i+1:]
+	}
+	return res
+}
@@ -0,0 +1,153 @@
+package container_logs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCriLine(t *testing.T) {
+	tests := []struct {
+		line            string
+		expectedStream  string
+		expectedText    string
+		expectedPartial bool
+		wantErr         bool
+	}{
+		{line: "2024-05-01T12:00:00.123456789Z stdout F hello world", expectedStream: "stdout", expectedText: "hello world"},
+		{line: "2024-05-01T12:00:00.123456789Z stderr P part ", expectedStream: "stderr", expectedText: "part ", expectedPartial: true},
+		{line: "2024-05-01T12:00:00.123456789+01:00 stdout F", expectedStream: "stdout"},
+		{line: "2024-05-01T12:00:00Z stdout F:extra tagged", expectedStream: "stdout", expectedText: "tagged"},
+		{line: "2024-05-01T12:00:00Z stdout X invalid tag", wantErr: true},
+		{line: "not a timestamp stdout F message", wantErr: true},
+		{line: "2024-05-01T12:00:00Z", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, stream, text, partial, err := parseCriLine([]byte(tt.line))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCriLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stream != tt.expectedStream || text != tt.expectedText || partial != tt.expectedPartial {
+				t.Errorf("parseCriLine() = %q, %q, %v, expected %q, %q, %v", stream, text, partial, tt.expectedStream, tt.expectedText, tt.expectedPartial)
+			}
+		})
+	}
+}
+
+func TestParseDockerLine(t *testing.T) {
+	tests := []struct {
+		line            string
+		expectedStream  string
+		expectedText    string
+		expectedPartial bool
+		wantErr         bool
+	}{
+		{line: `{"log":"hello world\n","stream":"stdout","time":"2024-05-01T12:00:00.123456789Z"}`, expectedStream: "stdout", expectedText: "hello world"},
+		{line: `{"log":"part","stream":"stderr","time":"2024-05-01T12:00:00.123456789Z"}`, expectedStream: "stderr", expectedText: "part", expectedPartial: true},
+		{line: `{"log":"no time\n","stream":"stdout"}`, wantErr: true},
+		{line: `not json`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, stream, text, partial, err := parseDockerLine([]byte(tt.line))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDockerLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stream != tt.expectedStream || text != tt.expectedText || partial != tt.expectedPartial {
+				t.Errorf("parseDockerLine() = %q, %q, %v, expected %q, %q, %v", stream, text, partial, tt.expectedStream, tt.expectedText, tt.expectedPartial)
+			}
+		})
+	}
+}
+
+func TestContainerLog_Records(t *testing.T) {
+	dir := t.TempDir()
+	// a partial line continues from the rotated (gzipped) file into the current file,
+	// with a line from the other stream in between
+	rotated := filepath.Join(dir, "0.log.20240501-120000.gz")
+	writeTestFile(t, rotated, strings.Join([]string{
+		"2024-05-01T12:00:00Z stdout F first",
+		"2024-05-01T12:00:01Z stdout P second ",
+		"2024-05-01T12:00:01Z stderr F error",
+	}, "\n"))
+	current := filepath.Join(dir, "0.log")
+	writeTestFile(t, current, strings.Join([]string{
+		"2024-05-01T12:00:02Z stdout P part ",
+		"invalid line",
+		"2024-05-01T12:00:03Z stdout F end",
+		"",
+		"2024-05-01T12:00:04Z stdout P incomplete",
+	}, "\n"))
+
+	l := &containerLog{runtime: runtimeCri, files: []string{rotated, current}}
+	var records []containerLogRecord
+	invalid, err := l.records(func(record *containerLogRecord) error {
+		records = append(records, *record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("records() error = %v", err)
+	}
+	if invalid != 1 {
+		t.Errorf("expected 1 invalid line, got %d", invalid)
+	}
+
+	ts := func(s string) time.Time {
+		res, _ := time.Parse(time.RFC3339, s)
+		return res
+	}
+	expected := []containerLogRecord{
+		{timestamp: ts("2024-05-01T12:00:00Z"), stream: "stdout", log: "first"},
+		{timestamp: ts("2024-05-01T12:00:01Z"), stream: "stderr", log: "error"},
+		{timestamp: ts("2024-05-01T12:00:01Z"), stream: "stdout", log: "second part end"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected records %v, got %v", expected, records)
+	}
+}
+
+func TestDockerLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	id := "abc123"
+	for _, name := range []string{id + "-json.log", id + "-json.log.1", id + "-json.log.2.gz", id + "-json.log.10", "config.v2.json", "hostconfig.json"} {
+		writeTestFile(t, filepath.Join(dir, name), "")
+	}
+
+	files, err := dockerLogFiles(dir, id)
+	if err != nil {
+		t.Fatalf("dockerLogFiles() error = %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	expected := []string{id + "-json.log.10", id + "-json.log.2.gz", id + "-json.log.1", id + "-json.log"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected files %v, got %v", expected, names)
+	}
+}
+
+// writeTestFile writes the content to the file, gzipping it if the file has a .gz extension
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	data := []byte(content)
+	if strings.HasSuffix(path, ".gz") {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write(data)
+		_ = gz.Close()
+		data = buf.Bytes()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,33 @@
+package container_logs
+
+import (
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-core/sources/spool"
+	"github.com/turbot/tailpipe-plugin-sdk/collection_state"
+)
+
+// ContainerLogsCollectionState is the collection state used by the ContainerLogsSource
+// It tracks a cursor for each container log (i.e. the timestamp of the last record collected),
+// so subsequent collections only read new records
+type ContainerLogsCollectionState = spool.CursorCollectionState[ContainerLogCursor]
+
+// ContainerLogCursor is the position of the last record collected for a container log
+// log records do not have a sequence number, so the cursor is the timestamp of the last record, and the number
+// of records collected with that timestamp
+type ContainerLogCursor struct {
+	Timestamp time.Time `json:"timestamp"`
+	Count     int       `json:"count"`
+}
+
+// after returns whether the cursor is after the other cursor
+func (c *ContainerLogCursor) after(other *ContainerLogCursor) bool {
+	if c.Timestamp.Equal(other.Timestamp) {
+		return c.Count > other.Count
+	}
+	return c.Timestamp.After(other.Timestamp)
+}
+
+func NewContainerLogsCollectionState() collection_state.CollectionState {
+	return spool.NewCursorCollectionState((*ContainerLogCursor).after)
+}
@@ -0,0 +1,226 @@
+package container_logs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-core/sources/spool"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// recordsPerArtifact is the number of log records written to each artifact
+const recordsPerArtifact = 10000
+
+// ContainerLogsSource is an artifact source which reads container logs written by the Docker json-file logging
+// driver and by Kubernetes (the CRI log format), reassembling lines which have been split by the runtime
+// Each record is written as a JSON object to an artifact in the temp dir, along with the container metadata
+// (container id, name and image, pod namespace, name and uid, and labels) read from the container config or log path
+// - so the jsonl format should be used to map the fields to columns
+// The timestamp of the last record collected is stored in the collection state (for each container log),
+// so subsequent collections only read new records
+type ContainerLogsSource struct {
+	artifact_source.ArtifactSourceImpl[*ContainerLogsSourceConfig, *artifact_source.EmptyConnection]
+}
+
+// containerLogRow is the JSON object written for each record
+type containerLogRow struct {
+	Timestamp string `json:"timestamp"`
+	Stream    string `json:"stream"`
+	Log       string `json:"log"`
+	Runtime   string `json:"runtime"`
+	containerMetadata
+}
+
+func (s *ContainerLogsSource) Init(ctx context.Context, params *row_source.RowSourceParams, opts ...row_source.RowSourceOption) error {
+	// use the container logs collection state - this tracks the cursor for each container log, rather than the artifacts
+	s.NewCollectionStateFunc = NewContainerLogsCollectionState
+
+	// call base to parse config and apply options
+	return s.ArtifactSourceImpl.Init(ctx, params, opts...)
+}
+
+func (s *ContainerLogsSource) Identifier() string {
+	return ContainerLogsSourceIdentifier
+}
+
+// DiscoverArtifacts reads the new records from all container logs, writing them to artifacts in the temp dir,
+// and notifies observers of each artifact as it is completed
+// Errors reading a container log are not fatal - they are notified and the next log is read
+func (s *ContainerLogsSource) DiscoverArtifacts(ctx context.Context) error {
+	executionId, err := context_values.ExecutionIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	logs := s.findContainerLogs(ctx, executionId)
+
+	var cursors map[string]ContainerLogCursor
+	if state := s.containerLogsCollectionState(); state != nil {
+		cursors = state.GetCursors()
+	}
+
+	for _, l := range logs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var cursor *ContainerLogCursor
+		if c, ok := cursors[l.key]; ok {
+			cursor = &c
+		}
+		if err := s.readContainerLog(ctx, executionId, l, cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DownloadArtifact does nothing as the artifact has already been spooled to the temp dir
+func (s *ContainerLogsSource) DownloadArtifact(ctx context.Context, info *types.ArtifactInfo) error {
+	downloadInfo, err := spool.NewDownloadedArtifactInfo(info)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDownloaded(ctx, downloadInfo)
+}
+
+// readContainerLog writes the records of the container log after the cursor to artifacts
+// only errors writing the artifacts are returned - errors reading the log are notified
+func (s *ContainerLogsSource) readContainerLog(ctx context.Context, executionId string, l *containerLog, cursor *ContainerLogCursor) error {
+	// the cursors for the records in the current artifact
+	artifactCursors := make(map[string]*ContainerLogCursor)
+	w := spool.NewWriter(s.TempDir, "container_logs.jsonl", recordsPerArtifact, func(path string) error {
+		defer clear(artifactCursors)
+		return s.discoverArtifact(ctx, path, l.location(), artifactCursors)
+	})
+	defer w.Close()
+
+	metadata := l.metadata
+	if metadata.Labels == nil {
+		metadata.Labels = map[string]string{}
+	}
+
+	// the position of the current record - the number of records read with the same timestamp
+	var position ContainerLogCursor
+	var writeErr error
+	var count int
+	invalid, readErr := l.records(func(record *containerLogRecord) error {
+		if record.timestamp.Equal(position.Timestamp) {
+			position.Count++
+		} else {
+			position = ContainerLogCursor{Timestamp: record.timestamp, Count: 1}
+		}
+		if cursor != nil && !position.after(cursor) {
+			return nil
+		}
+		if !s.inCollectionTimeRange(record.timestamp) {
+			return nil
+		}
+
+		line, err := json.Marshal(containerLogRow{
+			Timestamp:         record.timestamp.UTC().Format(time.RFC3339Nano),
+			Stream:            record.stream,
+			Log:               record.log,
+			Runtime:           l.runtime,
+			containerMetadata: metadata,
+		})
+		if err != nil {
+			writeErr = err
+			return err
+		}
+		// set the cursor before writing, as the write may complete the artifact
+		recordPosition := position
+		artifactCursors[l.key] = &recordPosition
+		if err := w.WriteLine(line); err != nil {
+			writeErr = err
+			return err
+		}
+		count++
+		return nil
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if readErr != nil {
+		slog.Warn("ContainerLogsSource.DiscoverArtifacts error reading container log", "path", l.location(), "error", readErr)
+		s.NotifyError(ctx, executionId, readErr)
+	}
+	if invalid > 0 {
+		slog.Warn("ContainerLogsSource.DiscoverArtifacts invalid lines in container log", "path", l.location(), "count", invalid)
+		s.NotifyError(ctx, executionId, fmt.Errorf("%s: skipped %d invalid log lines", l.location(), invalid))
+	}
+	slog.Info("ContainerLogsSource.DiscoverArtifacts read container log", "path", l.location(), "records", count)
+
+	// write any records we read before an error
+	return w.Flush()
+}
+
+// discoverArtifact notifies observers of a completed artifact, after storing the cursors for its records
+func (s *ContainerLogsSource) discoverArtifact(ctx context.Context, path, location string, cursors map[string]*ContainerLogCursor) error {
+	info, err := spool.NewArtifactInfo(path, ContainerLogsSourceIdentifier, location, nil)
+	if err != nil {
+		return err
+	}
+	if state := s.containerLogsCollectionState(); state != nil {
+		state.SetPendingCursors(info.Identifier(), cursors)
+	}
+	return s.OnArtifactDiscovered(ctx, info)
+}
+
+// findContainerLogs returns the container logs in the Docker containers and Kubernetes pod log directories
+// missing directories and errors reading directories are not fatal - they are notified
+func (s *ContainerLogsSource) findContainerLogs(ctx context.Context, executionId string) []*containerLog {
+	notify := func(err error) {
+		slog.Warn("ContainerLogsSource.DiscoverArtifacts error finding container logs", "error", err)
+		s.NotifyError(ctx, executionId, err)
+	}
+
+	var res []*containerLog
+	if root := s.Config.GetDockerPath(); s.pathExists(root, s.Config.DockerPath != nil, notify) {
+		res = append(res, findDockerLogs(root, notify)...)
+	}
+	if root := s.Config.GetPodsPath(); s.pathExists(root, s.Config.PodsPath != nil, notify) {
+		res = append(res, findPodLogs(root, notify)...)
+	}
+	return res
+}
+
+// pathExists returns whether the path is set and exists
+// the default paths may not exist (e.g. if Docker is not installed) - so we only notify for configured paths
+func (s *ContainerLogsSource) pathExists(path string, configured bool, notify func(error)) bool {
+	if path == "" {
+		return false
+	}
+	if _, err := os.Stat(path); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) || configured {
+			notify(fmt.Errorf("%s: path does not exist", path))
+		}
+		return false
+	}
+	return true
+}
+
+// inCollectionTimeRange returns whether the timestamp falls within the collection time range
+func (s *ContainerLogsSource) inCollectionTimeRange(ts time.Time) bool {
+	if ts.Before(s.CollectionTimeRange.LowerBoundary) {
+		return false
+	}
+	return s.CollectionTimeRange.UpperBoundary.IsZero() || ts.Before(s.CollectionTimeRange.UpperBoundary)
+}
+
+// containerLogsCollectionState returns our collection state as a ContainerLogsCollectionState
+func (s *ContainerLogsSource) containerLogsCollectionState() *ContainerLogsCollectionState {
+	if s.CollectionState == nil {
+		return nil
+	}
+	state, _ := s.CollectionState.State.(*ContainerLogsCollectionState)
+	return state
+}
@@ -0,0 +1,53 @@
+package container_logs
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source_config"
+)
+
+const (
+	ContainerLogsSourceIdentifier = "container_logs"
+)
+
+const (
+	defaultDockerPath = "/var/lib/docker/containers"
+	defaultPodsPath   = "/var/log/pods"
+)
+
+type ContainerLogsSourceConfig struct {
+	artifact_source_config.ArtifactSourceConfigImpl
+	// required to allow partial decoding
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// the Docker containers directory, containing a directory for each container with its json-file driver log
+	// and config.v2.json (default /var/lib/docker/containers) - set to "" to not read Docker logs
+	DockerPath *string `hcl:"docker_path,optional"`
+	// the Kubernetes pod log directory, containing the CRI format logs of each pod (default /var/log/pods)
+	// set to "" to not read pod logs
+	PodsPath *string `hcl:"pods_path,optional"`
+}
+
+func (c *ContainerLogsSourceConfig) Validate() error {
+	// validate the base fields
+	return c.ArtifactSourceConfigImpl.Validate()
+}
+
+func (c *ContainerLogsSourceConfig) Identifier() string {
+	return ContainerLogsSourceIdentifier
+}
+
+// GetDockerPath returns the Docker containers directory, or "" if Docker logs should not be read
+func (c *ContainerLogsSourceConfig) GetDockerPath() string {
+	if c.DockerPath == nil {
+		return defaultDockerPath
+	}
+	return *c.DockerPath
+}
+
+// GetPodsPath returns the Kubernetes pod log directory, or "" if pod logs should not be read
+func (c *ContainerLogsSourceConfig) GetPodsPath() string {
+	if c.PodsPath == nil {
+		return defaultPodsPath
+	}
+	return *c.PodsPath
+}
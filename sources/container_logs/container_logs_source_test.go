@@ -0,0 +1,235 @@
+package container_logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/events"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+type rowObserver struct {
+	Rows []string
+	mut  sync.Mutex
+}
+
+func (r *rowObserver) Notify(_ context.Context, e events.Event) error {
+	if row, ok := e.(*events.RowExtracted); ok {
+		r.mut.Lock()
+		r.Rows = append(r.Rows, row.Row.(string))
+		r.mut.Unlock()
+	}
+	return nil
+}
+
+const (
+	testContainerId = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testPodId       = "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	testPodUid      = "8a5c1f3e-1111-2222-3333-444455556666"
+)
+
+func TestContainerLogsSource_Collect(t *testing.T) {
+	dir := t.TempDir()
+	dockerDir := filepath.Join(dir, "docker", "containers")
+	podsDir := filepath.Join(dir, "log", "pods")
+	statePath := filepath.Join(dir, "state.json")
+	tempDir := filepath.Join(dir, "collection")
+
+	// the records must be within the default collection time range
+	start := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	ts := func(seconds float64) string {
+		return start.Add(time.Duration(seconds * float64(time.Second))).Format(time.RFC3339Nano)
+	}
+
+	// a docker container, with a rotated log and a line split across two entries
+	containerDir := filepath.Join(dockerDir, testContainerId)
+	writeTestFile(t, filepath.Join(containerDir, "config.v2.json"),
+		`{"ID":"`+testContainerId+`","Name":"/web","Config":{"Image":"nginx:1.27","Labels":{"app":"web"}}}`)
+	writeTestFile(t, filepath.Join(containerDir, testContainerId+"-json.log.1"),
+		`{"log":"docker 1\n","stream":"stdout","time":"`+ts(0)+`"}`+"\n")
+	dockerLog := filepath.Join(containerDir, testContainerId+"-json.log")
+	dockerLines := []string{
+		`{"log":"docker ","stream":"stderr","time":"` + ts(1) + `"}`,
+		`{"log":"2\n","stream":"stderr","time":"` + ts(1.5) + `"}`,
+	}
+	writeTestFile(t, dockerLog, strings.Join(dockerLines, "\n")+"\n")
+
+	// a kubernetes pod, with the container id symlink created by the kubelet
+	podLog := filepath.Join(podsDir, "default_api-7d9f_"+testPodUid, "api", "0.log")
+	podLines := []string{
+		ts(0) + " stdout F pod 1",
+		// the second and third records have the same timestamp
+		ts(1) + " stdout P pod ",
+		ts(1) + " stdout F 2",
+		ts(1) + " stdout F pod 3",
+	}
+	writeTestFile(t, podLog, strings.Join(podLines, "\n")+"\n")
+	if err := os.MkdirAll(filepath.Join(dir, "log", "containers"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(podLog, filepath.Join(dir, "log", "containers", "api-7d9f_default_api-"+testPodId+".log")); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := collectContainerLogs(t, dockerDir, podsDir, statePath, tempDir)
+	assertLogs(t, "initial collection", rows, []string{"docker 1", "docker 2", "pod 1", "pod 2", "pod 3"})
+
+	// check the metadata of each runtime
+	for _, row := range rows {
+		switch row["log"] {
+		case "docker 2":
+			expected := map[string]any{
+				"timestamp":      ts(1),
+				"stream":         "stderr",
+				"log":            "docker 2",
+				"runtime":        "docker",
+				"container_id":   testContainerId,
+				"container_name": "web",
+				"image":          "nginx:1.27",
+				"namespace":      "",
+				"pod":            "",
+				"pod_uid":        "",
+				"labels":         map[string]any{"app": "web"},
+			}
+			if !reflect.DeepEqual(row, expected) {
+				t.Errorf("expected row %v, got %v", expected, row)
+			}
+		case "pod 2":
+			expected := map[string]any{
+				"timestamp":      ts(1),
+				"stream":         "stdout",
+				"log":            "pod 2",
+				"runtime":        "cri",
+				"container_id":   testPodId,
+				"container_name": "api",
+				"image":          "",
+				"namespace":      "default",
+				"pod":            "api-7d9f",
+				"pod_uid":        testPodUid,
+				"labels":         map[string]any{},
+			}
+			if !reflect.DeepEqual(row, expected) {
+				t.Errorf("expected row %v, got %v", expected, row)
+			}
+		}
+	}
+
+	// nothing new has been written
+	rows = collectContainerLogs(t, dockerDir, podsDir, statePath, tempDir)
+	assertLogs(t, "no new records", rows, nil)
+
+	// new lines are written, including one with the same timestamp as the last record collected
+	writeTestFile(t, dockerLog, strings.Join(append(dockerLines, `{"log":"docker 3\n","stream":"stdout","time":"`+ts(2)+`"}`), "\n")+"\n")
+	writeTestFile(t, podLog, strings.Join(append(podLines, ts(1)+" stdout F pod 4", ts(2)+" stdout F pod 5"), "\n")+"\n")
+	rows = collectContainerLogs(t, dockerDir, podsDir, statePath, tempDir)
+	assertLogs(t, "new records", rows, []string{"docker 3", "pod 4", "pod 5"})
+}
+
+func TestContainerLogsSource_CollectionStateOnlyContainsCursors(t *testing.T) {
+	dir := t.TempDir()
+	dockerDir := filepath.Join(dir, "docker", "containers")
+	podsDir := filepath.Join(dir, "log", "pods")
+	statePath := filepath.Join(dir, "state.json")
+	tempDir := filepath.Join(dir, "collection")
+
+	start := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	line := func(n int) string {
+		return fmt.Sprintf(`{"log":"docker %d\n","stream":"stdout","time":"%s"}`, n, start.Add(time.Duration(n)*time.Second).Format(time.RFC3339Nano))
+	}
+	containerDir := filepath.Join(dockerDir, testContainerId)
+	writeTestFile(t, filepath.Join(containerDir, "config.v2.json"), `{"ID":"`+testContainerId+`","Name":"/web"}`)
+	dockerLog := filepath.Join(containerDir, testContainerId+"-json.log")
+	writeTestFile(t, dockerLog, line(1)+"\n")
+	collectContainerLogs(t, dockerDir, podsDir, statePath, tempDir)
+	writeTestFile(t, dockerLog, line(1)+"\n"+line(2)+"\n")
+	collectContainerLogs(t, dockerDir, podsDir, statePath, tempDir)
+
+	// the spooled artifacts must not be stored in the state, otherwise it would grow with every collection
+	stateBytes, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		State map[string]json.RawMessage `json:"state"`
+	}
+	if err := json.Unmarshal(stateBytes, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.State) != 1 || saved.State["cursors"] == nil {
+		t.Fatalf("expected the collection state to only contain cursors, got %s", stateBytes)
+	}
+	var cursors map[string]ContainerLogCursor
+	if err := json.Unmarshal(saved.State["cursors"], &cursors); err != nil {
+		t.Fatal(err)
+	}
+	if len(cursors) != 1 {
+		t.Fatalf("expected a single cursor, got %v", cursors)
+	}
+	for _, cursor := range cursors {
+		if !cursor.Timestamp.Equal(start.Add(2 * time.Second)) {
+			t.Errorf("expected cursor at %s, got %s", start.Add(2*time.Second), cursor.Timestamp)
+		}
+	}
+}
+
+// collectContainerLogs runs a collection of the given directories and returns the rows extracted
+func collectContainerLogs(t *testing.T, dockerDir, podsDir, statePath, tempDir string) []map[string]any {
+	ctx := context_values.WithExecutionId(context.Background(), "test")
+
+	s := &ContainerLogsSource{}
+	any(s).(row_source.BaseSource).RegisterSource(s)
+
+	hclBytes := []byte(fmt.Sprintf("docker_path = %q\npods_path = %q", dockerDir, podsDir))
+	err := s.Init(ctx, &row_source.RowSourceParams{
+		SourceConfigData:    types.NewSourceConfigData(hclBytes, hcl.Range{}, ContainerLogsSourceIdentifier),
+		CollectionStatePath: statePath,
+		CollectionTempDir:   tempDir,
+	}, artifact_source.WithRowPerLine())
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+
+	var observer rowObserver
+	_ = s.AddObserver(&observer)
+
+	if err := s.Collect(ctx); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if err := s.OnCollectionComplete(); err != nil {
+		t.Fatalf("OnCollectionComplete() error = %v", err)
+	}
+
+	var rows []map[string]any
+	for _, line := range observer.Rows {
+		var row map[string]any
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("invalid row %s: %v", line, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func assertLogs(t *testing.T, name string, rows []map[string]any, expected []string) {
+	var logs []string
+	for _, row := range rows {
+		logs = append(logs, row["log"].(string))
+	}
+	slices.Sort(logs)
+	if !slices.Equal(logs, expected) {
+		t.Fatalf("%s: expected logs %v, got %v", name, expected, logs)
+	}
+}
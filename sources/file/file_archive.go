@@ -0,0 +1,202 @@
+package file
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/elastic/go-grok"
+	"github.com/turbot/pipe-fittings/v2/filter"
+	"github.com/turbot/tailpipe-plugin-core/compression"
+)
+
+// archiveMemberSeparator separates the archive path from the member path in the name of an archive member artifact,
+// e.g. /var/support/bundle.tar.gz!/var/log/syslog
+const archiveMemberSeparator = "!/"
+
+// tarArchiveExtensions are the (lower case) file extensions of the tar archives which are expanded by the file source
+// (the compressed forms must also have a compressed extension, so the archive is decompressed as it is read)
+var tarArchiveExtensions = []string{".tar", ".tar.gz", ".tgz", ".tar.zst", ".tar.bz2", ".tbz2", ".tar.xz", ".txz", ".tar.lz4"}
+
+// isTarArchive returns whether the file is a tar archive (based on its extension)
+func isTarArchive(path string) bool {
+	lowerPath := strings.ToLower(path)
+	for _, ext := range tarArchiveExtensions {
+		if strings.HasSuffix(lowerPath, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveMemberName returns the artifact name for a member of an archive
+func archiveMemberName(archivePath, memberPath string) string {
+	return archivePath + archiveMemberSeparator + memberPath
+}
+
+// splitArchiveMemberName splits the name of an archive member artifact into the archive path and member path
+// if the name is not the name of an archive member, ok is false
+func splitArchiveMemberName(name string) (archivePath, memberPath string, ok bool) {
+	// the archive path may itself contain the separator, so try each occurrence in turn
+	for i := strings.Index(name, archiveMemberSeparator); i != -1; {
+		if isTarArchive(name[:i]) {
+			return name[:i], name[i+len(archiveMemberSeparator):], true
+		}
+		next := strings.Index(name[i+1:], archiveMemberSeparator)
+		if next == -1 {
+			break
+		}
+		i += next + 1
+	}
+	return "", "", false
+}
+
+// walkArchive discovers the members of a tar archive as artifacts
+// the archive is treated as a directory, so the file layout and the include/exclude patterns are matched against the
+// member path within the archive, and the size limits and modification time filter are applied to each member
+// The archive is read once, and nothing is extracted - the position of each member within the archive is recorded,
+// so the member can be read directly from the archive when it is loaded (see archiveMembers)
+// Errors reading the archive are not fatal - they are notified and the members read before the error are discovered
+func (s *FileSource) walkArchive(ctx context.Context, executionId, archivePath string, pathFilter *pathFilter, layouts []string, g *grok.Grok, filterMap map[string]*filter.SqlFilter) error {
+	basePath := strings.TrimSuffix(archiveMemberName(archivePath, ""), "/")
+
+	// a member may appear more than once in an archive - only discover it once
+	discovered := make(map[string]struct{})
+	err := readTarArchive(archivePath, func(memberPath string, header *tar.Header, offset int64) (bool, error) {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if _, ok := discovered[memberPath]; ok {
+			return true, nil
+		}
+		discovered[memberPath] = struct{}{}
+
+		name := archiveMemberName(archivePath, memberPath)
+		if s.skipArchiveMember(pathFilter, name, memberPath, header) {
+			return true, nil
+		}
+
+		s.archiveMembers.add(name, &archiveMember{
+			archivePath: archivePath,
+			path:        memberPath,
+			offset:      offset,
+			size:        header.Size,
+			modTime:     header.ModTime,
+		})
+		if err := s.WalkNode(ctx, name, basePath, layouts, false, g, filterMap); err != nil {
+			return false, &walkNodeError{err}
+		}
+		return true, nil
+	})
+
+	var walkErr *walkNodeError
+	if errors.As(err, &walkErr) {
+		return walkErr.err
+	}
+	if err != nil {
+		slog.Warn("FileSource.DiscoverArtifacts error reading archive", "archive", archivePath, "error", err)
+		s.NotifyError(ctx, executionId, fmt.Errorf("%s: unable to read archive: %w", archivePath, err))
+	}
+	return nil
+}
+
+// skipArchiveMember returns whether the archive member should be skipped, based on the include/exclude patterns
+// (a member is skipped if any of the directories containing it are excluded), its size or its modification time
+func (s *FileSource) skipArchiveMember(pathFilter *pathFilter, name, memberPath string, header *tar.Header) bool {
+	for dir := path.Dir(memberPath); dir != "."; dir = path.Dir(dir) {
+		if pathFilter.skipRelPath(dir, true) {
+			return true
+		}
+	}
+	if pathFilter.skipRelPath(memberPath, false) {
+		return true
+	}
+	return s.skipFileInfo(name, fs.FileInfoToDirEntry(header.FileInfo()))
+}
+
+// walkNodeError wraps an error returned by WalkNode, so it can be distinguished from an error reading the archive
+type walkNodeError struct {
+	err error
+}
+
+func (e *walkNodeError) Error() string {
+	return e.err.Error()
+}
+
+// readTarArchive calls the given function for each regular file in the tar archive, until the function returns false
+// the member path is the cleaned, slash separated path of the file within the archive, and the offset is the position
+// of the file data within the (decompressed) tar stream
+// compressed archives are decompressed as they are read
+func readTarArchive(archivePath string, fn func(memberPath string, header *tar.Header, offset int64) (bool, error)) error {
+	r, err := openTarArchive(archivePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// NOTE: the tar reader does not read ahead, so once a header has been read, the number of bytes read is the
+	// offset of the file data
+	cr := &countingReader{r: r}
+	tr := tar.NewReader(cr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		memberPath, ok := cleanMemberPath(header.Name)
+		if !ok {
+			slog.Debug("FileSource skipping archive member with invalid path", "archive", archivePath, "member", header.Name)
+			continue
+		}
+		more, err := fn(memberPath, header, cr.n)
+		if err != nil || !more {
+			return err
+		}
+	}
+}
+
+// countingReader is an io.Reader which counts the bytes read
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// openTarArchive opens the tar archive, decompressing it if it is compressed
+func openTarArchive(archivePath string) (io.ReadCloser, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return compression.Decompress(f, archivePath)
+}
+
+// cleanMemberPath returns the cleaned path of an archive member
+// members with an absolute path, or a path outside the archive root, are not valid
+func cleanMemberPath(name string) (string, bool) {
+	memberPath := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	memberPath = strings.TrimPrefix(memberPath, "./")
+	if memberPath == "." || path.IsAbs(memberPath) || memberPath == ".." || strings.HasPrefix(memberPath, "../") {
+		return "", false
+	}
+	return memberPath, true
+}
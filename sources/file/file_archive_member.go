@@ -0,0 +1,185 @@
+package file
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-core/compression"
+)
+
+// maxIdleArchiveStreams is the maximum number of decompressed streams kept open for each compressed archive
+const maxIdleArchiveStreams = 4
+
+// archiveMember is a member of a tar archive which has been discovered as an artifact
+type archiveMember struct {
+	archivePath string
+	// the cleaned path of the member within the archive
+	path string
+	// the position of the member data within the (decompressed) tar stream
+	offset int64
+	size   int64
+	// the modification time of the member, from its tar header
+	modTime time.Time
+}
+
+// canStream returns whether the archive member can be loaded directly from the archive
+// zip files must be read from a local file
+func canStream(memberPath string) bool {
+	return compression.Detect(memberPath) != compression.Zip
+}
+
+// archiveMembers records the archive members which have been discovered, keyed by artifact name, and reads their
+// data directly from the archive, so nothing is extracted to disk
+// The data of a member of an uncompressed archive is read at its offset. A compressed archive must be decompressed from
+// the start to reach a member, so once a member has been read the decompressed stream is kept open, positioned at the
+// end of the member, and later members are read from it. As members are generally loaded in the order they were
+// discovered, a compressed archive is usually only decompressed once
+type archiveMembers struct {
+	members sync.Map
+
+	mut sync.Mutex
+	// the idle decompressed streams of each compressed archive, keyed by archive path
+	streams map[string][]*archiveStream
+	closed  bool
+}
+
+// archiveStream is a decompressed tar stream, and the position it has been read to
+type archiveStream struct {
+	r   io.ReadCloser
+	pos int64
+}
+
+func (a *archiveMembers) add(name string, member *archiveMember) {
+	a.members.Store(name, member)
+}
+
+func (a *archiveMembers) get(name string) (*archiveMember, bool) {
+	v, ok := a.members.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(*archiveMember), true
+}
+
+// open returns a reader for the data of the archive member, as stored in the archive (i.e. a compressed member is
+// not decompressed)
+func (a *archiveMembers) open(member *archiveMember) (io.ReadCloser, error) {
+	if compression.Detect(member.archivePath) == compression.None {
+		f, err := os.Open(member.archivePath)
+		if err != nil {
+			return nil, err
+		}
+		return compression.NewReadCloser(io.NewSectionReader(f, member.offset, member.size), f), nil
+	}
+
+	stream, err := a.takeStream(member.archivePath, member.offset)
+	if err != nil {
+		return nil, err
+	}
+	// skip to the member data
+	if _, err := io.CopyN(io.Discard, stream.r, member.offset-stream.pos); err != nil {
+		stream.r.Close()
+		return nil, fmt.Errorf("unable to read to %s: %w", member.path, err)
+	}
+	stream.pos = member.offset
+	return &archiveMemberReader{archives: a, archivePath: member.archivePath, stream: stream, remaining: member.size}, nil
+}
+
+// takeStream removes the idle stream of the archive which is positioned closest before the offset from the idle
+// streams, and returns it - if there is no such stream, the archive is opened
+func (a *archiveMembers) takeStream(archivePath string, offset int64) (*archiveStream, error) {
+	a.mut.Lock()
+	streams := a.streams[archivePath]
+	best := -1
+	for i, stream := range streams {
+		if stream.pos <= offset && (best == -1 || stream.pos > streams[best].pos) {
+			best = i
+		}
+	}
+	if best != -1 {
+		stream := streams[best]
+		a.streams[archivePath] = slices.Delete(streams, best, best+1)
+		a.mut.Unlock()
+		return stream, nil
+	}
+	a.mut.Unlock()
+
+	r, err := openTarArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveStream{r: r}, nil
+}
+
+// putStream adds the stream to the idle streams of the archive, so later members can be read from it
+func (a *archiveMembers) putStream(archivePath string, stream *archiveStream) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	if a.closed || len(a.streams[archivePath]) >= maxIdleArchiveStreams {
+		stream.r.Close()
+		return
+	}
+	if a.streams == nil {
+		a.streams = make(map[string][]*archiveStream)
+	}
+	a.streams[archivePath] = append(a.streams[archivePath], stream)
+}
+
+// close closes the idle streams
+func (a *archiveMembers) close() {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	for _, streams := range a.streams {
+		for _, stream := range streams {
+			stream.r.Close()
+		}
+	}
+	a.streams = nil
+	a.closed = true
+}
+
+// archiveMemberReader reads the data of a member of a compressed archive from a decompressed tar stream
+// when it is closed, the stream is returned to the idle streams of the archive, unless it could not be read
+type archiveMemberReader struct {
+	archives    *archiveMembers
+	archivePath string
+	stream      *archiveStream
+	remaining   int64
+	err         error
+}
+
+func (r *archiveMemberReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.stream.r.Read(p)
+	r.remaining -= int64(n)
+	r.stream.pos += int64(n)
+	if err == io.EOF && r.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *archiveMemberReader) Close() error {
+	if r.stream == nil {
+		return nil
+	}
+	stream := r.stream
+	r.stream = nil
+	if r.err != nil {
+		return stream.r.Close()
+	}
+	r.archives.putStream(r.archivePath, stream)
+	return nil
+}
@@ -0,0 +1,327 @@
+package file
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/turbot/tailpipe-plugin-core/compression"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+func TestFileSource_Archives(t *testing.T) {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".tar.zst", ".tar.xz", ".txz", ".tar.lz4"} {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+			logDir := filepath.Join(dir, "bundles")
+			archivePath := filepath.Join(logDir, "bundle"+ext)
+			writeTestArchive(t, archivePath, []testArchiveMember{
+				{name: "./var/log/", dir: true},
+				{name: "./var/log/app.log", content: "app 1\napp 2\n"},
+				{name: "./var/log/app.log.1.gz", content: "rotated 1\n", gzip: true},
+				{name: "./etc/app.conf", content: "not a log\n"},
+				{name: "../outside.log", content: "outside the archive root\n"},
+			})
+
+			rows, artifacts := collectFiles(t, logDir, "var/log/%{DATA}", filepath.Join(dir, "state.json"), filepath.Join(dir, "collection"))
+			assertRows(t, "archive members", rows, []string{"app 1", "app 2", "rotated 1"})
+			assertRows(t, "archive artifacts", artifacts, []string{
+				archivePath + "!/var/log/app.log",
+				archivePath + "!/var/log/app.log.1.gz",
+			})
+		})
+	}
+}
+
+func TestFileSource_Bzip2Archives(t *testing.T) {
+	for _, ext := range []string{".tar.bz2", ".tbz2"} {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+			logDir := filepath.Join(dir, "bundles")
+			archivePath := filepath.Join(logDir, "bundle"+ext)
+			data, err := os.ReadFile(bzip2TestArchive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(logDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(archivePath, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// the members are collected, rather than the tar stream being collected as text
+			// (the mod time filter is disabled, as the members of the test archive were last modified long ago)
+			rows, artifacts := collectFiles(t, logDir, "var/log/%{DATA}", filepath.Join(dir, "state.json"), filepath.Join(dir, "collection"), "filter_mod_time = false")
+			assertRows(t, "archive members", rows, []string{"bzip2 app 1", "bzip2 app 2"})
+			assertRows(t, "archive artifacts", artifacts, []string{archivePath + "!/var/log/app.log"})
+		})
+	}
+}
+
+func TestFileSource_InvalidArchive(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "bundles")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "bad.tar.gz"), []byte("not a gzip file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, filepath.Join(logDir, "app.log"), "app 1")
+
+	// the invalid archive is reported, but does not stop the collection
+	rows, _ := collectFiles(t, logDir, "%{DATA}", filepath.Join(dir, "state.json"), filepath.Join(dir, "collection"))
+	assertRows(t, "invalid archive", rows, []string{"app 1"})
+}
+
+func TestSplitArchiveMemberName(t *testing.T) {
+	tests := []struct {
+		name           string
+		expectedOk     bool
+		expectedPath   string
+		expectedMember string
+	}{
+		{name: "/logs/bundle.tar.gz!/var/log/syslog", expectedOk: true, expectedPath: "/logs/bundle.tar.gz", expectedMember: "var/log/syslog"},
+		{name: "/logs/bundle.TGZ!/syslog", expectedOk: true, expectedPath: "/logs/bundle.TGZ", expectedMember: "syslog"},
+		{name: "/logs/a!/b.tar!/syslog", expectedOk: true, expectedPath: "/logs/a!/b.tar", expectedMember: "syslog"},
+		{name: "/logs/a!/syslog", expectedOk: false},
+		{name: "/logs/bundle.tar.gz", expectedOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archivePath, memberPath, ok := splitArchiveMemberName(tt.name)
+			if ok != tt.expectedOk || archivePath != tt.expectedPath || memberPath != tt.expectedMember {
+				t.Errorf("splitArchiveMemberName() = %q, %q, %v, expected %q, %q, %v", archivePath, memberPath, ok, tt.expectedPath, tt.expectedMember, tt.expectedOk)
+			}
+		})
+	}
+}
+
+func TestFileSource_ArchiveMemberFilters(t *testing.T) {
+	members := []testArchiveMember{
+		{name: "var/log/app.log", content: "app 1\n"},
+		{name: "var/log/app.log.swp", content: "swap 1\n"},
+		{name: "var/log/large.log", content: "large 1\nlarge 2\nlarge 3\nlarge 4\n"},
+		{name: "var/log/tmp/scratch.log", content: "scratch 1\n"},
+		{name: "var/log/notes.txt", content: "notes 1\n"},
+	}
+	tests := []struct {
+		name     string
+		config   []string
+		expected []string
+	}{
+		{name: "no filters", expected: []string{"app 1", "large 1", "large 2", "large 3", "large 4", "notes 1", "scratch 1", "swap 1"}},
+		// the include patterns apply to the members, not to the archive
+		{name: "include", config: []string{`include = ["*.log"]`}, expected: []string{"app 1", "large 1", "large 2", "large 3", "large 4", "scratch 1"}},
+		{name: "exclude", config: []string{`exclude = ["*.swp", "tmp/"]`}, expected: []string{"app 1", "large 1", "large 2", "large 3", "large 4", "notes 1"}},
+		{name: "exclude path", config: []string{`exclude = ["var/log/*.txt"]`}, expected: []string{"app 1", "large 1", "large 2", "large 3", "large 4", "scratch 1", "swap 1"}},
+		{name: "max_size", config: []string{"max_size = 10"}, expected: []string{"app 1", "notes 1", "scratch 1", "swap 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			logDir := filepath.Join(dir, "bundles")
+			writeTestArchive(t, filepath.Join(logDir, "bundle.tar.gz"), members)
+
+			rows, _ := collectFiles(t, logDir, "var/log/%{DATA}", filepath.Join(dir, "state.json"), filepath.Join(dir, "collection"), tt.config...)
+			assertRows(t, tt.name, rows, tt.expected)
+		})
+	}
+}
+
+func TestArchiveMembers_Open(t *testing.T) {
+	for _, ext := range []string{".tar", ".tar.gz"} {
+		t.Run(ext, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "bundle"+ext)
+			var members []testArchiveMember
+			for i := range 10 {
+				members = append(members, testArchiveMember{name: fmt.Sprintf("app%d.log", i), content: strings.Repeat(fmt.Sprintf("app %d\n", i), i*100)})
+			}
+			writeTestArchive(t, archivePath, members)
+
+			var archives archiveMembers
+			defer archives.close()
+			err := readTarArchive(archivePath, func(memberPath string, header *tar.Header, offset int64) (bool, error) {
+				archives.add(memberPath, &archiveMember{archivePath: archivePath, path: memberPath, offset: offset, size: header.Size})
+				return true, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// read the members in order, then out of order - each member is read from the archive
+			order := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 7, 2, 9, 0}
+			for _, i := range order {
+				m := members[i]
+				member, ok := archives.get(m.name)
+				if !ok {
+					t.Fatalf("member %s not found", m.name)
+				}
+				r, err := archives.open(member)
+				if err != nil {
+					t.Fatalf("open() error = %v", err)
+				}
+				data, err := io.ReadAll(r)
+				r.Close()
+				if err != nil {
+					t.Fatalf("error reading %s: %v", m.name, err)
+				}
+				if string(data) != m.content {
+					t.Errorf("expected %s to contain %d bytes of %q, got %d bytes", m.name, len(m.content), m.content[:min(len(m.content), 6)], len(data))
+				}
+			}
+		})
+	}
+}
+
+func TestFileSource_ArchiveMembersNotExtracted(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "bundles")
+	tempDir := filepath.Join(dir, "collection")
+	writeTestArchive(t, filepath.Join(logDir, "bundle.tar.zst"), []testArchiveMember{
+		{name: "var/log/app.log", content: "app 1\napp 2\n"},
+		{name: "var/log/app.log.1.gz", content: "rotated 1\n", gzip: true},
+		{name: "etc/app.conf", content: "not a log\n"},
+	})
+
+	rows, _ := collectFiles(t, logDir, "var/log/%{DATA}", filepath.Join(dir, "state.json"), tempDir)
+	assertRows(t, "archive members", rows, []string{"app 1", "app 2", "rotated 1"})
+
+	// the members are read directly from the archive, so nothing is written to the temp dir
+	err := filepath.WalkDir(tempDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			t.Errorf("unexpected file written to the temp dir: %s", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testArchiveMember struct {
+	name    string
+	content string
+	dir     bool
+	gzip    bool
+	// the modification time of the member (default now)
+	modTime time.Time
+}
+
+// writeTestArchive writes a tar archive containing the members, compressing it based on the file extension
+func writeTestArchive(t *testing.T, path string, members []testArchiveMember) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, m := range members {
+		if m.dir {
+			if err := tw.WriteHeader(&tar.Header{Name: m.name, Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		modTime := m.modTime
+		if modTime.IsZero() {
+			modTime = time.Now()
+		}
+		content := []byte(m.content)
+		if m.gzip {
+			var gzBuf bytes.Buffer
+			gz := gzip.NewWriter(&gzBuf)
+			_, _ = gz.Write(content)
+			_ = gz.Close()
+			content = gzBuf.Bytes()
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: m.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content)), ModTime: modTime}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	switch {
+	case strings.HasSuffix(path, ".gz"), strings.HasSuffix(path, ".tgz"):
+		var gzBuf bytes.Buffer
+		gz := gzip.NewWriter(&gzBuf)
+		_, _ = gz.Write(data)
+		_ = gz.Close()
+		data = gzBuf.Bytes()
+	case strings.HasSuffix(path, ".zst"):
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = enc.EncodeAll(data, nil)
+		_ = enc.Close()
+	case strings.HasSuffix(path, ".xz"), strings.HasSuffix(path, ".txz"):
+		data = compressTestData(t, compression.Xz, string(data))
+	case strings.HasSuffix(path, ".lz4"):
+		data = compressTestData(t, compression.Lz4, string(data))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// collectFiles runs a collection of the given directory and returns the rows extracted and the artifacts discovered
+// any additional config is appended to the source config
+func collectFiles(t *testing.T, logDir, layout, statePath, tempDir string, config ...string) ([]string, []string) {
+	return collectFilesWithOptions(t, []row_source.RowSourceOption{artifact_source.WithRowPerLine()}, logDir, layout, statePath, tempDir, config...)
+}
+
+// collectFilesWithOptions runs a collection of the given directory, initialising the source with the given options
+func collectFilesWithOptions(t *testing.T, opts []row_source.RowSourceOption, logDir, layout, statePath, tempDir string, config ...string) ([]string, []string) {
+	ctx := context_values.WithExecutionId(context.Background(), "test")
+
+	s := &FileSource{}
+	any(s).(row_source.BaseSource).RegisterSource(s)
+
+	hclBytes := []byte(strings.Join(append([]string{fmt.Sprintf("paths = [%q]", logDir)}, config...), "\n"))
+	err := s.Init(ctx, &row_source.RowSourceParams{
+		SourceConfigData:    types.NewSourceConfigData(hclBytes, hcl.Range{}, "file"),
+		CollectionStatePath: statePath,
+		CollectionTempDir:   tempDir,
+	}, opts...)
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	s.Config.FileLayout = &layout
+
+	var rowObserver rowObserver
+	var artifactObserver testObserver
+	_ = s.AddObserver(&rowObserver)
+	_ = s.AddObserver(&artifactObserver)
+
+	if err := s.Collect(ctx); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if err := s.OnCollectionComplete(); err != nil {
+		t.Fatalf("OnCollectionComplete() error = %v", err)
+	}
+	return rowObserver.Rows, slices.Clone(artifactObserver.Artifacts)
+}
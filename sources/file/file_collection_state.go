@@ -0,0 +1,178 @@
+package file
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/collection_state"
+)
+
+// FileCollectionState is the collection state used by the FileSource
+// It wraps the standard ArtifactCollectionState and additionally tracks, for each file collected in follow mode,
+// the byte offset we have read up to, along with the identity of the file (device/inode)
+// This allows us to only collect the new tail of a file which is being actively written to
+type FileCollectionState struct {
+	*collection_state.ArtifactCollectionState
+
+	// map of file path to the follow state for that file
+	Files map[string]*FileState `json:"files,omitempty"`
+
+	// are we in follow mode - this is set by the source after Init
+	follow bool
+	// map of file path to the follow state for a file which has been downloaded but not yet collected
+	// the state is moved into Files when OnCollected is called for the file
+	pending map[string]*FileState
+	// the Files map is accessed from the discovery and download goroutines, so we need our own lock
+	mut sync.RWMutex
+}
+
+// FileState is the follow state for a single file
+type FileState struct {
+	// the byte offset we have collected up to
+	Offset int64 `json:"offset"`
+	// the identity of the file - used to detect the file being replaced
+	Device uint64 `json:"device,omitempty"`
+	Inode  uint64 `json:"inode,omitempty"`
+	// a hash of the head of the file - used to recognise a rotated copy of the file,
+	// and to detect the file being truncated and rewritten
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// SameFile returns whether the given identity matches the identity of this file state
+func (f *FileState) SameFile(device, inode uint64) bool {
+	return f.Device == device && f.Inode == inode
+}
+
+func NewFileCollectionState() collection_state.CollectionState {
+	return &FileCollectionState{
+		ArtifactCollectionState: collection_state.NewArtifactCollectionState().(*collection_state.ArtifactCollectionState),
+		Files:                   make(map[string]*FileState),
+		pending:                 make(map[string]*FileState),
+	}
+}
+
+// Init trims any nil trunk states then calls the base Init
+// NOTE: RowSourceImpl only trims nil trunk states for an ArtifactCollectionState so we must do it ourselves
+func (s *FileCollectionState) Init(collectionTimeRange collection_state.DirectionalTimeRange, granularity time.Duration) {
+	s.TrimNilTrunkStates()
+	s.ArtifactCollectionState.Init(collectionTimeRange, granularity)
+}
+
+// ShouldCollect returns whether the file should be collected
+// If we are following files and we have a follow state for this file, we always collect it
+// - the source only asks us about followed files which have new data
+func (s *FileCollectionState) ShouldCollect(id string, timestamp time.Time) bool {
+	// always call the base ShouldCollect - this caches the trunk state for the file, which OnCollected relies on
+	shouldCollect := s.ArtifactCollectionState.ShouldCollect(id, timestamp)
+
+	if s.follow && s.GetFileState(id) != nil {
+		return true
+	}
+	return shouldCollect
+}
+
+// OnCollected is called when a file has been collected - if there is a pending follow state for the file,
+// store it so that the next collection continues from the new offset
+func (s *FileCollectionState) OnCollected(id string, timestamp time.Time) error {
+	if err := s.ArtifactCollectionState.OnCollected(id, timestamp); err != nil {
+		return err
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if fileState, ok := s.pending[id]; ok {
+		s.Files[id] = fileState
+		delete(s.pending, id)
+	}
+	return nil
+}
+
+// OnCollectionComplete trims any nil trunk states then calls the base OnCollectionComplete
+func (s *FileCollectionState) OnCollectionComplete() error {
+	s.TrimNilTrunkStates()
+	return s.ArtifactCollectionState.OnCollectionComplete()
+}
+
+// IsEmpty returns whether the collection state is empty
+func (s *FileCollectionState) IsEmpty() bool {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return len(s.Files) == 0 && s.ArtifactCollectionState.IsEmpty()
+}
+
+// Clear clears the collection state for the given time range
+// As we have no time information for the follow state, we clear the follow state for all files
+// (meaning followed files will be re-read from the start)
+func (s *FileCollectionState) Clear(timeRange collection_state.DirectionalTimeRange) {
+	s.mut.Lock()
+	s.Files = make(map[string]*FileState)
+	s.pending = make(map[string]*FileState)
+	s.mut.Unlock()
+
+	s.ArtifactCollectionState.Clear(timeRange)
+}
+
+// SetFollow sets whether we are following files
+func (s *FileCollectionState) SetFollow(follow bool) {
+	s.follow = follow
+}
+
+// GetFileState returns the follow state for the given file, or nil if we have none
+func (s *FileCollectionState) GetFileState(path string) *FileState {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return s.Files[path]
+}
+
+// SetFileState sets the follow state for the given file
+// this is used when we recognise a file as a rotation of a file we have already collected
+func (s *FileCollectionState) SetFileState(path string, fileState *FileState) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.Files[path] = fileState
+}
+
+// RemoveFileState removes the follow state for the given file
+func (s *FileCollectionState) RemoveFileState(path string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	delete(s.Files, path)
+}
+
+// GetFileStates returns a copy of the follow state for all files
+func (s *FileCollectionState) GetFileStates() map[string]*FileState {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	res := make(map[string]*FileState, len(s.Files))
+	for path, fileState := range s.Files {
+		fileStateCopy := *fileState
+		res[path] = &fileStateCopy
+	}
+	return res
+}
+
+// SetPendingFileState stores the follow state for a file which has been downloaded
+// this will be stored in Files when the file is collected
+func (s *FileCollectionState) SetPendingFileState(path string, fileState *FileState) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.pending[path] = fileState
+}
+
+// MarshalJSON locks the mutex before serialising the state
+// as the Files map may be updated by a download goroutine while the state is being saved
+func (s *FileCollectionState) MarshalJSON() ([]byte, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	// use a local type to avoid MarshalJSON recursing
+	type fileCollectionState FileCollectionState
+	return json.Marshal((*fileCollectionState)(s))
+}
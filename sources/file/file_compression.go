@@ -0,0 +1,16 @@
+package file
+
+import (
+	"github.com/turbot/tailpipe-plugin-core/compression"
+)
+
+// canFollow returns whether the file can be followed - compressed files are always collected in full
+func canFollow(filePath string) bool {
+	return compression.Detect(filePath) == compression.None
+}
+
+// canDecompress returns whether we can decompress the file to resume collecting a compressed rotation
+func canDecompress(filePath string) bool {
+	c := compression.Detect(filePath)
+	return c != compression.None && c != compression.Zip
+}
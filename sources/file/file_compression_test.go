@@ -0,0 +1,83 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/turbot/tailpipe-plugin-core/compression"
+	"github.com/ulikunitz/xz"
+)
+
+// bzip2TestFile is a bzip2 compressed file containing the lines "bzip2 1" and "bzip2 2"
+// (the standard library has no bzip2 writer, so this is checked in)
+const bzip2TestFile = "test_data/compressed/app.log.bz2"
+
+// bzip2TestArchive is a bzip2 compressed tar archive containing var/log/app.log (with the lines "bzip2 app 1" and
+// "bzip2 app 2") and etc/app.conf
+const bzip2TestArchive = "test_data/compressed/bundle.tar.bz2"
+
+func TestFileSource_CompressedFiles(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	bzip2Data, err := os.ReadFile(bzip2TestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"app.log":       []byte("plain 1\n"),
+		"app.log.1.zst": compressTestData(t, compression.Zstd, "zstd 1\nzstd 2\n"),
+		"app.log.2.xz":  compressTestData(t, compression.Xz, "xz 1\n"),
+		"app.log.3.lz4": compressTestData(t, compression.Lz4, "lz4 1\n"),
+		"app.log.4.bz2": bzip2Data,
+		// a compressed file without a compressed extension
+		"app.log.5": compressTestData(t, compression.Gzip, "gzip 1\n"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(logDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, _ := collectFiles(t, logDir, "%{DATA}", filepath.Join(dir, "state.json"), filepath.Join(dir, "collection"))
+	assertRows(t, "compressed files", rows, []string{"plain 1", "zstd 1", "zstd 2", "xz 1", "lz4 1", "bzip2 1", "bzip2 2", "gzip 1"})
+}
+
+// compressTestData compresses the data using the given compression format
+func compressTestData(t *testing.T, c compression.Format, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch c {
+	case compression.Gzip:
+		w = gzip.NewWriter(&buf)
+	case compression.Zstd:
+		w, err = zstd.NewWriter(&buf)
+	case compression.Xz:
+		w, err = xz.NewWriter(&buf)
+	case compression.Lz4:
+		w = lz4.NewWriter(&buf)
+	default:
+		t.Fatalf("unsupported compression %s", c)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(w, strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
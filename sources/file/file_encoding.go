@@ -0,0 +1,238 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/turbot/tailpipe-plugin-core/compression"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+// EncodingAuto detects the encoding of each file from its byte order mark - files without a byte order mark are UTF-8
+const EncodingAuto = "auto"
+
+// byteOrderMarks are the byte order marks recognised when the encoding is "auto"
+// NOTE: the UTF-32LE mark starts with the UTF-16LE mark, so must be checked first
+var byteOrderMarks = []struct {
+	name string
+	bom  []byte
+}{
+	{"UTF-32LE", []byte{0xff, 0xfe, 0x00, 0x00}},
+	{"UTF-32BE", []byte{0x00, 0x00, 0xfe, 0xff}},
+	{"UTF-8", []byte{0xef, 0xbb, 0xbf}},
+	{"UTF-16LE", []byte{0xff, 0xfe}},
+	{"UTF-16BE", []byte{0xfe, 0xff}},
+}
+
+// lookupEncoding returns the encoding with the given (IANA) name, e.g. UTF-16LE, ISO-8859-1 or Shift_JIS
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	// the IANA index does not support UTF-32
+	switch strings.ToUpper(name) {
+	case "UTF-32LE":
+		return utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM), nil
+	case "UTF-32BE":
+		return utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM), nil
+	}
+
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("encoding %s is not supported", name)
+	}
+	return enc, nil
+}
+
+// isWideEncoding returns whether the encoding is UTF-16 or UTF-32, in which a newline is more than one byte
+func isWideEncoding(name string) bool {
+	upper := strings.ToUpper(name)
+	return strings.HasPrefix(upper, "UTF-16") || strings.HasPrefix(upper, "UTF-32")
+}
+
+// validateEncoding returns an error if the encoding is not "auto" or a supported encoding
+func validateEncoding(name string) error {
+	if name == EncodingAuto {
+		return nil
+	}
+	_, err := lookupEncoding(name)
+	return err
+}
+
+// resolveEncoding returns the encoding of text which starts with the given bytes, and the length of its byte order mark
+// (0 if it does not start with the byte order mark for the encoding)
+// if the configured encoding is "auto", this is determined by the byte order mark - if there is none, it is UTF-8
+func resolveEncoding(head []byte, name string) (string, int) {
+	var bomName string
+	var bomLen int
+	for _, m := range byteOrderMarks {
+		if bytes.HasPrefix(head, m.bom) {
+			bomName, bomLen = m.name, len(m.bom)
+			break
+		}
+	}
+
+	if name == EncodingAuto {
+		if bomName == "" {
+			return "UTF-8", 0
+		}
+		return bomName, bomLen
+	}
+	if bomName != "" && strings.EqualFold(bomName, name) {
+		return name, bomLen
+	}
+	return name, 0
+}
+
+// isUtf8 returns whether the encoding name is UTF-8
+func isUtf8(name string) bool {
+	return strings.EqualFold(name, "UTF-8") || strings.EqualFold(name, "UTF8")
+}
+
+// decodeText wraps the reader in a reader which transcodes the text from the given encoding to UTF-8
+// any byte order mark is removed
+// It also returns the name of the resolved encoding, and a function which returns whether a line of the transcoded
+// text is valid - i.e. the original text contained no invalid sequences
+func decodeText(r io.ReadCloser, name string) (io.ReadCloser, string, func(string) bool, error) {
+	br := bufio.NewReader(r)
+	// an error here means the data is shorter than the longest byte order mark - just use what we have
+	head, _ := br.Peek(4)
+
+	name, bomLen := resolveEncoding(head, name)
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		r.Close()
+		return nil, "", nil, err
+	}
+	_, _ = br.Discard(bomLen)
+	text := compression.NewReadCloser(br, r)
+
+	// UTF-8 does not need transcoding, but may contain invalid sequences
+	if isUtf8(name) {
+		return text, name, utf8.ValidString, nil
+	}
+	// the decoder replaces invalid sequences with the replacement character
+	isValid := func(line string) bool {
+		return !strings.ContainsRune(line, utf8.RuneError)
+	}
+	return compression.NewReadCloser(enc.NewDecoder().Reader(text), text), name, isValid, nil
+}
+
+// fileEncoding returns the resolved encoding of the (decompressed) file
+func fileEncoding(filePath, name string) (string, error) {
+	r, err := compression.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	head := make([]byte, 4)
+	n, _ := io.ReadFull(r, head)
+	name, _ = resolveEncoding(head[:n], name)
+	return name, nil
+}
+
+// downloadTranscoded notifies observers of a downloaded file which has been transcoded to UTF-8 in the temp dir
+// (UTF-8 files are not copied)
+func (s *FileSource) downloadTranscoded(ctx context.Context, info *types.ArtifactInfo, localName string, fileInfo fs.FileInfo) error {
+	fileName := filepath.Base(localName)
+
+	encodingName, err := fileEncoding(localName, s.Config.GetEncoding())
+	if err != nil {
+		slog.Error("FileSource.DownloadArtifact error reading file", "file", localName, "error", err)
+		return fmt.Errorf("%s: unable to read file", fileName)
+	}
+	if isUtf8(encodingName) {
+		return s.OnArtifactDownloaded(ctx, types.NewDownloadedArtifactInfo(info, localName, fileInfo.Size()))
+	}
+
+	r, err := compression.Open(localName)
+	if err != nil {
+		slog.Error("FileSource.DownloadArtifact error opening file", "file", localName, "error", err)
+		return fmt.Errorf("%s: unable to open file", fileName)
+	}
+	tempName, size, err := s.transcodeToTemp(ctx, r, compression.DecompressedName(fileName))
+	if err != nil {
+		slog.Error("FileSource.DownloadArtifact error transcoding file", "file", localName, "encoding", encodingName, "error", err)
+		return fmt.Errorf("%s: unable to transcode file from %s", fileName, encodingName)
+	}
+	// the copy has the modification time of the file, as loaders may use it (e.g. to infer the year of timestamps)
+	setModTime(tempName, fileInfo.ModTime())
+	return s.OnArtifactDownloaded(ctx, types.NewDownloadedArtifactInfo(info, tempName, size))
+}
+
+// transcodeToTemp copies the text to a file in the temp dir with the given name as a suffix, transcoding it to UTF-8
+// This is used if the table's loader reads the local file itself, so the file must be transcoded before it is loaded
+// Lines containing sequences which are invalid in the encoding are not copied - the number of these is notified
+// UTF-8 text is copied unchanged
+func (s *FileSource) transcodeToTemp(ctx context.Context, r io.ReadCloser, name string) (string, int64, error) {
+	text, encodingName, isValid, err := decodeText(r, s.Config.GetEncoding())
+	if err != nil {
+		return "", 0, err
+	}
+	defer text.Close()
+
+	tempFile, err := os.CreateTemp(s.TempDir, "*-"+name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer tempFile.Close()
+
+	if isUtf8(encodingName) {
+		size, err := io.Copy(tempFile, text)
+		return tempFile.Name(), size, err
+	}
+
+	w := bufio.NewWriter(tempFile)
+	br := bufio.NewReader(text)
+	var size int64
+	var invalid int
+	for {
+		line, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return "", 0, readErr
+		}
+		if line != "" {
+			if isValid(line) {
+				n, err := w.WriteString(line)
+				if err != nil {
+					return "", 0, err
+				}
+				size += int64(n)
+			} else {
+				invalid++
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", 0, err
+	}
+
+	if invalid > 0 {
+		if executionId, err := context_values.ExecutionIdFromContext(ctx); err == nil {
+			slog.Warn("FileSource.DownloadArtifact skipped lines containing invalid sequences", "file", name, "encoding", encodingName, "count", invalid)
+			s.NotifyError(ctx, executionId, fmt.Errorf("%s: skipped %d lines containing invalid %s sequences", name, invalid, encodingName))
+		}
+	}
+	return tempFile.Name(), size, nil
+}
+
+// invalidEncodingError returns the error for a row containing sequences which are invalid in the encoding
+// this is sent in place of the row, so the mapper reports it as a row error
+// (the message does not include the line, so the errors for an artifact are aggregated)
+func invalidEncodingError(encodingName string) error {
+	return fmt.Errorf("row contains invalid %s sequences", encodingName)
+}
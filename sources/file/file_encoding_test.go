@@ -0,0 +1,196 @@
+package file
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turbot/tailpipe-plugin-core/compression"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name             string
+		encoding         string
+		data             []byte
+		expected         string
+		expectedEncoding string
+		expectedInvalid  []string
+	}{
+		{
+			name:             "auto UTF-16LE with byte order mark",
+			encoding:         EncodingAuto,
+			data:             encodeUtf16(t, unicode.LittleEndian, "line 1\r\nlíne 2\r\n", true),
+			expected:         "line 1\r\nlíne 2\r\n",
+			expectedEncoding: "UTF-16LE",
+		},
+		{
+			name:             "auto UTF-16BE with byte order mark",
+			encoding:         EncodingAuto,
+			data:             encodeUtf16(t, unicode.BigEndian, "line 1\n", true),
+			expected:         "line 1\n",
+			expectedEncoding: "UTF-16BE",
+		},
+		{
+			name:             "auto UTF-32LE with byte order mark",
+			encoding:         EncodingAuto,
+			data:             []byte{0xff, 0xfe, 0x00, 0x00, 'a', 0, 0, 0, '\n', 0, 0, 0},
+			expected:         "a\n",
+			expectedEncoding: "UTF-32LE",
+		},
+		{
+			name:             "auto UTF-8 with byte order mark",
+			encoding:         EncodingAuto,
+			data:             []byte("\xef\xbb\xbfline 1\n"),
+			expected:         "line 1\n",
+			expectedEncoding: "UTF-8",
+		},
+		{
+			name:             "auto without byte order mark",
+			encoding:         EncodingAuto,
+			data:             []byte("line 1\ninvalid \xff\n"),
+			expected:         "line 1\ninvalid \xff\n",
+			expectedEncoding: "UTF-8",
+			expectedInvalid:  []string{"invalid \xff"},
+		},
+		{
+			name:             "explicit UTF-16LE with byte order mark",
+			encoding:         "utf-16le",
+			data:             encodeUtf16(t, unicode.LittleEndian, "line 1\n", true),
+			expected:         "line 1\n",
+			expectedEncoding: "utf-16le",
+		},
+		{
+			name:     "explicit UTF-16LE with unpaired surrogate",
+			encoding: "UTF-16LE",
+			// "a\n", then an unpaired high surrogate followed by "b\n"
+			data:             []byte{'a', 0, '\n', 0, 0x00, 0xd8, 'b', 0, '\n', 0},
+			expected:         "a\n�b\n",
+			expectedEncoding: "UTF-16LE",
+			expectedInvalid:  []string{"�b"},
+		},
+		{
+			name:             "ISO-8859-1",
+			encoding:         "ISO-8859-1",
+			data:             []byte("caf\xe9\n"),
+			expected:         "café\n",
+			expectedEncoding: "ISO-8859-1",
+		},
+		{
+			name:             "Shift_JIS",
+			encoding:         "Shift_JIS",
+			data:             encodeShiftJis(t, "ログ\n"),
+			expected:         "ログ\n",
+			expectedEncoding: "Shift_JIS",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, encodingName, isValid, err := decodeText(io.NopCloser(bytes.NewReader(tt.data)), tt.encoding)
+			if err != nil {
+				t.Fatalf("decodeText() error = %v", err)
+			}
+			defer r.Close()
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("error reading decoded text: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, data)
+			}
+			if encodingName != tt.expectedEncoding {
+				t.Errorf("expected encoding %s, got %s", tt.expectedEncoding, encodingName)
+			}
+			var invalid []string
+			for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+				if !isValid(line) {
+					invalid = append(invalid, line)
+				}
+			}
+			if strings.Join(invalid, ",") != strings.Join(tt.expectedInvalid, ",") {
+				t.Errorf("expected invalid lines %q, got %q", tt.expectedInvalid, invalid)
+			}
+		})
+	}
+}
+
+func TestFileSource_Encoding(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		// a UTF-16LE file with a byte order mark, containing an unpaired surrogate on the second line
+		"windows.log": append(encodeUtf16(t, unicode.LittleEndian, "windows 1\r\n", true), 0x00, 0xd8, '2', 0, '\n', 0),
+		"utf8.log":    []byte("utf8 1\n"),
+		"utf16.log.gz": compressTestData(t, compression.Gzip,
+			string(encodeUtf16(t, unicode.BigEndian, "compressed 1\n", true))),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(logDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, _ := collectFiles(t, logDir, "%{DATA}", filepath.Join(dir, "state.json"), filepath.Join(dir, "collection"), `encoding = "auto"`)
+	assertRows(t, "encoded files", rows, []string{
+		"windows 1",
+		"error: row contains invalid UTF-16LE sequences",
+		"utf8 1",
+		"compressed 1",
+	})
+}
+
+func TestFileSource_TranscodeToTemp(t *testing.T) {
+	dir := t.TempDir()
+	s := &FileSource{}
+	s.TempDir = dir
+	encoding := "UTF-16LE"
+	s.Config = &FileSourceConfig{Encoding: &encoding}
+
+	data := append(encodeUtf16(t, unicode.LittleEndian, "line 1\nline 2\n", true), 0x00, 0xd8, '\n', 0)
+	localName, size, err := s.transcodeToTemp(t.Context(), io.NopCloser(bytes.NewReader(data)), "app.log")
+	if err != nil {
+		t.Fatalf("transcodeToTemp() error = %v", err)
+	}
+	res, err := os.ReadFile(localName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the invalid line is not copied
+	if string(res) != "line 1\nline 2\n" || size != int64(len(res)) {
+		t.Errorf("expected transcoded file %q, got %q (size %d)", "line 1\nline 2\n", res, size)
+	}
+	if !strings.HasSuffix(localName, "-app.log") {
+		t.Errorf("expected the local name to end with the file name, got %s", localName)
+	}
+}
+
+// encodeUtf16 encodes the text as UTF-16, with a byte order mark if bom is set
+func encodeUtf16(t *testing.T, endianness unicode.Endianness, text string, bom bool) []byte {
+	t.Helper()
+	policy := unicode.IgnoreBOM
+	if bom {
+		policy = unicode.UseBOM
+	}
+	res, err := unicode.UTF16(endianness, policy).NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func encodeShiftJis(t *testing.T, text string) []byte {
+	t.Helper()
+	res, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
@@ -0,0 +1,84 @@
+package file
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// pathFilter applies the include and exclude glob patterns from the source config to the paths found when walking
+// Patterns use doublestar syntax and are matched against the path relative to the source path being walked:
+// - a pattern containing no '/' is also matched against the base name, so `*.swp` matches at any depth
+// - a pattern with a trailing '/' only matches directories, e.g. `lost+found/`
+type pathFilter struct {
+	include []string
+	exclude []string
+}
+
+func newPathFilter(include, exclude []string) *pathFilter {
+	return &pathFilter{
+		include: include,
+		exclude: exclude,
+	}
+}
+
+// validateGlobs returns an error if any of the given patterns is not a valid glob
+func validateGlobs(field string, patterns []string) error {
+	for _, pattern := range patterns {
+		if !doublestar.ValidatePattern(strings.TrimSuffix(pattern, "/")) {
+			return fmt.Errorf("%s pattern %s is not a valid glob", field, pattern)
+		}
+	}
+	return nil
+}
+
+// skip returns whether the given path should be skipped
+// directories are only skipped if they are excluded - the include patterns only apply to files
+func (f *pathFilter) skip(basePath, targetPath string, isDir bool) bool {
+	// never skip the path we are walking
+	if targetPath == basePath {
+		return false
+	}
+	relPath, err := filepath.Rel(basePath, targetPath)
+	if err != nil {
+		return false
+	}
+	return f.skipRelPath(filepath.ToSlash(relPath), isDir)
+}
+
+// skipRelPath returns whether the given (slash separated) relative path should be skipped
+func (f *pathFilter) skipRelPath(relPath string, isDir bool) bool {
+	if matchesAny(f.exclude, relPath, isDir) {
+		return true
+	}
+	if isDir || len(f.include) == 0 {
+		return false
+	}
+	return !matchesAny(f.include, relPath, isDir)
+}
+
+// matchesAny returns whether the (slash separated) relative path matches any of the patterns
+func matchesAny(patterns []string, relPath string, isDir bool) bool {
+	for _, pattern := range patterns {
+		// a trailing slash means the pattern only matches directories
+		if strings.HasSuffix(pattern, "/") {
+			if !isDir {
+				continue
+			}
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+
+		target := relPath
+		// a pattern with no separator is matched against the base name, wherever it is in the tree
+		if !strings.Contains(pattern, "/") {
+			target = filepath.Base(relPath)
+		}
+		// we have already validated the patterns so we can ignore the error
+		if match, _ := doublestar.Match(pattern, target); match {
+			return true
+		}
+	}
+	return false
+}
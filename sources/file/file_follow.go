@@ -0,0 +1,338 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-core/compression"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// fingerprintSize is the number of bytes at the head of a file which are hashed to fingerprint it
+// files smaller than this are not fingerprinted
+const fingerprintSize = 1024
+
+// hasNewData returns whether a followed file has been written to since we last collected it
+// If we have not collected the file before, we check whether it is a rotation of a file we have collected
+// (i.e. a renamed or compressed copy) and if so, adopt the follow state of the original file
+func (s *FileSource) hasNewData(targetPath string, d fs.DirEntry) bool {
+	info, err := d.Info()
+	if err != nil {
+		// let DownloadArtifact report the error
+		return true
+	}
+
+	state := s.fileCollectionState()
+	fileState := state.GetFileState(targetPath)
+	if fileState == nil {
+		rotatedState := s.findRotatedFrom(targetPath, info)
+		if rotatedState == nil {
+			// this is a new file - leave it to the collection state to decide
+			return true
+		}
+		slog.Info("FileSource recognised rotated file", "file", targetPath, "offset", rotatedState.Offset)
+		state.SetFileState(targetPath, rotatedState)
+
+		// collect anything written to the file before it was rotated
+		// (we cannot tell how much data a compressed file holds without decompressing it, so always collect it)
+		return !canFollow(targetPath) || info.Size() > rotatedState.Offset
+	}
+
+	// a compressed file will not be written to, so there is only new data if it has been replaced
+	if !canFollow(targetPath) {
+		device, inode, ok := fileIdentity(info)
+		return ok && !fileState.SameFile(device, inode)
+	}
+
+	return info.Size() > resumeOffset(targetPath, info, fileState)
+}
+
+// findRotatedFrom determines whether the file is a rotation of a file we have previously followed:
+// - a renamed file will have the same device/inode as the original
+// - a compressed file will have the same head-of-file fingerprint as the original - as different files may have the
+// same head (e.g. a CSV header or a banner), a fingerprint is only matched for a compressed file, and only against
+// files which have since been removed or replaced
+// If so, it returns a copy of the follow state of the original file, with the identity of this file
+// If several files match, files whose name is a prefix of the name of this file (e.g. app.log for app.log.1.gz) are
+// preferred, then the first by path
+func (s *FileSource) findRotatedFrom(targetPath string, info fs.FileInfo) *FileState {
+	device, inode, hasIdentity := fileIdentity(info)
+	compressed := !canFollow(targetPath)
+	// ignore errors - if we cannot fingerprint the file we can still match on identity
+	fingerprint, _ := fingerprintFile(targetPath)
+
+	// NOTE: use the follow state as it was at the start of discovery, as the state for the original path
+	// may already have been updated if a new file has been created in its place
+	for _, path := range rotationCandidates(targetPath, s.previousFileStates) {
+		prevState := s.previousFileStates[path]
+
+		if !compressed {
+			// if the original file was fingerprinted, the fingerprint must match
+			// (this avoids matching a new file which has reused the inode of a deleted file)
+			if hasIdentity && prevState.SameFile(device, inode) && (prevState.Fingerprint == "" || prevState.Fingerprint == fingerprint) {
+				rotatedState := *prevState
+				return &rotatedState
+			}
+			continue
+		}
+
+		if fingerprint != "" && prevState.Fingerprint == fingerprint && fileRemoved(path, prevState) {
+			rotatedState := *prevState
+			rotatedState.Device, rotatedState.Inode = device, inode
+			return &rotatedState
+		}
+	}
+	return nil
+}
+
+// rotationCandidates returns the paths of the followed files which the target may be a rotation of, in the order
+// they should be checked: files whose name is a prefix of the target name first, then by path
+func rotationCandidates(targetPath string, fileStates map[string]*FileState) []string {
+	targetName := filepath.Base(targetPath)
+	isPrefix := func(path string) bool {
+		return strings.HasPrefix(targetName, filepath.Base(path))
+	}
+
+	var res []string
+	for path := range fileStates {
+		if path != targetPath {
+			res = append(res, path)
+		}
+	}
+	slices.SortFunc(res, func(a, b string) int {
+		if aPrefix, bPrefix := isPrefix(a), isPrefix(b); aPrefix != bPrefix {
+			if aPrefix {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	return res
+}
+
+// fileRemoved returns whether the followed file no longer exists at its path, or has been replaced by another file
+func fileRemoved(path string, fileState *FileState) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	device, inode, ok := fileIdentity(info)
+	return ok && !fileState.SameFile(device, inode)
+}
+
+// resumeOffset returns the offset to resume reading a followed file from, given its previous follow state
+// we start from the beginning if the file has been replaced, truncated (e.g. by logrotate copytruncate)
+// or rewritten since we last read it
+func resumeOffset(path string, info fs.FileInfo, prevState *FileState) int64 {
+	if prevState == nil {
+		return 0
+	}
+	if device, inode, ok := fileIdentity(info); ok && !prevState.SameFile(device, inode) {
+		slog.Debug("followed file has been replaced", "file", path)
+		return 0
+	}
+	if info.Size() < prevState.Offset {
+		slog.Info("followed file has been truncated", "file", path, "size", info.Size(), "offset", prevState.Offset)
+		return 0
+	}
+	if prevState.Fingerprint != "" {
+		if fingerprint, err := fingerprintFile(path); err == nil && fingerprint != prevState.Fingerprint {
+			slog.Info("followed file has been rewritten", "file", path)
+			return 0
+		}
+	}
+	return prevState.Offset
+}
+
+// downloadFollowedFile copies the data appended to a followed file since the previous collection to the temp dir,
+// and notifies observers of the copy. Only complete lines are copied - a partial line at the end of the file
+// will be collected by the next collection
+func (s *FileSource) downloadFollowedFile(ctx context.Context, info *types.ArtifactInfo, fileInfo fs.FileInfo) error {
+	localName := info.Name
+	fileName := filepath.Base(localName)
+
+	offset := resumeOffset(localName, fileInfo, s.fileCollectionState().GetFileState(localName))
+
+	f, err := os.Open(localName)
+	if err != nil {
+		slog.Error("FileSource.DownloadArtifact error opening file", "file", localName, "error", err)
+		return fmt.Errorf("%s: unable to open file", fileName)
+	}
+	defer f.Close()
+
+	// NOTE: we only copy up to the size we obtained from stat - anything written after that will be collected next time
+	tailPath, tailSize, err := s.copyToTemp(io.NewSectionReader(f, offset, fileInfo.Size()-offset), fileName, true)
+	if err != nil {
+		slog.Error("FileSource.DownloadArtifact error copying file", "file", localName, "offset", offset, "error", err)
+		return fmt.Errorf("%s: unable to copy file", fileName)
+	}
+	slog.Debug("FileSource.DownloadArtifact copied followed file", "file", localName, "offset", offset, "bytes", tailSize)
+
+	// if the table groups lines into records, and the file has been written to within the flush timeout, the last
+	// record may be incomplete - leave it to be collected by the next collection
+	// NOTE: records are found by their line endings, so this does not apply to encoded files
+	if grouper, ok := s.Loader.(recordGrouper); ok && s.Config.GetEncoding() == "" && time.Since(fileInfo.ModTime()) < grouper.FlushTimeout() {
+		tailSize, err = removeLastRecord(tailPath, grouper)
+		if err != nil {
+			slog.Error("FileSource.DownloadArtifact error reading records", "file", localName, "error", err)
+			return fmt.Errorf("%s: unable to read records", fileName)
+		}
+		slog.Debug("FileSource.DownloadArtifact left the last record of followed file", "file", localName, "offset", offset+tailSize)
+	}
+
+	// store the new offset - this will be saved in the collection state once the file is collected
+	s.setPendingFileState(localName, fileInfo, offset+tailSize)
+
+	// notify observers of the downloaded artifact
+	return s.OnArtifactDownloaded(ctx, types.NewDownloadedArtifactInfo(info, tailPath, tailSize))
+}
+
+// downloadRotatedArchive decompresses a compressed rotation of a followed file, and copies the data after the
+// offset we had reached in the original file to the temp dir
+func (s *FileSource) downloadRotatedArchive(ctx context.Context, info *types.ArtifactInfo, fileInfo fs.FileInfo, fileState *FileState) error {
+	localName := info.Name
+	fileName := filepath.Base(localName)
+
+	r, err := compression.Open(localName)
+	if err != nil {
+		slog.Error("FileSource.DownloadArtifact error opening compressed file", "file", localName, "error", err)
+		return fmt.Errorf("%s: unable to open compressed file", fileName)
+	}
+	defer r.Close()
+
+	// skip the data we have already collected
+	if _, err := io.CopyN(io.Discard, r, fileState.Offset); err != nil && err != io.EOF {
+		slog.Error("FileSource.DownloadArtifact error decompressing file", "file", localName, "error", err)
+		return fmt.Errorf("%s: unable to decompress file", fileName)
+	}
+
+	// the decompressed copy must not have the compressed extension, so the default loader is used
+	tailPath, tailSize, err := s.copyToTemp(r, strings.TrimSuffix(fileName, filepath.Ext(fileName)), false)
+	if err != nil {
+		slog.Error("FileSource.DownloadArtifact error decompressing file", "file", localName, "error", err)
+		return fmt.Errorf("%s: unable to decompress file", fileName)
+	}
+	slog.Debug("FileSource.DownloadArtifact copied rotated file", "file", localName, "offset", fileState.Offset, "bytes", tailSize)
+
+	s.setPendingFileState(localName, fileInfo, fileState.Offset+tailSize)
+
+	// notify observers of the downloaded artifact
+	return s.OnArtifactDownloaded(ctx, types.NewDownloadedArtifactInfo(info, tailPath, tailSize))
+}
+
+// copyToTemp copies the data from the reader to a file in the temp dir, returning the path of the copy and the
+// number of bytes copied. If completeLines is set, any partial line at the end of the data is not copied
+func (s *FileSource) copyToTemp(r io.Reader, name string, completeLines bool) (string, int64, error) {
+	// retain the original file name as a suffix so the extension is unchanged
+	tempFile, err := os.CreateTemp(s.TempDir, "*-"+name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer tempFile.Close()
+
+	w := &lineEndWriter{w: tempFile}
+	if _, err := io.Copy(w, r); err != nil {
+		return "", 0, err
+	}
+	if !completeLines {
+		return tempFile.Name(), w.written, nil
+	}
+
+	// remove any partial line from the end of the copy
+	if err := tempFile.Truncate(w.lastLineEnd); err != nil {
+		return "", 0, err
+	}
+	return tempFile.Name(), w.lastLineEnd, nil
+}
+
+// removeLastRecord removes the last record from the copy of a followed file, returning the size of the remaining data
+func removeLastRecord(path string, grouper recordGrouper) (int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	start, err := grouper.LastRecordStart(f)
+	if err != nil {
+		return 0, err
+	}
+	return start, f.Truncate(start)
+}
+
+// setPendingFileState stores the follow state for a downloaded file in the collection state
+// this will be committed once the file is collected
+func (s *FileSource) setPendingFileState(path string, fileInfo fs.FileInfo, offset int64) {
+	device, inode, _ := fileIdentity(fileInfo)
+	fileState := &FileState{
+		Offset: offset,
+		Device: device,
+		Inode:  inode,
+	}
+
+	fingerprint, err := fingerprintFile(path)
+	if err != nil {
+		slog.Warn("FileSource failed to fingerprint file", "file", path, "error", err)
+	}
+	fileState.Fingerprint = fingerprint
+
+	s.fileCollectionState().SetPendingFileState(path, fileState)
+}
+
+// fileCollectionState returns our collection state as a FileCollectionState
+func (s *FileSource) fileCollectionState() *FileCollectionState {
+	if s.CollectionState == nil {
+		return nil
+	}
+	state, _ := s.CollectionState.State.(*FileCollectionState)
+	return state
+}
+
+// fingerprintFile returns a hash of the first fingerprintSize bytes of the (decompressed) file contents
+// if the file is smaller than fingerprintSize, an empty fingerprint is returned
+func fingerprintFile(path string) (string, error) {
+	r, err := compression.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	head := make([]byte, fingerprintSize)
+	if _, err := io.ReadFull(r, head); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return "", nil
+		}
+		return "", err
+	}
+	hash := sha256.Sum256(head)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// lineEndWriter is an io.Writer which records the number of bytes written up to and including the last newline
+type lineEndWriter struct {
+	w           io.Writer
+	written     int64
+	lastLineEnd int64
+}
+
+func (l *lineEndWriter) Write(p []byte) (int, error) {
+	n, err := l.w.Write(p)
+	if i := bytes.LastIndexByte(p[:n], '\n'); i >= 0 {
+		l.lastLineEnd = l.written + int64(i) + 1
+	}
+	l.written += int64(n)
+	return n, err
+}
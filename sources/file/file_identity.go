@@ -0,0 +1,18 @@
+//go:build !windows
+
+package file
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode of the file
+// ok is false if the identity cannot be determined
+func fileIdentity(info fs.FileInfo) (device uint64, inode uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(stat.Dev), stat.Ino, true //nolint:unconvert // Dev is not a uint64 on all platforms
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package file
+
+import (
+	"io/fs"
+)
+
+// fileIdentity returns the device and inode of the file
+// device and inode are not available from fs.FileInfo on windows, so ok is always false
+func fileIdentity(_ fs.FileInfo) (device uint64, inode uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,187 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-core/compression"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_loader"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const fileLoaderIdentifier = "file_source_loader"
+
+// fileLoader is the loader used by the file source if the table does not specify a loader
+// It streams the data of each artifact, decompressing it if it is compressed (determined from the file extension or,
+// failing that, the magic bytes at the head of the file) - gzip, zstd, bzip2, xz and lz4 compression are supported
+// If an encoding is set, the text is transcoded to UTF-8 - rows containing invalid sequences are replaced by an error,
+// so they are reported as row errors
+// Members of tar archives are read directly from the archive
+// Zip files cannot be streamed, so are loaded by the SDK zip loader (and are not transcoded)
+type fileLoader struct {
+	rowPerLine bool
+	encoding   string
+	// the archive members discovered by the source
+	archiveMembers *archiveMembers
+}
+
+func newFileLoader(rowPerLine bool, encoding string, archiveMembers *archiveMembers) *fileLoader {
+	return &fileLoader{rowPerLine: rowPerLine, encoding: encoding, archiveMembers: archiveMembers}
+}
+
+func (l *fileLoader) Identifier() string {
+	return fileLoaderIdentifier
+}
+
+// Load implements artifact_loader.Loader
+func (l *fileLoader) Load(ctx context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	if compression.Detect(info.LocalName) == compression.Zip {
+		return l.zipLoader().Load(ctx, info, dataChan)
+	}
+
+	r, encodingName, isValid, err := l.openText(info)
+	if err != nil {
+		return err
+	}
+	rowError := invalidEncodingError(encodingName)
+	return l.loadReader(ctx, info, r, dataChan, func(row string) any {
+		if !isValid(row) {
+			return rowError
+		}
+		return row
+	})
+}
+
+// open opens the artifact data - this is used by loaders which read the artifact data themselves
+// (zip files cannot be streamed, so are not supported)
+func (l *fileLoader) open(info *types.DownloadedArtifactInfo) (io.ReadCloser, error) {
+	if compression.Detect(info.LocalName) == compression.Zip {
+		return nil, fmt.Errorf("zip files can not be streamed")
+	}
+	r, _, _, err := l.openText(info)
+	return r, err
+}
+
+// openText opens the artifact data, reading archive members from the archive, decompressing the data and, if an
+// encoding is set, transcoding the text to UTF-8
+// It also returns the name of the encoding and a function which returns whether a line of the text is valid
+func (l *fileLoader) openText(info *types.DownloadedArtifactInfo) (io.ReadCloser, string, func(string) bool, error) {
+	r, err := l.openData(info.LocalName)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error opening %s: %w", info.LocalName, err)
+	}
+
+	if l.encoding == "" {
+		return r, "UTF-8", func(string) bool { return true }, nil
+	}
+	r, encodingName, isValid, err := decodeText(r, l.encoding)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error decoding %s: %w", info.LocalName, err)
+	}
+	return r, encodingName, isValid, nil
+}
+
+// openData opens the file or archive member, decompressing it if it is compressed
+func (l *fileLoader) openData(localName string) (io.ReadCloser, error) {
+	member, ok := l.archiveMembers.get(localName)
+	if !ok {
+		return compression.Open(localName)
+	}
+	r, err := l.archiveMembers.open(member)
+	if err != nil {
+		return nil, err
+	}
+	return compression.Decompress(r, member.path)
+}
+
+// ArtifactModTime implements formats.ArtifactModTimeProvider - it returns the modification time of an archive member
+// from its tar header (the modification time of other artifacts is that of the file)
+func (l *fileLoader) ArtifactModTime(info *types.DownloadedArtifactInfo) (time.Time, bool) {
+	member, ok := l.archiveMembers.get(info.LocalName)
+	if !ok {
+		return time.Time{}, false
+	}
+	return member.modTime, true
+}
+
+// loadReader sends the data read from the reader to the data channel, either a line at a time or as a single object
+// each row is passed to rowData, which returns the data to send (this is an error if the row is not valid)
+// the reader is closed once it has been read
+func (l *fileLoader) loadReader(ctx context.Context, info *types.DownloadedArtifactInfo, r io.ReadCloser, dataChan chan *types.RowData, rowData func(string) any) error {
+	if !l.rowPerLine {
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", info.LocalName, err)
+		}
+		// as for the SDK file loader, the data of a valid file is sent as bytes
+		var fileData any = data
+		if d, ok := rowData(string(data)).(error); ok {
+			fileData = d
+		}
+		go func() {
+			dataChan <- &types.RowData{
+				Data: fileData,
+			}
+			close(dataChan)
+		}()
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	go func() {
+		defer func() {
+			r.Close()
+			close(dataChan)
+		}()
+
+		for scanner.Scan() {
+			// check context cancellation
+			if ctx.Err() != nil {
+				break
+			}
+			dataChan <- &types.RowData{
+				Data: rowData(scanner.Text()),
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			slog.Error("fileLoader error reading artifact", "artifact", info.LocalName, "error", err)
+		}
+	}()
+	return nil
+}
+
+// zipLoader returns the SDK zip loader
+func (l *fileLoader) zipLoader() artifact_loader.Loader {
+	if l.rowPerLine {
+		return artifact_loader.NewZipRowLoader()
+	}
+	return artifact_loader.NewZipLoader()
+}
+
+// loaderDecorator is implemented by loaders which decorate the rows loaded by another loader,
+// for example the multiline loader, which groups lines into records
+// if the table's loader is a decorator, the file loader is used to load the rows it decorates
+type loaderDecorator interface {
+	SetInnerLoader(artifact_loader.Loader)
+}
+
+// artifactReader is implemented by loaders which read the rows from the artifact data, for example the json loader,
+// which decodes the records of JSON documents as they are read
+// if the table's loader is an artifact reader, the file loader opens the data it reads
+type artifactReader interface {
+	SetArtifactOpener(func(*types.DownloadedArtifactInfo) (io.ReadCloser, error))
+}
+
+// recordGrouper is implemented by loaders which group lines into records, for example the multiline loader
+// when a file is followed, the last record of the data appended since the previous collection may be completed by
+// lines which have not been written yet, so it is not collected until the file has not been written to for the
+// flush timeout
+type recordGrouper interface {
+	FlushTimeout() time.Duration
+	LastRecordStart(io.Reader) (int64, error)
+}
@@ -0,0 +1,108 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-core/compression"
+	"github.com/turbot/tailpipe-plugin-core/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestFileSource_LoaderDecorator(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "bundles")
+	writeTestArchive(t, filepath.Join(logDir, "bundle.tar.gz"), []testArchiveMember{
+		{name: "app.log.gz", content: "start 1\n  more 1\nstart 2\n", gzip: true},
+	})
+
+	// the multiline loader groups the lines loaded by the file loader - so the member is streamed from the archive
+	// and decompressed before the lines are grouped
+	startPattern := "^start"
+	opts := (&formats.Grok{Multiline: &formats.Multiline{StartPattern: &startPattern}}).GetSourceOptions()
+	rows, artifacts := collectFilesWithOptions(t, opts, logDir, "%{DATA}", filepath.Join(dir, "state.json"), filepath.Join(dir, "collection"))
+	assertRows(t, "multiline records", rows, []string{"start 1\n  more 1", "start 2"})
+	if len(artifacts) != 1 || !strings.HasSuffix(artifacts[0], "bundle.tar.gz!/app.log.gz") {
+		t.Errorf("expected the archive member artifact, got %v", artifacts)
+	}
+}
+
+func TestFileSource_ArtifactReader(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "exports")
+	writeTestArchive(t, filepath.Join(logDir, "bundle.tar.gz"), []testArchiveMember{
+		{name: "export.json.gz", content: `{"Records": [{"id": "1"}, {"id": "2"}]}`, gzip: true},
+	})
+	if err := os.WriteFile(filepath.Join(logDir, "utf16.json"), encodeUtf16(t, unicode.LittleEndian, `{"Records": [{"id": "3"}]}`, true), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "export.json.xz"), compressTestData(t, compression.Xz, `{"Records": [{"id": "4"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the json loader reads the data opened by the file loader - so archive members are streamed, files are
+	// decompressed and the text is transcoded before the records are decoded
+	recordsPath := "Records[*]"
+	opts := (&formats.Json{RecordsPath: &recordsPath}).GetSourceOptions()
+	rows, _ := collectFilesWithOptions(t, opts, logDir, "%{DATA}", filepath.Join(dir, "state.json"), filepath.Join(dir, "collection"), `encoding = "auto"`)
+	assertRows(t, "json records", rows, []string{
+		"map[id:1]",
+		"map[id:2]",
+		"map[id:3]",
+		"map[id:4]",
+	})
+}
+
+func TestFileSource_ArtifactModTime(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	memberTime := time.Date(2019, 1, 5, 0, 0, 0, 0, time.UTC)
+	writeTestArchive(t, filepath.Join(logDir, "bundle.tar.gz"), []testArchiveMember{
+		{name: "syslog", content: "Dec 31 23:59:59 host app: last line of the year\n", modTime: memberTime},
+	})
+
+	// the syslog loader uses the modification time of the member from the archive, not the time it was read
+	// (the mod time filter is disabled, as the files were last modified before the collection time range)
+	rows, _ := collectFilesWithOptions(t, (&formats.Syslog{}).GetSourceOptions(), logDir, "%{DATA}", filepath.Join(dir, "state.json"), filepath.Join(dir, "collection"), "filter_mod_time = false")
+	assertRows(t, "streamed member", rows, []string{"2019-01-05 Dec 31 23:59:59 host app: last line of the year"})
+
+	// if the table's loader reads a local file, the copies of transcoded files and archive members have the
+	// modification time of the original
+	fileTime := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	utf16Path := filepath.Join(logDir, "utf16.log")
+	if err := os.WriteFile(utf16Path, encodeUtf16(t, unicode.LittleEndian, "utf16 1\n", true), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(utf16Path, fileTime, fileTime); err != nil {
+		t.Fatal(err)
+	}
+	opts := []row_source.RowSourceOption{artifact_source.WithRowPerLine(), artifact_source.WithArtifactLoader(&modTimeLoader{})}
+	rows, _ = collectFilesWithOptions(t, opts, logDir, "%{DATA}", filepath.Join(dir, "state2.json"), filepath.Join(dir, "collection2"), `encoding = "UTF-16LE"`, "filter_mod_time = false")
+	assertRows(t, "copies", rows, []string{"2019-01-05", "2020-06-01"})
+}
+
+// modTimeLoader is a loader which reads a local file, and sends its modification time as a single row
+type modTimeLoader struct{}
+
+func (l *modTimeLoader) Identifier() string {
+	return "mod_time_loader"
+}
+
+func (l *modTimeLoader) Load(_ context.Context, info *types.DownloadedArtifactInfo, dataChan chan *types.RowData) error {
+	fileInfo, err := os.Stat(info.LocalName)
+	if err != nil {
+		return err
+	}
+	go func() {
+		dataChan <- &types.RowData{Data: fileInfo.ModTime().UTC().Format(time.DateOnly)}
+		close(dataChan)
+	}()
+	return nil
+}
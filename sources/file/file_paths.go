@@ -0,0 +1,84 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// walkTarget is a file or directory to walk, along with the base path the file layout is matched relative to
+type walkTarget struct {
+	root     string
+	basePath string
+	isDir    bool
+}
+
+// expandPaths expands the configured paths into the files and directories to walk
+// - a directory is walked, and the file layout is matched against the paths relative to the directory
+// - a file is collected if it satisfies the file layout (and filters), which is matched against the file name
+// - a glob is expanded into the files and directories it matches, which are handled as above
+//
+// Any path which does not exist, or glob which does not match anything, is returned in missingPaths
+func expandPaths(paths []string) (targets []walkTarget, missingPaths []string, errs []error) {
+	for _, path := range paths {
+		if !isGlob(path) {
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+				missingPaths = append(missingPaths, path)
+				continue
+			}
+			targets = append(targets, newWalkTarget(path))
+			continue
+		}
+
+		// we have already validated the pattern
+		matches, err := doublestar.FilepathGlob(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: unable to expand path: %w", path, err))
+			continue
+		}
+		if len(matches) == 0 {
+			missingPaths = append(missingPaths, path)
+			continue
+		}
+		slices.Sort(matches)
+		for _, match := range matches {
+			targets = append(targets, newWalkTarget(match))
+		}
+	}
+
+	// the same file or directory may be matched by more than one path - only walk it once
+	seen := make(map[string]struct{}, len(targets))
+	targets = slices.DeleteFunc(targets, func(t walkTarget) bool {
+		if _, ok := seen[t.root]; ok {
+			return true
+		}
+		seen[t.root] = struct{}{}
+		return false
+	})
+	return targets, missingPaths, errs
+}
+
+func newWalkTarget(path string) walkTarget {
+	// if we cannot stat the path, treat it as a directory and let the walk report the error
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return walkTarget{root: path, basePath: filepath.Dir(path)}
+	}
+	return walkTarget{root: path, basePath: path, isDir: true}
+}
+
+// isGlob returns whether the path is a glob, i.e. whether it contains any glob meta characters
+// A path which exists is not a glob, even if it contains meta characters (e.g. /var/log/app[1].log or a {tenant}
+// directory), so an existing file or directory is always collected as it is named
+func isGlob(path string) bool {
+	if !strings.ContainsAny(path, "*?[{") {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err != nil
+}
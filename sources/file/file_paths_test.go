@@ -0,0 +1,72 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app[1].log", "app1.log", "{tenant}/a.log", "tenant/b.log"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		expectedRoots   []string
+		expectedMissing bool
+	}{
+		{
+			name:          "existing file with glob meta characters",
+			path:          filepath.Join(dir, "app[1].log"),
+			expectedRoots: []string{filepath.Join(dir, "app[1].log")},
+		},
+		{
+			name:          "existing directory with glob meta characters",
+			path:          filepath.Join(dir, "{tenant}"),
+			expectedRoots: []string{filepath.Join(dir, "{tenant}")},
+		},
+		{
+			name:          "glob",
+			path:          filepath.Join(dir, "app[0-9].log"),
+			expectedRoots: []string{filepath.Join(dir, "app1.log")},
+		},
+		{
+			name:          "glob with alternatives",
+			path:          filepath.Join(dir, "{tenant,other}", "*.log"),
+			expectedRoots: []string{filepath.Join(dir, "tenant", "b.log")},
+		},
+		{
+			name:            "glob which matches nothing",
+			path:            filepath.Join(dir, "app[2].log"),
+			expectedMissing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, missingPaths, errs := expandPaths([]string{tt.path})
+			if len(errs) > 0 {
+				t.Fatalf("expandPaths() errors = %v", errs)
+			}
+			var roots []string
+			for _, target := range targets {
+				roots = append(roots, target.root)
+			}
+			if !slices.Equal(roots, tt.expectedRoots) {
+				t.Errorf("expected roots %v, got %v", tt.expectedRoots, roots)
+			}
+			if (len(missingPaths) > 0) != tt.expectedMissing {
+				t.Errorf("expected missing %v, got missing paths %v", tt.expectedMissing, missingPaths)
+			}
+		})
+	}
+}
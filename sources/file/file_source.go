@@ -7,13 +7,16 @@ import (
 	"io/fs"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/elastic/go-grok"
 
 	typehelpers "github.com/turbot/go-kit/types"
 	"github.com/turbot/pipe-fittings/v2/filter"
+	"github.com/turbot/tailpipe-plugin-core/compression"
 	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
 	"github.com/turbot/tailpipe-plugin-sdk/context_values"
 	"github.com/turbot/tailpipe-plugin-sdk/row_source"
@@ -22,14 +25,45 @@ import (
 
 type FileSource struct {
 	artifact_source.ArtifactSourceImpl[*FileSourceConfig, *artifact_source.EmptyConnection]
+
+	// the follow state of all files at the start of discovery - used to recognise rotated files
+	previousFileStates map[string]*FileState
+	// the archive members discovered - these are read directly from the archive when they are loaded
+	archiveMembers archiveMembers
+	// whether the artifacts are loaded by the file loader (directly, or by a loader which decorates it)
+	usesFileLoader bool
 }
 
 func (s *FileSource) Init(ctx context.Context, params *row_source.RowSourceParams, opts ...row_source.RowSourceOption) error {
+	// use the file collection state - this also tracks the read offset of followed files
+	s.NewCollectionStateFunc = NewFileCollectionState
+
 	// call base to parse config and apply options
 	if err := s.ArtifactSourceImpl.Init(ctx, params, opts...); err != nil {
 		return err
 	}
 
+	// if the table has not specified a loader, use the file loader - this reads archive members from the archive, and
+	// decompresses and transcodes the data
+	// if the table's loader decorates the rows loaded by another loader, the file loader loads those rows, and if
+	// the table's loader reads the artifact data itself, the file loader opens the data
+	switch loader := s.Loader.(type) {
+	case nil:
+		s.Loader = newFileLoader(s.RowPerLine, s.Config.GetEncoding(), &s.archiveMembers)
+		s.usesFileLoader = true
+	case loaderDecorator:
+		loader.SetInnerLoader(newFileLoader(s.RowPerLine, s.Config.GetEncoding(), &s.archiveMembers))
+		s.usesFileLoader = true
+	case artifactReader:
+		loader.SetArtifactOpener(newFileLoader(s.RowPerLine, s.Config.GetEncoding(), &s.archiveMembers).open)
+		s.usesFileLoader = true
+	}
+
+	// tell the collection state whether we are following files
+	if state := s.fileCollectionState(); state != nil {
+		state.SetFollow(s.Config.FollowEnabled())
+	}
+
 	// ensure all paths are absolute
 	for i, p := range s.Config.Paths {
 		abs, err := filepath.Abs(p)
@@ -46,6 +80,12 @@ func (s *FileSource) Identifier() string {
 	return FileSourceIdentifier
 }
 
+// Close closes the archives which are open to read archive members
+func (s *FileSource) Close() error {
+	s.archiveMembers.close()
+	return s.ArtifactSourceImpl.Close()
+}
+
 func (s *FileSource) DiscoverArtifacts(ctx context.Context) error {
 	executionId, err := context_values.ExecutionIdFromContext(ctx)
 	if err != nil {
@@ -71,12 +111,63 @@ func (s *FileSource) DiscoverArtifacts(ctx context.Context) error {
 		return fmt.Errorf("error adding grok patterns: %v", err)
 	}
 
-	for _, basePath := range s.Config.Paths {
-		// TODO we need a mechanism to stop walking when weh have reached the 'To' time https://github.com/turbot/tailpipe-plugin-sdk/issues/243
-		err := filepath.WalkDir(basePath, func(targetPath string, d fs.DirEntry, err error) error {
+	if s.Config.FollowEnabled() {
+		s.initFollowState()
+	}
+
+	pathFilter := newPathFilter(s.Config.Include, s.Config.Exclude)
+
+	// expand any globs in the paths - errors expanding a glob are not fatal
+	targets, missingPaths, errs := expandPaths(s.Config.Paths)
+	for _, err := range errs {
+		slog.Warn("FileSource.DiscoverArtifacts error expanding path", "error", err)
+		s.NotifyError(ctx, executionId, err)
+	}
+	s.handleMissingPaths(ctx, executionId, missingPaths)
+
+	// walk the paths in turn - the walker reads the root directories of all paths, and the directories accepted by
+	// the walk function, ahead concurrently
+	// symlink cycles and dangling symlinks are not fatal - notify and continue walking
+	onSymlinkError := func(err error) {
+		slog.Warn("FileSource.DiscoverArtifacts error following symlink", "error", err)
+		s.NotifyError(ctx, executionId, err)
+	}
+	walker := newDirWalker(s.Config.GetDiscoveryConcurrency(), s.Config.FollowSymlinksEnabled(), onSymlinkError)
+	defer walker.close()
+	for _, target := range targets {
+		if target.isDir {
+			walker.prefetch(target.root)
+		}
+	}
+
+	for _, target := range targets {
+		// if the target is a file, the base path is the directory containing it
+		basePath := target.basePath
+		err := walker.walkDir(target.root, func(targetPath string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
+			// a tar archive is treated as a directory - the include patterns and size limits apply to its members
+			isArchive := !d.IsDir() && isTarArchive(targetPath)
+			// skip any paths excluded by the include/exclude patterns - do not walk excluded directories
+			if pathFilter.skip(basePath, targetPath, d.IsDir() || isArchive) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			// discover the members of the archive
+			if isArchive {
+				return s.walkArchive(ctx, executionId, targetPath, pathFilter, optionalLayouts, g, filterMap)
+			}
+			// skip any files outside the collection time range or the configured size limits
+			if !d.IsDir() && s.skipFileInfo(targetPath, d) {
+				return nil
+			}
+			// if we are following files, skip any previously collected file which has no new data
+			if !d.IsDir() && s.Config.FollowEnabled() && !s.hasNewData(targetPath, d) {
+				return nil
+			}
 			return s.WalkNode(ctx, targetPath, basePath, optionalLayouts, d.IsDir(), g, filterMap)
 		})
 		if err != nil {
@@ -84,7 +175,7 @@ func (s *FileSource) DiscoverArtifacts(ctx context.Context) error {
 			errStr := err.Error()
 
 			// non-fatal error, log, notify and then attempt next path
-			slog.Error("FileSource.DiscoverArtifacts error walking file path", "path", basePath, "error", errStr)
+			slog.Error("FileSource.DiscoverArtifacts error walking file path", "path", target.root, "error", errStr)
 
 			// remove op from error string
 			parts := strings.Fields(errStr)
@@ -102,8 +193,80 @@ func (s *FileSource) DiscoverArtifacts(ctx context.Context) error {
 	return nil
 }
 
+// handleMissingPaths reports any paths which do not exist (or globs which match nothing), according to on_missing_path
+// NOTE: if on_missing_path is "error", a missing path will have failed validation - however a path may have been
+// removed since, or a glob may not match anything, so these are reported as non-fatal errors, as for "warn"
+func (s *FileSource) handleMissingPaths(ctx context.Context, executionId string, missingPaths []string) {
+	for _, path := range missingPaths {
+		if s.Config.GetOnMissingPath() == OnMissingPathIgnore {
+			slog.Info("FileSource.DiscoverArtifacts ignoring missing path", "path", path)
+			continue
+		}
+		slog.Warn("FileSource.DiscoverArtifacts path does not exist", "path", path)
+		s.NotifyError(ctx, executionId, fmt.Errorf("%s: path does not exist", path))
+	}
+}
+
+// skipFileInfo returns whether the file should be skipped based on its size or modification time
+// - if the file was last modified before the collection from time, it cannot contain any data we need
+// - if the file was last modified after the collection to time, we assume it contains data after the to time
+// (this check can be disabled using filter_mod_time, for files whose modification times are not reliable)
+func (s *FileSource) skipFileInfo(targetPath string, d fs.DirEntry) bool {
+	filterModTime := s.Config.ModTimeFilterEnabled()
+	if !filterModTime && s.Config.MinSize == nil && s.Config.MaxSize == nil {
+		return false
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		// let DownloadArtifact report the error
+		return false
+	}
+
+	if s.Config.MinSize != nil && info.Size() < *s.Config.MinSize {
+		slog.Debug("FileSource skipping file smaller than min_size", "file", targetPath, "size", info.Size())
+		return true
+	}
+	if s.Config.MaxSize != nil && info.Size() > *s.Config.MaxSize {
+		slog.Debug("FileSource skipping file larger than max_size", "file", targetPath, "size", info.Size())
+		return true
+	}
+
+	if filterModTime {
+		timeRange := s.CollectionTimeRange
+		modTime := info.ModTime()
+		if !timeRange.LowerBoundary.IsZero() && modTime.Before(timeRange.LowerBoundary) {
+			slog.Debug("FileSource skipping file modified before the collection from time", "file", targetPath, "mod time", modTime)
+			return true
+		}
+		if !timeRange.UpperBoundary.IsZero() && modTime.After(timeRange.UpperBoundary) {
+			slog.Debug("FileSource skipping file modified after the collection to time", "file", targetPath, "mod time", modTime)
+			return true
+		}
+	}
+	return false
+}
+
+// initFollowState takes a snapshot of the follow state for use when recognising rotated files,
+// then removes the follow state for any files which no longer exist
+func (s *FileSource) initFollowState() {
+	state := s.fileCollectionState()
+	s.previousFileStates = state.GetFileStates()
+
+	for path := range s.previousFileStates {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			state.RemoveFileState(path)
+		}
+	}
+}
+
 // DownloadArtifact does nothing as the artifact already exists on the local file system
+// (unless the artifact is an archive member which cannot be read from the archive - see downloadArchiveMember)
 func (s *FileSource) DownloadArtifact(ctx context.Context, info *types.ArtifactInfo) error {
+	if member, ok := s.archiveMembers.get(info.Name); ok {
+		return s.downloadArchiveMember(ctx, info, member)
+	}
+
 	// for file source, the local name is the same as the name
 	localName := info.Name
 	fileName := filepath.Base(localName)
@@ -123,6 +286,76 @@ func (s *FileSource) DownloadArtifact(ctx context.Context, info *types.ArtifactI
 	}
 	f.Close()
 
+	// if we are following files, only collect the data appended since the previous collection
+	if s.Config.FollowEnabled() {
+		if canFollow(localName) {
+			return s.downloadFollowedFile(ctx, info, fileInfo)
+		}
+		// if this is a compressed rotation of a followed file, collect the data after the previous offset
+		if fileState := s.fileCollectionState().GetFileState(localName); fileState != nil && canDecompress(localName) {
+			return s.downloadRotatedArchive(ctx, info, fileInfo, fileState)
+		}
+	}
+
+	// if the table's loader reads the file itself, the file must be transcoded before it is loaded
+	if !s.usesFileLoader && s.Config.GetEncoding() != "" && compression.Detect(localName) != compression.Zip {
+		return s.downloadTranscoded(ctx, info, localName, fileInfo)
+	}
+
 	// notify observers of the downloaded artifact
 	return s.OnArtifactDownloaded(ctx, types.NewDownloadedArtifactInfo(info, localName, fileInfo.Size()))
 }
+
+// downloadArchiveMember notifies observers of a downloaded archive member
+// If we are using the file loader, the member is read directly from the archive when it is loaded, so nothing is
+// written to disk. Otherwise (or if the member is a zip file, which can not be streamed), the member is copied to the
+// temp dir, as the loader must read a local file
+func (s *FileSource) downloadArchiveMember(ctx context.Context, info *types.ArtifactInfo, member *archiveMember) error {
+	fileName := path.Base(member.path)
+
+	// ensure the archive still exists
+	if _, err := os.Stat(member.archivePath); err != nil {
+		slog.Error("FileSource.DownloadArtifact error obtaining archive info", "archive", member.archivePath, "error", err)
+		return fmt.Errorf("%s: unable to obtain archive info", filepath.Base(member.archivePath))
+	}
+
+	if s.usesFileLoader && canStream(member.path) {
+		return s.OnArtifactDownloaded(ctx, types.NewDownloadedArtifactInfo(info, info.Name, member.size))
+	}
+
+	r, err := s.archiveMembers.open(member)
+	if err != nil {
+		slog.Error("FileSource.DownloadArtifact error opening archive member", "artifact", info.Name, "error", err)
+		return fmt.Errorf("%s: unable to open archive member", fileName)
+	}
+	defer r.Close()
+
+	var localName string
+	var size int64
+	if !s.usesFileLoader && s.Config.GetEncoding() != "" && canStream(member.path) {
+		// the table's loader reads the file itself, so the member must be decompressed and transcoded
+		dr, err := compression.Decompress(r, member.path)
+		if err != nil {
+			slog.Error("FileSource.DownloadArtifact error decompressing archive member", "artifact", info.Name, "error", err)
+			return fmt.Errorf("%s: unable to decompress archive member", fileName)
+		}
+		localName, size, err = s.transcodeToTemp(ctx, dr, compression.DecompressedName(fileName))
+	} else {
+		localName, size, err = s.copyToTemp(r, fileName, false)
+	}
+	if err != nil {
+		slog.Error("FileSource.DownloadArtifact error copying archive member", "artifact", info.Name, "error", err)
+		return fmt.Errorf("%s: unable to copy archive member", fileName)
+	}
+	// the copy has the modification time of the member, as loaders may use it (e.g. to infer the year of timestamps)
+	setModTime(localName, member.modTime)
+	return s.OnArtifactDownloaded(ctx, types.NewDownloadedArtifactInfo(info, localName, size))
+}
+
+// setModTime sets the modification time of a copy of a file to that of the original file
+// failure is not fatal - the copy keeps the time it was written
+func setModTime(localName string, modTime time.Time) {
+	if err := os.Chtimes(localName, modTime, modTime); err != nil {
+		slog.Warn("FileSource.DownloadArtifact error setting modification time of copy", "file", localName, "error", err)
+	}
+}
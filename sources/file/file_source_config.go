@@ -2,13 +2,29 @@ package file
 
 import (
 	"fmt"
+	"os"
+	"slices"
+
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/hashicorp/hcl/v2"
-	"github.com/turbot/pipe-fittings/v2/utils"
+	typehelpers "github.com/turbot/go-kit/types"
 	"github.com/turbot/tailpipe-plugin-sdk/artifact_source_config"
 )
 
 const (
 	FileSourceIdentifier = "file"
+
+	defaultDiscoveryConcurrency = 8
+)
+
+// the policies for handling a path which does not exist
+const (
+	// a missing path fails validation of the source config
+	OnMissingPathError = "error"
+	// a missing path is reported as a non-fatal error when collecting
+	OnMissingPathWarn = "warn"
+	// a missing path is ignored
+	OnMissingPathIgnore = "ignore"
 )
 
 type FileSourceConfig struct {
@@ -17,6 +33,43 @@ type FileSourceConfig struct {
 	Remain hcl.Body `hcl:",remain" json:"-"`
 
 	Paths []string `hcl:"paths"`
+
+	// if set, the source records how far each file has been read and on subsequent collections
+	// only collects lines which have been appended since the previous collection
+	Follow *bool `hcl:"follow,optional"`
+
+	// glob patterns (doublestar syntax) matched against the path relative to the source path
+	// (for the members of a tar archive, the patterns are matched against the path within the archive)
+	// if set, only files which match at least one include pattern are collected
+	Include []string `hcl:"include,optional"`
+	// files and directories which match any exclude pattern are skipped - excluded directories are not walked
+	Exclude []string `hcl:"exclude,optional"`
+
+	// if set, files smaller than min_size or larger than max_size (in bytes) are not collected
+	MinSize *int64 `hcl:"min_size,optional"`
+	MaxSize *int64 `hcl:"max_size,optional"`
+
+	// by default, files last modified before the collection from time, or after the collection to time, are not
+	// collected - set this to false if the file modification times do not reflect the data in the files
+	// (e.g. if the files have been copied or restored from a backup)
+	FilterModTime *bool `hcl:"filter_mod_time,optional"`
+
+	// the maximum number of directories to read concurrently when discovering files
+	DiscoveryConcurrency *int `hcl:"discovery_concurrency,optional"`
+
+	// if set, symlinks to files and directories within the paths are followed
+	// (a path which is itself a symlink to a directory is always followed)
+	FollowSymlinks *bool `hcl:"follow_symlinks,optional"`
+
+	// how to handle a path which does not exist: "error" (the default), "warn" or "ignore"
+	OnMissingPath *string `hcl:"on_missing_path,optional"`
+
+	// the character encoding of the files, e.g. UTF-16LE, ISO-8859-1 or Shift_JIS - the text is transcoded to UTF-8
+	// set to "auto" to detect the encoding of each file from its byte order mark (files without one are UTF-8)
+	// rows containing sequences which are invalid in the encoding are reported as row errors
+	// NOTE: UTF-16 and UTF-32 files can not be followed (when following files, the byte order mark is only read when a
+	// file is first collected, so "auto" should not be used to follow these files)
+	Encoding *string `hcl:"encoding,optional"`
 }
 
 func (f *FileSourceConfig) Validate() error {
@@ -30,16 +83,99 @@ func (f *FileSourceConfig) Validate() error {
 		return fmt.Errorf("required field: paths can not be empty")
 	}
 
-	// validate paths exist on the file system
+	// validate the missing path policy
+	onMissingPath := f.GetOnMissingPath()
+	if !slices.Contains([]string{OnMissingPathError, OnMissingPathWarn, OnMissingPathIgnore}, onMissingPath) {
+		return fmt.Errorf("invalid on_missing_path %s: must be one of %s, %s or %s", onMissingPath, OnMissingPathError, OnMissingPathWarn, OnMissingPathIgnore)
+	}
+
+	// validate paths exist on the file system - a path may be a directory, a file or a glob
 	for _, path := range f.Paths {
-		if !utils.IsValidDir(path) {
-			return fmt.Errorf("path %s is not a directory or does not exist", path)
+		if isGlob(path) {
+			// globs are expanded when discovering files, so they need not match anything yet
+			if !doublestar.ValidatePathPattern(path) {
+				return fmt.Errorf("path %s is not a valid glob", path)
+			}
+			continue
+		}
+		// if the missing path policy is not "error", missing paths are reported when collecting
+		if _, err := os.Stat(path); err != nil && onMissingPath == OnMissingPathError {
+			return fmt.Errorf("path %s does not exist", path)
+		}
+	}
+
+	// validate the include and exclude patterns
+	if err := validateGlobs("include", f.Include); err != nil {
+		return err
+	}
+	if err := validateGlobs("exclude", f.Exclude); err != nil {
+		return err
+	}
+
+	// validate the size limits
+	if f.MinSize != nil && *f.MinSize < 0 {
+		return fmt.Errorf("min_size can not be negative")
+	}
+	if f.MaxSize != nil && *f.MaxSize < 0 {
+		return fmt.Errorf("max_size can not be negative")
+	}
+	if f.MinSize != nil && f.MaxSize != nil && *f.MinSize > *f.MaxSize {
+		return fmt.Errorf("min_size can not be greater than max_size")
+	}
+
+	if f.DiscoveryConcurrency != nil && *f.DiscoveryConcurrency < 1 {
+		return fmt.Errorf("discovery_concurrency must be at least 1")
+	}
+
+	if f.Encoding != nil {
+		if err := validateEncoding(*f.Encoding); err != nil {
+			return fmt.Errorf("invalid encoding: %w", err)
+		}
+		// followed files are read from the last complete line, which can not be found in multi-byte encodings
+		if f.FollowEnabled() && isWideEncoding(*f.Encoding) {
+			return fmt.Errorf("encoding %s can not be used with follow", *f.Encoding)
 		}
 	}
 
 	return nil
 }
 
+// GetDiscoveryConcurrency returns the maximum number of directories to read concurrently when discovering files
+func (f *FileSourceConfig) GetDiscoveryConcurrency() int {
+	if f.DiscoveryConcurrency == nil {
+		return defaultDiscoveryConcurrency
+	}
+	return *f.DiscoveryConcurrency
+}
+
+// ModTimeFilterEnabled returns whether files should be filtered by their modification time (default true)
+func (f *FileSourceConfig) ModTimeFilterEnabled() bool {
+	return f.FilterModTime == nil || *f.FilterModTime
+}
+
+// GetOnMissingPath returns the policy for handling a path which does not exist (default "error")
+func (f *FileSourceConfig) GetOnMissingPath() string {
+	if f.OnMissingPath == nil {
+		return OnMissingPathError
+	}
+	return *f.OnMissingPath
+}
+
+// GetEncoding returns the character encoding of the files ("" if the files should not be transcoded)
+func (f *FileSourceConfig) GetEncoding() string {
+	return typehelpers.SafeString(f.Encoding)
+}
+
+// FollowSymlinksEnabled returns whether symlinks should be followed when discovering files
+func (f *FileSourceConfig) FollowSymlinksEnabled() bool {
+	return typehelpers.BoolValue(f.FollowSymlinks)
+}
+
+// FollowEnabled returns whether follow mode is enabled
+func (f *FileSourceConfig) FollowEnabled() bool {
+	return typehelpers.BoolValue(f.Follow)
+}
+
 func (f *FileSourceConfig) Identifier() string {
 	return FileSourceIdentifier
 }
@@ -0,0 +1,107 @@
+package file
+
+import (
+	"testing"
+)
+
+func TestFileSourceConfig_Validate(t *testing.T) {
+	warn := OnMissingPathWarn
+	ignore := OnMissingPathIgnore
+	invalid := "skip"
+	follow := true
+
+	tests := []struct {
+		name          string
+		paths         []string
+		onMissingPath *string
+		encoding      string
+		follow        bool
+		wantErr       bool
+	}{
+		{
+			name:  "directory",
+			paths: []string{"./test_data/discover_test_1"},
+		},
+		{
+			name:  "file",
+			paths: []string{"./test_data/discover_test_1/top1.log"},
+		},
+		{
+			name:  "glob which matches nothing",
+			paths: []string{"./test_data/*/missing/*.log"},
+		},
+		{
+			name:    "invalid glob",
+			paths:   []string{"./test_data/[a-"},
+			wantErr: true,
+		},
+		{
+			name:    "missing path",
+			paths:   []string{"./test_data/discover_test_1", "./test_data/missing"},
+			wantErr: true,
+		},
+		{
+			name:          "missing path with warn",
+			paths:         []string{"./test_data/discover_test_1", "./test_data/missing"},
+			onMissingPath: &warn,
+		},
+		{
+			name:          "missing path with ignore",
+			paths:         []string{"./test_data/missing"},
+			onMissingPath: &ignore,
+		},
+		{
+			name:          "invalid on_missing_path",
+			paths:         []string{"./test_data/discover_test_1"},
+			onMissingPath: &invalid,
+			wantErr:       true,
+		},
+		{
+			name:     "auto encoding",
+			paths:    []string{"./test_data/discover_test_1"},
+			encoding: EncodingAuto,
+		},
+		{
+			name:     "explicit encoding",
+			paths:    []string{"./test_data/discover_test_1"},
+			encoding: "Shift_JIS",
+		},
+		{
+			name:     "unsupported encoding",
+			paths:    []string{"./test_data/discover_test_1"},
+			encoding: "EBCDIC-XX",
+			wantErr:  true,
+		},
+		{
+			name:     "follow with single byte encoding",
+			paths:    []string{"./test_data/discover_test_1"},
+			encoding: "ISO-8859-1",
+			follow:   true,
+		},
+		{
+			name:     "follow with UTF-16 encoding",
+			paths:    []string{"./test_data/discover_test_1"},
+			encoding: "UTF-16LE",
+			follow:   true,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &FileSourceConfig{
+				Paths:         tt.paths,
+				OnMissingPath: tt.onMissingPath,
+			}
+			if tt.encoding != "" {
+				config.Encoding = &tt.encoding
+			}
+			if tt.follow {
+				config.Follow = &follow
+			}
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
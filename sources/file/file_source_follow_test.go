@@ -0,0 +1,297 @@
+package file
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-core/formats"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/events"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+type rowObserver struct {
+	Rows []string
+	mut  sync.Mutex
+}
+
+func (r *rowObserver) Notify(_ context.Context, e events.Event) error {
+	if row, ok := e.(*events.RowExtracted); ok {
+		r.mut.Lock()
+		// a row which could not be read is sent as an error
+		switch data := row.Row.(type) {
+		case error:
+			r.Rows = append(r.Rows, "error: "+data.Error())
+		case map[string]string:
+			// records read by the json loader
+			r.Rows = append(r.Rows, fmt.Sprint(data))
+		case *formats.SyslogLine:
+			// lines loaded by the syslog loader, with the modification time of the file
+			r.Rows = append(r.Rows, data.ModTime.UTC().Format(time.DateOnly)+" "+data.Line)
+		default:
+			r.Rows = append(r.Rows, data.(string))
+		}
+		r.mut.Unlock()
+	}
+	return nil
+}
+
+func TestFileSource_Follow(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(logDir, "app.log")
+	statePath := filepath.Join(dir, "state.json")
+
+	steps := []struct {
+		name         string
+		appendData   string
+		expectedRows []string
+	}{
+		{
+			name:         "initial collection reads complete lines",
+			appendData:   "line 1\nline 2\npartial",
+			expectedRows: []string{"line 1", "line 2"},
+		},
+		{
+			name:         "no new data",
+			appendData:   "",
+			expectedRows: nil,
+		},
+		{
+			name:         "appended data is collected, including the completed partial line",
+			appendData:   " line 3\nline 4\n",
+			expectedRows: []string{"partial line 3", "line 4"},
+		},
+	}
+
+	for _, step := range steps {
+		f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(step.appendData); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		rows := collectFollowed(t, logDir, "%{DATA}.log", statePath, filepath.Join(dir, "collection"))
+		if len(rows) != len(step.expectedRows) {
+			t.Fatalf("%s: expected rows %v, got %v", step.name, step.expectedRows, rows)
+		}
+		for i, expected := range step.expectedRows {
+			if rows[i] != expected {
+				t.Errorf("%s: expected row %d to be %q, got %q", step.name, i, expected, rows[i])
+			}
+		}
+	}
+}
+
+func TestFileSource_FollowRotation(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(logDir, "app.log")
+	statePath := filepath.Join(dir, "state.json")
+	tempDir := filepath.Join(dir, "collection")
+
+	// write enough data for the file to be fingerprinted
+	var initialRows []string
+	for i := 0; i < 50; i++ {
+		initialRows = append(initialRows, fmt.Sprintf("initial row %02d with some padding to fill the head of the file", i))
+	}
+	writeLines(t, logPath, initialRows...)
+
+	rows := collectFollowed(t, logDir, "%{DATA}", statePath, tempDir)
+	assertRows(t, "initial collection", rows, initialRows)
+
+	// rename rotation - the original file has a final line written before rotation and a new file is created
+	writeLines(t, logPath, "written before rotation")
+	if err := os.Rename(logPath, logPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, logPath, "written after rotation")
+
+	rows = collectFollowed(t, logDir, "%{DATA}", statePath, tempDir)
+	assertRows(t, "rename rotation", rows, []string{"written after rotation", "written before rotation"})
+
+	// compressed rotation - the rotated file is compressed, so it should not be collected again
+	compressFile(t, logPath+".1", logPath+".2.gz")
+
+	rows = collectFollowed(t, logDir, "%{DATA}", statePath, tempDir)
+	assertRows(t, "compressed rotation", rows, nil)
+
+	// copytruncate rotation - the file is truncated in place and then written to
+	if err := os.Truncate(logPath, 0); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, logPath, "after truncate")
+
+	rows = collectFollowed(t, logDir, "%{DATA}", statePath, tempDir)
+	assertRows(t, "copytruncate rotation", rows, []string{"after truncate"})
+}
+
+func TestFileSource_FollowRotationSharedHeader(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	statePath := filepath.Join(dir, "state.json")
+	tempDir := filepath.Join(dir, "collection")
+
+	// all files start with the same header, which is larger than the fingerprint
+	var header []string
+	for i := 0; i < 20; i++ {
+		header = append(header, fmt.Sprintf("# header line %02d with some padding to fill the head of the file", i))
+	}
+	withHeader := func(rows ...string) []string {
+		return append(slices.Clone(header), rows...)
+	}
+	aPath := filepath.Join(logDir, "a.csv")
+	bPath := filepath.Join(logDir, "b.csv")
+	writeLines(t, aPath, withHeader("a 1")...)
+	writeLines(t, bPath, withHeader("b 1", "b 2")...)
+
+	rows := collectFollowed(t, logDir, "%{DATA}", statePath, tempDir)
+	assertRows(t, "initial collection", rows, append(withHeader("a 1"), withHeader("b 1", "b 2")...))
+
+	// a new file with the same header is not a rotation of either file, so is collected in full
+	writeLines(t, filepath.Join(logDir, "c.csv"), withHeader("c 1")...)
+
+	rows = collectFollowed(t, logDir, "%{DATA}", statePath, tempDir)
+	assertRows(t, "new file with the same header", rows, withHeader("c 1"))
+
+	// a compressed rotation of b is matched to b, so only the data written since the last collection is collected
+	writeLines(t, bPath, "b 3")
+	if err := os.Rename(bPath, bPath+".1"); err != nil {
+		t.Fatal(err)
+	}
+	// (as logrotate does, the new file is created before the rotated file is compressed)
+	writeLines(t, bPath, withHeader("new b 1")...)
+	compressFile(t, bPath+".1", bPath+".1.gz")
+
+	rows = collectFollowed(t, logDir, "%{DATA}", statePath, tempDir)
+	assertRows(t, "compressed rotation", rows, append(withHeader("new b 1"), "b 3"))
+}
+
+func TestFileSource_FollowMultilineFlushTimeout(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(logDir, "app.log")
+	statePath := filepath.Join(dir, "state.json")
+	tempDir := filepath.Join(dir, "collection")
+
+	startPattern, flushTimeout := `^start`, "1h"
+	collect := func() []string {
+		loader := formats.NewMultilineLoader(&formats.Multiline{StartPattern: &startPattern, FlushTimeout: &flushTimeout})
+		return collectFollowed(t, logDir, "%{DATA}", statePath, tempDir, artifact_source.WithArtifactLoader(loader))
+	}
+
+	// the file has just been written, so the last record may be incomplete - it is left for the next collection
+	writeLines(t, logPath, "start 1", "more 1", "start 2", "more 2")
+	assertRows(t, "last record left", collect(), []string{"start 1\nmore 1"})
+
+	// the lines appended complete the record
+	writeLines(t, logPath, "more 2", "start 3")
+	assertRows(t, "record completed by appended lines", collect(), []string{"start 2\nmore 2\nmore 2"})
+
+	// once the file has not been written to for the flush timeout, the last record is collected
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(logPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, "last record flushed", collect(), []string{"start 3"})
+	assertRows(t, "no new data", collect(), nil)
+}
+
+func writeLines(t *testing.T, path string, lines ...string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range lines {
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func compressFile(t *testing.T, source, target string) {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzWriter := gzip.NewWriter(f)
+	if _, err := gzWriter.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	gzWriter.Close()
+	f.Close()
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertRows(t *testing.T, name string, rows, expectedRows []string) {
+	// rows from different files may be extracted in any order
+	sort.Strings(rows)
+	expectedRows = slices.Clone(expectedRows)
+	sort.Strings(expectedRows)
+	if !slices.Equal(rows, expectedRows) {
+		t.Fatalf("%s: expected rows %v, got %v", name, expectedRows, rows)
+	}
+}
+
+// collectFollowed runs a collection of the given directory in follow mode and returns the rows extracted
+func collectFollowed(t *testing.T, logDir, layout, statePath, tempDir string, opts ...row_source.RowSourceOption) []string {
+	ctx := context_values.WithExecutionId(context.Background(), "test")
+
+	s := &FileSource{}
+	any(s).(row_source.BaseSource).RegisterSource(s)
+
+	hclBytes := []byte(fmt.Sprintf("paths = [%q]\nfollow = true", logDir))
+	err := s.Init(ctx, &row_source.RowSourceParams{
+		SourceConfigData:    types.NewSourceConfigData(hclBytes, hcl.Range{}, "file"),
+		CollectionStatePath: statePath,
+		CollectionTempDir:   tempDir,
+	}, append([]row_source.RowSourceOption{artifact_source.WithRowPerLine()}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	s.Config.FileLayout = &layout
+
+	var observer rowObserver
+	_ = s.AddObserver(&observer)
+
+	if err := s.Collect(ctx); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if err := s.OnCollectionComplete(); err != nil {
+		t.Fatalf("OnCollectionComplete() error = %v", err)
+	}
+	return observer.Rows
+}
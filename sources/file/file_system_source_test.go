@@ -3,11 +3,14 @@ package file
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/turbot/tailpipe-plugin-sdk/artifact_source_config"
+	"github.com/turbot/tailpipe-plugin-sdk/collection_state"
 	"github.com/turbot/tailpipe-plugin-sdk/context_values"
 	"github.com/turbot/tailpipe-plugin-sdk/events"
 	"github.com/turbot/tailpipe-plugin-sdk/row_source"
@@ -30,6 +33,8 @@ func TestFileSource_DiscoverArtifacts(t *testing.T) {
 	type fields struct {
 		paths      []string
 		fileLayout string
+		include    []string
+		exclude    []string
 		//config     []byte
 	}
 
@@ -205,6 +210,94 @@ func TestFileSource_DiscoverArtifacts(t *testing.T) {
 			},
 			expectedArtifacts: []string{},
 		},
+		{
+			name: "exclude directory glob",
+			fields: fields{
+				paths:      []string{"./test_data/discover_test_1"},
+				fileLayout: "%{DATA}",
+				exclude:    []string{"AWSLogs/org2/**", "other/"},
+			},
+			expectedArtifacts: []string{
+				"./test_data/discover_test_1/top1.log",
+				"./test_data/discover_test_1/top2.log",
+				"./test_data/discover_test_1/AWSLogs/non_leafile.log",
+				"./test_data/discover_test_1/AWSLogs/org1/1/CloudTrail/1_1.log",
+				"./test_data/discover_test_1/AWSLogs/org1/1/CloudTrail/1_2.log",
+				"./test_data/discover_test_1/AWSLogs/org1/2/CloudTrail/2_1.log",
+				"./test_data/discover_test_1/AWSLogs/org1/2/CloudTrail/2_2.log",
+				"./test_data/discover_test_1/AWSLogs/org1/3/CloudTrail/3_1.log",
+				"./test_data/discover_test_1/AWSLogs/org1/3/CloudTrail/3_2.log",
+			},
+		},
+		{
+			name: "exclude file name at any depth",
+			fields: fields{
+				paths:      []string{"./test_data/discover_test_1"},
+				fileLayout: "%{DATA}",
+				exclude:    []string{"*_1.log", "*_2.log", "*_3.log"},
+			},
+			expectedArtifacts: []string{
+				"./test_data/discover_test_1/top1.log",
+				"./test_data/discover_test_1/top2.log",
+				"./test_data/discover_test_1/AWSLogs/non_leafile.log",
+				"./test_data/discover_test_1/other/foo1.log",
+				"./test_data/discover_test_1/other/foo2.log",
+			},
+		},
+		{
+			name: "include and exclude",
+			fields: fields{
+				paths:      []string{"./test_data/discover_test_1"},
+				fileLayout: "%{DATA}",
+				include:    []string{"AWSLogs/**/*_3.log", "top*.log"},
+				exclude:    []string{"6/"},
+			},
+			expectedArtifacts: []string{
+				"./test_data/discover_test_1/top1.log",
+				"./test_data/discover_test_1/top2.log",
+				"./test_data/discover_test_1/AWSLogs/org2/4/CloudTrail/4_3.log",
+				"./test_data/discover_test_1/AWSLogs/org2/5/CloudTrail/5_3.log",
+			},
+		},
+		{
+			name: "file path",
+			fields: fields{
+				paths:      []string{"./test_data/discover_test_1/top1.log"},
+				fileLayout: "%{DATA}.log",
+			},
+			expectedArtifacts: []string{
+				"./test_data/discover_test_1/top1.log",
+			},
+		},
+		{
+			name: "glob paths",
+			fields: fields{
+				paths:      []string{"./test_data/discover_test_1/AWSLogs/org1/*/CloudTrail/*_1.log", "./test_data/discover_test_1/top*.log"},
+				fileLayout: "%{DATA}",
+			},
+			expectedArtifacts: []string{
+				"./test_data/discover_test_1/AWSLogs/org1/1/CloudTrail/1_1.log",
+				"./test_data/discover_test_1/AWSLogs/org1/2/CloudTrail/2_1.log",
+				"./test_data/discover_test_1/AWSLogs/org1/3/CloudTrail/3_1.log",
+				"./test_data/discover_test_1/top1.log",
+				"./test_data/discover_test_1/top2.log",
+			},
+		},
+		{
+			name: "glob path matching directories",
+			fields: fields{
+				paths:      []string{"./test_data/discover_test_*/AWSLogs/*/CloudTrail"},
+				fileLayout: "%{DATA}.log",
+			},
+			expectedArtifacts: []string{
+				"./test_data/discover_test_no_org/AWSLogs/1/CloudTrail/1_1.log",
+				"./test_data/discover_test_no_org/AWSLogs/1/CloudTrail/1_2.log",
+				"./test_data/discover_test_no_org/AWSLogs/2/CloudTrail/2_1.log",
+				"./test_data/discover_test_no_org/AWSLogs/2/CloudTrail/2_2.log",
+				"./test_data/discover_test_no_org/AWSLogs/3/CloudTrail/3_1.log",
+				"./test_data/discover_test_no_org/AWSLogs/3/CloudTrail/3_2.log",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -216,7 +309,9 @@ func TestFileSource_DiscoverArtifacts(t *testing.T) {
 				ArtifactSourceConfigImpl: artifact_source_config.ArtifactSourceConfigImpl{
 					FileLayout: &tt.fields.fileLayout,
 				},
-				Paths: tt.fields.paths,
+				Paths:   tt.fields.paths,
+				Include: tt.fields.include,
+				Exclude: tt.fields.exclude,
 			}
 
 			s, err := getFileSource(ctx, t, config)
@@ -249,6 +344,132 @@ func TestFileSource_DiscoverArtifacts(t *testing.T) {
 	}
 }
 
+func TestFileSource_DiscoverArtifactsFileInfo(t *testing.T) {
+	now := time.Now()
+	timeRange := collection_state.DirectionalTimeRange{
+		LowerBoundary: now.Add(-48 * time.Hour),
+		UpperBoundary: now.Add(-24 * time.Hour),
+	}
+
+	// create files with the given modification times and sizes
+	dir := t.TempDir()
+	files := []struct {
+		name    string
+		modTime time.Time
+		size    int
+	}{
+		{name: "before.log", modTime: now.Add(-72 * time.Hour), size: 10},
+		{name: "during.log", modTime: now.Add(-36 * time.Hour), size: 10},
+		{name: "during_large.log", modTime: now.Add(-36 * time.Hour), size: 1000},
+		{name: "during_small.log", modTime: now.Add(-36 * time.Hour), size: 1},
+		{name: "after.log", modTime: now, size: 10},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, make([]byte, f.size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, f.modTime, f.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	minSize := int64(5)
+	maxSize := int64(100)
+	disabled := false
+
+	tests := []struct {
+		name              string
+		config            *FileSourceConfig
+		expectedArtifacts []string
+	}{
+		{
+			name:              "mod time filter",
+			config:            &FileSourceConfig{},
+			expectedArtifacts: []string{"during.log", "during_large.log", "during_small.log"},
+		},
+		{
+			name:              "mod time and size filter",
+			config:            &FileSourceConfig{MinSize: &minSize, MaxSize: &maxSize},
+			expectedArtifacts: []string{"during.log"},
+		},
+		{
+			name:              "mod time filter disabled",
+			config:            &FileSourceConfig{FilterModTime: &disabled},
+			expectedArtifacts: []string{"after.log", "before.log", "during.log", "during_large.log", "during_small.log"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context_values.WithExecutionId(context.Background(), "test")
+
+			layout := "%{DATA}.log"
+			tt.config.FileLayout = &layout
+			tt.config.Paths = []string{dir}
+
+			s, err := getFileSource(ctx, t, tt.config)
+			if err != nil {
+				t.Fatalf("failed to get file source")
+			}
+			s.CollectionTimeRange = timeRange
+
+			var observer testObserver
+			_ = s.AddObserver(&observer)
+
+			if err := s.DiscoverArtifacts(ctx); err != nil {
+				t.Fatalf("DiscoverArtifacts() error = %v", err)
+			}
+			if len(observer.Artifacts) != len(tt.expectedArtifacts) {
+				t.Fatalf("DiscoverArtifacts() expected artifacts %v, got %v", tt.expectedArtifacts, observer.Artifacts)
+			}
+			for i, expected := range tt.expectedArtifacts {
+				if observer.Artifacts[i] != filepath.Join(dir, expected) {
+					t.Errorf("DiscoverArtifacts() expected artifact %v, got %v", expected, observer.Artifacts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFileSource_ModTimeFilter(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	if err := os.Mkdir(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the old file was last modified before the default collection from time, e.g. it was restored from a backup
+	writeLines(t, filepath.Join(logDir, "old.log"), "old 1")
+	oldTime := time.Now().Add(-30 * 24 * time.Hour)
+	if err := os.Chtimes(filepath.Join(logDir, "old.log"), oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, filepath.Join(logDir, "new.log"), "new 1")
+
+	tests := []struct {
+		name         string
+		config       []string
+		expectedRows []string
+	}{
+		{
+			name:         "filter on by default - the old file is skipped",
+			expectedRows: []string{"new 1"},
+		},
+		{
+			name:         "filter off - the old file is collected",
+			config:       []string{"filter_mod_time = false"},
+			expectedRows: []string{"new 1", "old 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			rows, _ := collectFiles(t, logDir, "%{DATA}.log", filepath.Join(tempDir, "state.json"), filepath.Join(tempDir, "collection"), tt.config...)
+			assertRows(t, tt.name, rows, tt.expectedRows)
+		})
+	}
+}
+
 func getFileSource(ctx context.Context, t *testing.T, config *FileSourceConfig) (*FileSource, error) {
 
 	s := &FileSource{}
@@ -266,6 +487,9 @@ func getFileSource(ctx context.Context, t *testing.T, config *FileSourceConfig)
 	// set the config
 	s.Config = config
 
+	// clear the collection time range, so files are not filtered by the modification time of the test data
+	s.CollectionTimeRange = collection_state.DirectionalTimeRange{}
+
 	// ensure all paths are absolute
 	for i, p := range s.Config.Paths {
 		abs, err := filepath.Abs(p)
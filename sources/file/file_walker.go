@@ -0,0 +1,335 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// dirWalker walks file trees, calling the walk function for each file and directory, and reads the listings of the
+// directories which will be walked ahead of the walk concurrently
+//
+// The walk function is called for all entries of a directory (in lexical order) before the walk descends into the
+// subdirectories it accepted, again in lexical order. When the walk function accepts a directory, its listing is
+// queued to be read (and the files in it stat'ed) by a fixed pool of workers, so the latency of reading a large or
+// remote (e.g. NFS) tree is incurred in parallel, up to the configured concurrency. Directories which are skipped
+// are never read.
+// The walk function is only ever called from the goroutine calling walkDir, so the walk order is deterministic.
+// SkipDir and SkipAll have the same meaning as for filepath.WalkDir
+//
+// Unlike filepath.WalkDir, if the root is a symlink to a directory it is walked. If followSymlinks is set,
+// symlinks within the tree are also followed - symlinks which form a cycle, or whose target does not exist,
+// are reported using onError and skipped
+type dirWalker struct {
+	followSymlinks bool
+	// called with any non-fatal error encountered following symlinks
+	onError func(error)
+	// map of the identity of each directory walked to the path it was walked at - used to detect symlink cycles
+	// (only populated if following symlinks)
+	visited map[string]string
+	// listings which have been requested but not yet consumed, keyed by directory path
+	// NOTE: this is only accessed from the walking goroutine so does not need a lock
+	pending map[string]<-chan dirListing
+
+	// the number of workers reading directories
+	concurrency int
+	// the directories queued to be read by the workers
+	queue []dirRequest
+	// whether the workers have been started, and whether they have been stopped
+	started, closed bool
+	// the lock for the queue, and the condition signalled when a directory is queued or the walker is closed
+	queueMut  sync.Mutex
+	queueCond *sync.Cond
+}
+
+// dirRequest is a request for a worker to read a directory
+type dirRequest struct {
+	path string
+	res  chan<- dirListing
+}
+
+// dirListing is the result of reading a directory
+type dirListing struct {
+	entries []fs.DirEntry
+	err     error
+}
+
+func newDirWalker(concurrency int, followSymlinks bool, onError func(error)) *dirWalker {
+	w := &dirWalker{
+		followSymlinks: followSymlinks,
+		onError:        onError,
+		visited:        make(map[string]string),
+		pending:        make(map[string]<-chan dirListing),
+		concurrency:    max(concurrency, 1),
+	}
+	w.queueCond = sync.NewCond(&w.queueMut)
+	return w
+}
+
+// prefetch queues the given directories to be read in the background
+// this is called for each directory the walk function accepts, and is also used to read the root directories of
+// all paths before walking them in turn
+func (w *dirWalker) prefetch(paths ...string) {
+	w.queueMut.Lock()
+	defer w.queueMut.Unlock()
+
+	for _, path := range paths {
+		if _, ok := w.pending[path]; ok {
+			continue
+		}
+		res := make(chan dirListing, 1)
+		w.pending[path] = res
+		w.queue = append(w.queue, dirRequest{path: path, res: res})
+	}
+
+	// start the workers the first time a directory is queued
+	if !w.started {
+		w.started = true
+		for range w.concurrency {
+			go w.worker()
+		}
+	}
+	w.queueCond.Broadcast()
+}
+
+// worker reads the queued directories until the walker is closed
+func (w *dirWalker) worker() {
+	for {
+		w.queueMut.Lock()
+		for len(w.queue) == 0 && !w.closed {
+			w.queueCond.Wait()
+		}
+		if w.closed {
+			w.queueMut.Unlock()
+			return
+		}
+		req := w.queue[0]
+		w.queue = w.queue[1:]
+		w.queueMut.Unlock()
+
+		req.res <- w.readDir(req.path)
+	}
+}
+
+// close stops the workers - any directories which are still queued are not read
+func (w *dirWalker) close() {
+	w.queueMut.Lock()
+	defer w.queueMut.Unlock()
+
+	w.closed = true
+	w.queue = nil
+	w.queueCond.Broadcast()
+}
+
+// discard removes the pending listings for directories which will not be walked
+// directories which have not yet been read are removed from the queue - if a read has started, the result is discarded
+func (w *dirWalker) discard(paths ...string) {
+	if len(paths) == 0 {
+		return
+	}
+	discarded := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		if _, ok := w.pending[path]; ok {
+			delete(w.pending, path)
+			discarded[path] = struct{}{}
+		}
+	}
+	if len(discarded) == 0 {
+		return
+	}
+
+	w.queueMut.Lock()
+	defer w.queueMut.Unlock()
+	w.queue = slices.DeleteFunc(w.queue, func(req dirRequest) bool {
+		_, ok := discarded[req.path]
+		return ok
+	})
+}
+
+// listing returns the listing for the given directory, waiting for the prefetched listing if there is one
+func (w *dirWalker) listing(path string) dirListing {
+	res, ok := w.pending[path]
+	if !ok {
+		return w.readDir(path)
+	}
+	delete(w.pending, path)
+	return <-res
+}
+
+// walkDir walks the file tree rooted at root, calling fn for each file or directory in the tree, including root
+// A root which is a symlink to a directory is walked (this is consistent with the validation of the source paths,
+// which resolves symlinks)
+func (w *dirWalker) walkDir(root string, fn fs.WalkDirFunc) error {
+	info, err := os.Lstat(root)
+	if err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		info, err = os.Stat(root)
+	}
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		d := fs.FileInfoToDirEntry(info)
+		var walk bool
+		if walk, err = w.visit(root, d, fn); walk {
+			err = w.walk(root, d, fn)
+		}
+	}
+	// discard any listing prefetched for the root if we did not walk it
+	w.discard(root)
+
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// visit calls fn for the path, and returns whether it is a directory which should be walked
+func (w *dirWalker) visit(path string, d fs.DirEntry, fn fs.WalkDirFunc) (bool, error) {
+	// if this is a symlink we could not resolve, report and skip it
+	if entry, ok := d.(*statDirEntry); ok && entry.linkErr != nil {
+		w.onError(entry.linkErr)
+		return false, nil
+	}
+
+	// if we are following symlinks, we may reach a directory more than once - only walk it the first time
+	dirKey := ""
+	if w.followSymlinks && d.IsDir() {
+		dirKey = w.dirKey(path, d)
+		if firstPath, ok := w.visited[dirKey]; ok {
+			if strings.HasPrefix(path, firstPath+string(filepath.Separator)) {
+				w.onError(fmt.Errorf("%s: symlink cycle detected, directory is already being walked as %s", path, firstPath))
+			} else {
+				slog.Debug("dirWalker skipping directory which has already been walked", "path", path, "walked as", firstPath)
+			}
+			return false, nil
+		}
+	}
+
+	if err := fn(path, d, nil); err != nil {
+		if err == filepath.SkipDir && d.IsDir() {
+			// successfully skipped directory
+			err = nil
+		}
+		return false, err
+	}
+	if !d.IsDir() {
+		return false, nil
+	}
+
+	if dirKey != "" {
+		w.visited[dirKey] = path
+	}
+	return true, nil
+}
+
+// walk visits the entries of a directory which has been accepted by fn, then walks the subdirectories it accepted
+func (w *dirWalker) walk(path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	listing := w.listing(path)
+	if listing.err != nil {
+		// second call, to report ReadDir error
+		if err := fn(path, d, listing.err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	// visit the entries, queueing the listings of the subdirectories which are accepted to be read ahead
+	var subDirs []string
+	var subDirEntries []fs.DirEntry
+	// discard the listings of any subdirectories which are not reached if the walk is stopped
+	defer func() {
+		w.discard(subDirs...)
+	}()
+	for _, entry := range listing.entries {
+		entryPath := filepath.Join(path, entry.Name())
+		walk, err := w.visit(entryPath, entry, fn)
+		if err == filepath.SkipDir {
+			// skip the remaining entries of this directory
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if walk {
+			w.prefetch(entryPath)
+			subDirs = append(subDirs, entryPath)
+			subDirEntries = append(subDirEntries, entry)
+		}
+	}
+
+	for i, subDir := range subDirs {
+		if err := w.walk(subDir, subDirEntries[i], fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dirKey returns a key identifying the directory - this is the device/inode if available,
+// falling back to the path with all symlinks resolved
+func (w *dirWalker) dirKey(path string, d fs.DirEntry) string {
+	if info, err := d.Info(); err == nil {
+		if device, inode, ok := fileIdentity(info); ok {
+			return fmt.Sprintf("%d:%d", device, inode)
+		}
+	}
+	if realPath, err := filepath.EvalSymlinks(path); err == nil {
+		return realPath
+	}
+	return path
+}
+
+// readDir reads the directory, and stats the files it contains
+// (so the stat latency is incurred by the read ahead rather than by the walk function)
+// If we are following symlinks, the entries for symlinks are replaced with entries for their targets
+func (w *dirWalker) readDir(path string) dirListing {
+	entries, err := os.ReadDir(path)
+	for i, entry := range entries {
+		if w.followSymlinks && entry.Type()&fs.ModeSymlink != 0 {
+			entries[i] = resolveSymlink(filepath.Join(path, entry.Name()), entry)
+			continue
+		}
+		if w.followSymlinks || !entry.IsDir() {
+			info, infoErr := entry.Info()
+			entries[i] = &statDirEntry{DirEntry: entry, info: info, err: infoErr}
+		}
+	}
+	return dirListing{entries: entries, err: err}
+}
+
+// resolveSymlink returns an entry for the target of the symlink
+// if the target cannot be resolved, the entry records the error
+func resolveSymlink(path string, entry fs.DirEntry) fs.DirEntry {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = fmt.Errorf("%s: symlink target does not exist", path)
+		} else {
+			slog.Error("dirWalker error resolving symlink", "path", path, "error", err)
+			err = fmt.Errorf("%s: unable to resolve symlink", path)
+		}
+		return &statDirEntry{DirEntry: entry, linkErr: err}
+	}
+	// os.Stat returns the name of the link, not of the target, so the entry keeps the link name
+	return &statDirEntry{DirEntry: fs.FileInfoToDirEntry(info), info: info}
+}
+
+// statDirEntry is a fs.DirEntry with the result of Info() cached
+type statDirEntry struct {
+	fs.DirEntry
+	info fs.FileInfo
+	err  error
+	// the error resolving the target, if this is an entry for a symlink which could not be resolved
+	linkErr error
+}
+
+func (s *statDirEntry) Info() (fs.FileInfo, error) {
+	return s.info, s.err
+}
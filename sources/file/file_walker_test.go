@@ -0,0 +1,168 @@
+package file
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDirWalker_Walk(t *testing.T) {
+	root, err := filepath.Abs("./test_data/discover_test_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := filepath.Join(root, "AWSLogs", "org2")
+
+	// skip a directory part way through the walk, to verify SkipDir behaves the same as for filepath.WalkDir
+	walkFn := func(walker *dirWalker, visited *[]string) fs.WalkDirFunc {
+		return func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			*visited = append(*visited, path)
+			// the skipped directory must never be read
+			for pending := range walker.pending {
+				if pending == skipped || strings.HasPrefix(pending, skipped+string(filepath.Separator)) {
+					t.Errorf("skipped directory %s was queued to be read", pending)
+				}
+			}
+			if path == skipped {
+				return fs.SkipDir
+			}
+			return nil
+		}
+	}
+
+	var expected []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		expected = append(expected, path)
+		if path == skipped {
+			return fs.SkipDir
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(expected)
+
+	var firstOrder []string
+	for _, concurrency := range []int{1, 2, 8} {
+		var visited []string
+		walker := newDirWalker(concurrency, false, nil)
+		walker.prefetch(root)
+		if err := walker.walkDir(root, walkFn(walker, &visited)); err != nil {
+			t.Fatal(err)
+		}
+		walker.close()
+
+		// all entries of a directory are visited before the contents of its subdirectories
+		for i, path := range visited {
+			if dir := filepath.Dir(path); path != root && slices.Index(visited, dir) > i {
+				t.Errorf("concurrency %d: %s visited before its directory", concurrency, path)
+			}
+		}
+		// the order is deterministic
+		if firstOrder == nil {
+			firstOrder = visited
+		} else if !slices.Equal(visited, firstOrder) {
+			t.Errorf("concurrency %d: expected paths in order %v, got %v", concurrency, firstOrder, visited)
+		}
+		// the same paths are visited as by filepath.WalkDir
+		sorted := slices.Sorted(slices.Values(visited))
+		if !slices.Equal(sorted, expected) {
+			t.Errorf("concurrency %d: expected paths %v, got %v", concurrency, expected, sorted)
+		}
+		if len(walker.pending) != 0 {
+			t.Errorf("concurrency %d: expected no pending listings, got %d", concurrency, len(walker.pending))
+		}
+	}
+}
+
+func TestDirWalker_Symlinks(t *testing.T) {
+	// logs/current -> data/2026-10-18, which contains a symlink back to logs
+	dir := t.TempDir()
+	logsDir := filepath.Join(dir, "logs")
+	dataDir := filepath.Join(dir, "data", "2026-10-18")
+	for _, d := range []string{logsDir, dataDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeLines(t, filepath.Join(dataDir, "a.log"), "a")
+	writeLines(t, filepath.Join(dir, "data", "b.log"), "b")
+	links := map[string]string{
+		filepath.Join(logsDir, "current"):    dataDir,
+		filepath.Join(logsDir, "dangling"):   filepath.Join(dir, "data", "missing"),
+		filepath.Join(logsDir, "linked.log"): filepath.Join(dir, "data", "b.log"),
+		filepath.Join(dataDir, "loop"):       logsDir,
+		filepath.Join(dir, "root_link"):      logsDir,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		root           string
+		followSymlinks bool
+		expectedPaths  []string
+		expectedErrors []string
+	}{
+		{
+			name:           "follow symlinks",
+			root:           "logs",
+			followSymlinks: true,
+			expectedPaths:  []string{"logs", "logs/current", "logs/linked.log", "logs/current/a.log"},
+			expectedErrors: []string{"logs/dangling: symlink target does not exist", "logs/current/loop: symlink cycle detected"},
+		},
+		{
+			name:          "do not follow symlinks",
+			root:          "logs",
+			expectedPaths: []string{"logs", "logs/current", "logs/dangling", "logs/linked.log"},
+		},
+		{
+			name:          "symlinked root is walked when not following symlinks",
+			root:          "root_link",
+			expectedPaths: []string{"root_link", "root_link/current", "root_link/dangling", "root_link/linked.log"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var visited []string
+			var errs []string
+			walker := newDirWalker(2, tt.followSymlinks, func(err error) {
+				errs = append(errs, err.Error())
+			})
+			defer walker.close()
+			err := walker.walkDir(filepath.Join(dir, tt.root), func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, _ := filepath.Rel(dir, path)
+				visited = append(visited, filepath.ToSlash(rel))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(visited, tt.expectedPaths) {
+				t.Errorf("expected paths %v, got %v", tt.expectedPaths, visited)
+			}
+			if len(errs) != len(tt.expectedErrors) {
+				t.Fatalf("expected errors %v, got %v", tt.expectedErrors, errs)
+			}
+			for i, expected := range tt.expectedErrors {
+				if !strings.Contains(filepath.ToSlash(errs[i]), expected) {
+					t.Errorf("expected error %d to contain %q, got %q", i, expected, errs[i])
+				}
+			}
+		})
+	}
+}
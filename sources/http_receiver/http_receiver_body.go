@@ -0,0 +1,120 @@
+package http_receiver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxBodySize is the maximum size of a request body, before and after decompression
+const maxBodySize = 32 * 1024 * 1024
+
+var errBodyTooLarge = fmt.Errorf("request body exceeds %d bytes", maxBodySize)
+
+// readLines reads the lines from the request body, decompressing it if needed
+// The whole body is read before returning, so a malformed request does not write any lines
+func readLines(r *http.Request, w http.ResponseWriter) ([][]byte, error) {
+	body, err := requestBody(r, w)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// limit the decompressed size - read one byte more than the limit so we can tell if it is exceeded
+	limited := &io.LimitedReader{R: body, N: maxBodySize + 1}
+
+	var lines [][]byte
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		lines, err = readJsonValues(limited)
+	} else {
+		lines, err = readTextLines(limited)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limited.N <= 0 {
+		return nil, errBodyTooLarge
+	}
+	return lines, nil
+}
+
+// requestBody returns a reader for the request body, decompressing it if it is gzip compressed
+// (either as indicated by the headers, or detected from the gzip magic number)
+func requestBody(r *http.Request, w http.ResponseWriter) (io.ReadCloser, error) {
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxBodySize))
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isGzip := strings.Contains(r.Header.Get("Content-Encoding"), "gzip") ||
+		mediaType == "application/gzip" || mediaType == "application/x-gzip"
+	if !isGzip {
+		magic, _ := body.Peek(2)
+		isGzip = bytes.Equal(magic, []byte{0x1f, 0x8b})
+	}
+	if !isGzip {
+		return io.NopCloser(body), nil
+	}
+
+	gzReader, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip body: %w", err)
+	}
+	return gzReader, nil
+}
+
+// readTextLines reads the non-empty lines from the body - this is used for both plain text and newline delimited JSON
+func readTextLines(r io.Reader) ([][]byte, error) {
+	var lines [][]byte
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimRight(line, "\r\n"); len(line) > 0 {
+			lines = append(lines, line)
+		}
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readJsonValues reads a stream of JSON values from the body, returning each value as a single line
+// if a value is an array, each element of the array is returned as a line
+func readJsonValues(r io.Reader) ([][]byte, error) {
+	var lines [][]byte
+	dec := json.NewDecoder(r)
+	for {
+		var value json.RawMessage
+		err := dec.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %w", err)
+		}
+
+		values := []json.RawMessage{value}
+		if bytes.HasPrefix(bytes.TrimSpace(value), []byte("[")) {
+			values = nil
+			if err := json.Unmarshal(value, &values); err != nil {
+				return nil, fmt.Errorf("invalid JSON body: %w", err)
+			}
+		}
+		for _, v := range values {
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, v); err != nil {
+				return nil, fmt.Errorf("invalid JSON body: %w", err)
+			}
+			lines = append(lines, buf.Bytes())
+		}
+	}
+}
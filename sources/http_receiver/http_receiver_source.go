@@ -0,0 +1,216 @@
+package http_receiver
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-core/sources/spool"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+const (
+	// linesPerArtifact is the number of lines written to each artifact
+	// - artifacts are collected while we continue to listen
+	linesPerArtifact = 10000
+	// shutdownTimeout is how long to wait for in-flight requests to complete when we stop listening
+	shutdownTimeout = 10 * time.Second
+)
+
+// HttpReceiverSource is an artifact source which listens for HTTP POST requests containing log lines
+// It listens for the configured duration, or until the line or byte budget is met, writing the lines from each
+// request to an artifact in the temp dir. The request body may be:
+// - newline delimited JSON or plain text lines - each non-empty line is written as is
+// - JSON (content type application/json) - each JSON value (or element of a JSON array) is written as a line
+// The body may be gzip compressed.
+type HttpReceiverSource struct {
+	artifact_source.ArtifactSourceImpl[*HttpReceiverSourceConfig, *artifact_source.EmptyConnection]
+
+	// called once we are listening - used for testing
+	onListening func(addr net.Addr)
+
+	// the writer and the budget counts are updated by the request handlers so are protected by a mutex
+	mut       sync.Mutex
+	writer    *spool.Writer
+	lineCount int
+	byteCount int64
+	// closed once the budget has been met
+	budgetMet     chan struct{}
+	budgetMetOnce sync.Once
+}
+
+// Init sets the collection state then calls the base Init
+func (s *HttpReceiverSource) Init(ctx context.Context, params *row_source.RowSourceParams, opts ...row_source.RowSourceOption) error {
+	// spooled data is always collected, so there is no collection state to keep
+	s.NewCollectionStateFunc = spool.NewCollectionState
+
+	return s.ArtifactSourceImpl.Init(ctx, params, opts...)
+}
+
+func (s *HttpReceiverSource) Identifier() string {
+	return HttpReceiverSourceIdentifier
+}
+
+// DiscoverArtifacts listens for requests, writing the lines received to artifacts in the temp dir,
+// and notifies observers of each artifact as it is completed
+func (s *HttpReceiverSource) DiscoverArtifacts(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.Config.Address)
+	if err != nil {
+		slog.Error("HttpReceiverSource.DiscoverArtifacts error starting listener", "error", err)
+		return fmt.Errorf("error listening on %s: %w", s.Config.Address, err)
+	}
+
+	sourceLocation := fmt.Sprintf("http://%s%s", listener.Addr(), s.Config.GetPath())
+	s.budgetMet = make(chan struct{})
+	s.writer = spool.NewWriter(s.TempDir, "http_receiver.log", linesPerArtifact, func(path string) error {
+		return s.discoverArtifact(ctx, path, sourceLocation)
+	})
+	defer s.writer.Close()
+
+	server := &http.Server{
+		Handler:           http.HandlerFunc(s.handleRequest),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	slog.Info("HttpReceiverSource listening", "address", sourceLocation)
+
+	if s.onListening != nil {
+		s.onListening(listener.Addr())
+	}
+
+	timer := time.NewTimer(s.Config.GetDuration())
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	case <-s.budgetMet:
+	case err := <-serveErr:
+		slog.Error("HttpReceiverSource.DiscoverArtifacts error serving requests", "error", err)
+		return fmt.Errorf("error serving requests: %w", err)
+	}
+
+	// stop listening and wait for any in-flight requests to complete
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HttpReceiverSource.DiscoverArtifacts error shutting down server", "error", err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	slog.Info("HttpReceiverSource.DiscoverArtifacts stopped listening", "lines", s.lineCount, "bytes", s.byteCount)
+	return s.writer.Flush()
+}
+
+// DownloadArtifact does nothing as the artifact has already been spooled to the temp dir
+func (s *HttpReceiverSource) DownloadArtifact(ctx context.Context, info *types.ArtifactInfo) error {
+	downloadInfo, err := spool.NewDownloadedArtifactInfo(info)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDownloaded(ctx, downloadInfo)
+}
+
+func (s *HttpReceiverSource) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != s.Config.GetPath() {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	lines, err := readLines(r, w)
+	if err != nil {
+		slog.Warn("HttpReceiverSource error reading request", "remote", r.RemoteAddr, "error", err)
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) || errors.Is(err, errBodyTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := s.writeLines(lines); err != nil {
+		if errors.Is(err, errBudgetMet) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		slog.Error("HttpReceiverSource error writing lines", "error", err)
+		http.Error(w, "error writing lines", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorized returns whether the request has the bearer token, if one is configured
+func (s *HttpReceiverSource) authorized(r *http.Request) bool {
+	if s.Config.Token == nil {
+		return true
+	}
+	expected := "Bearer " + *s.Config.Token
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) == 1
+}
+
+var errBudgetMet = errors.New("no longer accepting requests")
+
+// writeLines writes the lines from a request to the spool file, and signals if the budget has been met
+// all lines from a request are written, so the budget may be exceeded by the final request
+func (s *HttpReceiverSource) writeLines(lines [][]byte) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.isBudgetMet() {
+		return errBudgetMet
+	}
+
+	for _, line := range lines {
+		if err := s.writer.WriteLine(line); err != nil {
+			return err
+		}
+		s.lineCount++
+		s.byteCount += int64(len(line))
+	}
+
+	if s.isBudgetMet() {
+		s.budgetMetOnce.Do(func() { close(s.budgetMet) })
+	}
+	return nil
+}
+
+func (s *HttpReceiverSource) isBudgetMet() bool {
+	return (s.Config.MaxLines != nil && s.lineCount >= *s.Config.MaxLines) ||
+		(s.Config.MaxBytes != nil && s.byteCount >= *s.Config.MaxBytes)
+}
+
+// discoverArtifact notifies observers of a completed spool file
+func (s *HttpReceiverSource) discoverArtifact(ctx context.Context, path, sourceLocation string) error {
+	info, err := spool.NewArtifactInfo(path, HttpReceiverSourceIdentifier, sourceLocation, nil)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDiscovered(ctx, info)
+}
@@ -0,0 +1,94 @@
+package http_receiver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source_config"
+)
+
+const (
+	HttpReceiverSourceIdentifier = "http_receiver"
+
+	defaultPath     = "/"
+	defaultDuration = time.Minute
+)
+
+type HttpReceiverSourceConfig struct {
+	artifact_source_config.ArtifactSourceConfigImpl
+	// required to allow partial decoding
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// the address to listen on, e.g. ":8080" or "127.0.0.1:8080"
+	Address string `hcl:"address"`
+	// the path to accept requests on (default "/")
+	Path *string `hcl:"path,optional"`
+	// if set, requests must have an "Authorization: Bearer <token>" header
+	Token *string `hcl:"token,optional"`
+
+	// how long to listen for, e.g. "30s" or "5m" (default 1m)
+	Duration *string `hcl:"duration,optional"`
+	// if set, stop listening once this many lines have been received
+	MaxLines *int `hcl:"max_lines,optional"`
+	// if set, stop listening once this many bytes of lines have been received
+	MaxBytes *int64 `hcl:"max_bytes,optional"`
+}
+
+func (c *HttpReceiverSourceConfig) Validate() error {
+	// validate the base fields
+	if err := c.ArtifactSourceConfigImpl.Validate(); err != nil {
+		return err
+	}
+
+	if c.Address == "" {
+		return fmt.Errorf("required field: address can not be empty")
+	}
+	if c.Path != nil && !strings.HasPrefix(*c.Path, "/") {
+		return fmt.Errorf("path %s must start with '/'", *c.Path)
+	}
+	if c.Token != nil && *c.Token == "" {
+		return fmt.Errorf("token can not be empty")
+	}
+
+	if c.Duration != nil {
+		d, err := time.ParseDuration(*c.Duration)
+		if err != nil {
+			return fmt.Errorf("invalid duration %s: %w", *c.Duration, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("duration must be greater than zero")
+		}
+	}
+	if c.MaxLines != nil && *c.MaxLines < 1 {
+		return fmt.Errorf("max_lines must be at least 1")
+	}
+	if c.MaxBytes != nil && *c.MaxBytes < 1 {
+		return fmt.Errorf("max_bytes must be at least 1")
+	}
+
+	return nil
+}
+
+func (c *HttpReceiverSourceConfig) Identifier() string {
+	return HttpReceiverSourceIdentifier
+}
+
+// GetPath returns the path to accept requests on
+func (c *HttpReceiverSourceConfig) GetPath() string {
+	if c.Path == nil {
+		return defaultPath
+	}
+	return *c.Path
+}
+
+// GetDuration returns how long to listen for
+func (c *HttpReceiverSourceConfig) GetDuration() time.Duration {
+	if c.Duration == nil {
+		return defaultDuration
+	}
+	// we have already validated the duration
+	d, _ := time.ParseDuration(*c.Duration)
+	return d
+}
@@ -0,0 +1,178 @@
+package http_receiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/events"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+type rowObserver struct {
+	Rows []string
+	mut  sync.Mutex
+}
+
+func (r *rowObserver) Notify(_ context.Context, e events.Event) error {
+	if row, ok := e.(*events.RowExtracted); ok {
+		r.mut.Lock()
+		r.Rows = append(r.Rows, row.Row.(string))
+		r.mut.Unlock()
+	}
+	return nil
+}
+
+type testRequest struct {
+	method         string
+	path           string
+	token          string
+	contentType    string
+	gzip           bool
+	body           string
+	expectedStatus int
+}
+
+func TestHttpReceiverSource_Collect(t *testing.T) {
+	requests := []testRequest{
+		{
+			method:         http.MethodGet,
+			path:           "/ingest",
+			token:          "secret",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			method:         http.MethodPost,
+			path:           "/other",
+			token:          "secret",
+			body:           "ignored\n",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			method:         http.MethodPost,
+			path:           "/ingest",
+			token:          "wrong",
+			body:           "ignored\n",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			method:         http.MethodPost,
+			path:           "/ingest",
+			token:          "secret",
+			contentType:    "application/json",
+			body:           `{"invalid": `,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			method:         http.MethodPost,
+			path:           "/ingest",
+			token:          "secret",
+			contentType:    "application/x-ndjson",
+			body:           "{\"a\": 1}\n\n{\"a\": 2}\r\n",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			method:         http.MethodPost,
+			path:           "/ingest",
+			token:          "secret",
+			contentType:    "application/json; charset=utf-8",
+			body:           "[\n  {\"b\": 1},\n  {\"b\": [2, 3]}\n]\n{\n  \"c\": 1\n}",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			method:         http.MethodPost,
+			path:           "/ingest",
+			token:          "secret",
+			contentType:    "text/plain",
+			gzip:           true,
+			body:           "plain line 1\nplain line 2",
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+	expectedRows := []string{
+		`plain line 1`,
+		`plain line 2`,
+		`{"a": 1}`,
+		`{"a": 2}`,
+		`{"b":1}`,
+		`{"b":[2,3]}`,
+		`{"c":1}`,
+	}
+
+	ctx := context_values.WithExecutionId(context.Background(), "test")
+
+	s := &HttpReceiverSource{}
+	s.onListening = func(addr net.Addr) {
+		// send the requests once the source is listening
+		go sendRequests(t, addr.String(), requests)
+	}
+	any(s).(row_source.BaseSource).RegisterSource(s)
+
+	// listen until we have received all lines
+	hclBytes := []byte(fmt.Sprintf("address = \"127.0.0.1:0\"\npath = \"/ingest\"\ntoken = \"secret\"\nduration = \"10s\"\nmax_lines = %d", len(expectedRows)))
+	err := s.Init(ctx, &row_source.RowSourceParams{
+		SourceConfigData:  types.NewSourceConfigData(hclBytes, hcl.Range{}, HttpReceiverSourceIdentifier),
+		CollectionTempDir: t.TempDir(),
+	}, artifact_source.WithRowPerLine())
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+
+	var observer rowObserver
+	_ = s.AddObserver(&observer)
+
+	if err := s.Collect(ctx); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	slices.Sort(observer.Rows)
+	if !slices.Equal(observer.Rows, expectedRows) {
+		t.Errorf("expected rows %q, got %q", expectedRows, observer.Rows)
+	}
+}
+
+// sendRequests sends the requests in order, checking the response status of each
+func sendRequests(t *testing.T, addr string, requests []testRequest) {
+	for _, r := range requests {
+		body := []byte(r.body)
+		if r.gzip {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			_, _ = gz.Write(body)
+			_ = gz.Close()
+			body = buf.Bytes()
+		}
+
+		req, err := http.NewRequest(r.method, "http://"+addr+r.path, bytes.NewReader(body))
+		if err != nil {
+			t.Errorf("failed to create request: %v", err)
+			return
+		}
+		req.Header.Set("Authorization", "Bearer "+r.token)
+		if r.contentType != "" {
+			req.Header.Set("Content-Type", r.contentType)
+		}
+		if r.gzip {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("failed to send request: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != r.expectedStatus {
+			t.Errorf("%s %s: expected status %d, got %d", r.method, r.path, r.expectedStatus, resp.StatusCode)
+		}
+	}
+}
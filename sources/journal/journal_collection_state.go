@@ -0,0 +1,28 @@
+package journal
+
+import (
+	"github.com/turbot/tailpipe-plugin-core/sources/spool"
+	"github.com/turbot/tailpipe-plugin-sdk/collection_state"
+)
+
+// JournalCollectionState is the collection state used by the JournalSource
+// It tracks a cursor for each journal file (i.e. the sequence number of the last entry collected from the file),
+// so subsequent collections only read new entries
+type JournalCollectionState = spool.CursorCollectionState[JournalCursor]
+
+// JournalCursor is the position of the last entry collected from a journal file
+type JournalCursor struct {
+	// the sequence number of the entry
+	Seqnum uint64 `json:"seqnum"`
+	// the realtime timestamp of the entry, in microseconds since the epoch
+	Realtime uint64 `json:"realtime"`
+}
+
+// after returns whether the cursor is after the other cursor
+func (c *JournalCursor) after(other *JournalCursor) bool {
+	return c.Seqnum > other.Seqnum
+}
+
+func NewJournalCollectionState() collection_state.CollectionState {
+	return spool.NewCursorCollectionState((*JournalCursor).after)
+}
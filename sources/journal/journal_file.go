@@ -0,0 +1,388 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// the systemd journal file format is documented at https://systemd.io/JOURNAL_FILE_FORMAT/
+// all integers are little endian and all objects are 8 byte aligned
+
+var journalSignature = []byte("LPKSHHRH")
+
+// header incompatible flags
+const (
+	headerIncompatibleCompressedXz   = 1 << 0
+	headerIncompatibleCompressedLz4  = 1 << 1
+	headerIncompatibleKeyedHash      = 1 << 2
+	headerIncompatibleCompressedZstd = 1 << 3
+	headerIncompatibleCompact        = 1 << 4
+
+	headerIncompatibleSupported = headerIncompatibleCompressedXz | headerIncompatibleCompressedLz4 |
+		headerIncompatibleKeyedHash | headerIncompatibleCompressedZstd | headerIncompatibleCompact
+)
+
+// the file state when the file is open for writing
+const fileStateOnline = 1
+
+// object types
+const (
+	objectData       = 1
+	objectEntry      = 3
+	objectEntryArray = 6
+)
+
+// object compression flags
+const (
+	objectCompressedXz   = 1 << 0
+	objectCompressedLz4  = 1 << 1
+	objectCompressedZstd = 1 << 2
+
+	objectCompressionMask = objectCompressedXz | objectCompressedLz4 | objectCompressedZstd
+)
+
+const (
+	// the minimum header size we need to read - older files have a smaller header than current files
+	minHeaderSize    = 208
+	objectHeaderSize = 16
+	// the offsets of the object contents
+	dataPayloadOffset        = 64
+	dataPayloadOffsetCompact = 72
+	entryItemsOffset         = 64
+	entryArrayItemsOffset    = 24
+	// the maximum object size we will read - this protects against corrupt sizes
+	maxObjectSize = 64 * 1024 * 1024
+	// the maximum number of decoded data objects to cache
+	maxDataCacheSize = 4096
+)
+
+// journalFile reads the entries from a systemd journal file
+type journalFile struct {
+	path   string
+	f      *os.File
+	size   uint64
+	header journalHeader
+
+	// data objects are shared between entries, so cache the decoded fields by offset
+	dataCache map[uint64]journalField
+}
+
+type journalHeader struct {
+	incompatibleFlags uint32
+	state             uint8
+	fileId            [16]byte
+	machineId         [16]byte
+	seqnumId          [16]byte
+	headerSize        uint64
+	entryArrayOffset  uint64
+	nEntries          uint64
+	headEntrySeqnum   uint64
+	tailEntrySeqnum   uint64
+}
+
+// journalEntry is a single entry read from a journal file
+type journalEntry struct {
+	seqnum    uint64
+	realtime  uint64
+	monotonic uint64
+	bootId    [16]byte
+	xorHash   uint64
+	fields    []journalField
+}
+
+type journalField struct {
+	name  string
+	value []byte
+}
+
+func openJournalFile(path string) (*journalFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	j := &journalFile{
+		path:      path,
+		f:         f,
+		dataCache: make(map[uint64]journalField),
+	}
+	if err := j.readHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *journalFile) Close() error {
+	return j.f.Close()
+}
+
+func (j *journalFile) readHeader() error {
+	info, err := j.f.Stat()
+	if err != nil {
+		return err
+	}
+	j.size = uint64(info.Size())
+
+	buf := make([]byte, minHeaderSize)
+	if _, err := j.f.ReadAt(buf, 0); err != nil {
+		return fmt.Errorf("%s: not a journal file", filepath.Base(j.path))
+	}
+	if !bytes.Equal(buf[:8], journalSignature) {
+		return fmt.Errorf("%s: not a journal file", filepath.Base(j.path))
+	}
+
+	le := binary.LittleEndian
+	h := journalHeader{
+		incompatibleFlags: le.Uint32(buf[12:]),
+		state:             buf[16],
+		headerSize:        le.Uint64(buf[88:]),
+		entryArrayOffset:  le.Uint64(buf[176:]),
+		nEntries:          le.Uint64(buf[152:]),
+		tailEntrySeqnum:   le.Uint64(buf[160:]),
+		headEntrySeqnum:   le.Uint64(buf[168:]),
+	}
+	copy(h.fileId[:], buf[24:40])
+	copy(h.machineId[:], buf[40:56])
+	copy(h.seqnumId[:], buf[72:88])
+
+	if unsupported := h.incompatibleFlags &^ headerIncompatibleSupported; unsupported != 0 {
+		return fmt.Errorf("%s: unsupported journal file features (incompatible flags %#x)", filepath.Base(j.path), unsupported)
+	}
+	if h.headerSize < minHeaderSize || h.headerSize > j.size {
+		return fmt.Errorf("%s: invalid journal header size %d", filepath.Base(j.path), h.headerSize)
+	}
+	j.header = h
+	return nil
+}
+
+// seqnumId returns the sequence number id of the file, as a hex string
+// the sequence numbers of all files with the same sequence number id are comparable
+func (j *journalFile) seqnumId() string {
+	return hex.EncodeToString(j.header.seqnumId[:])
+}
+
+// cursorKey returns the key of the collection state cursor for the file
+// Files with the same sequence number id (e.g. system.journal and user-1000.journal) are written concurrently, so
+// each file needs its own cursor - the file is identified by the machine id and file id, as the file id is unchanged
+// when journald archives (renames) the active file
+func (j *journalFile) cursorKey() string {
+	return fmt.Sprintf("%s/%x/%x", j.seqnumId(), j.header.machineId[:], j.header.fileId[:])
+}
+
+func (j *journalFile) compact() bool {
+	return j.header.incompatibleFlags&headerIncompatibleCompact != 0
+}
+
+// online returns whether the file is currently open for writing
+// (the tail of an online file may be incomplete)
+func (j *journalFile) online() bool {
+	return j.header.state == fileStateOnline
+}
+
+// entries calls fn for each entry in the file, in order
+// the entries are found by following the chain of entry array objects from the header
+func (j *journalFile) entries(fn func(entry *journalEntry) error) error {
+	le := binary.LittleEndian
+	itemSize := uint64(8)
+	if j.compact() {
+		itemSize = 4
+	}
+
+	remaining := j.header.nEntries
+	offset := j.header.entryArrayOffset
+	for offset != 0 && remaining > 0 {
+		obj, err := j.readObject(offset, objectEntryArray)
+		if err != nil {
+			return err
+		}
+		if len(obj) < entryArrayItemsOffset {
+			return fmt.Errorf("%s: invalid entry array object at offset %d", filepath.Base(j.path), offset)
+		}
+		next := le.Uint64(obj[16:])
+
+		items := obj[entryArrayItemsOffset:]
+		for i := uint64(0); i+itemSize <= uint64(len(items)) && remaining > 0; i += itemSize {
+			var entryOffset uint64
+			if j.compact() {
+				entryOffset = uint64(le.Uint32(items[i:]))
+			} else {
+				entryOffset = le.Uint64(items[i:])
+			}
+			// a zero offset indicates the unused tail of the array
+			if entryOffset == 0 {
+				break
+			}
+			entry, err := j.readEntry(entryOffset)
+			if err != nil {
+				return err
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+			remaining--
+		}
+
+		// objects are only ever appended, so the next array must come after this one
+		if next != 0 && next <= offset {
+			return fmt.Errorf("%s: invalid entry array offset %d", filepath.Base(j.path), next)
+		}
+		offset = next
+	}
+	return nil
+}
+
+func (j *journalFile) readEntry(offset uint64) (*journalEntry, error) {
+	obj, err := j.readObject(offset, objectEntry)
+	if err != nil {
+		return nil, err
+	}
+	if len(obj) < entryItemsOffset {
+		return nil, fmt.Errorf("%s: invalid entry object at offset %d", filepath.Base(j.path), offset)
+	}
+
+	le := binary.LittleEndian
+	entry := &journalEntry{
+		seqnum:    le.Uint64(obj[16:]),
+		realtime:  le.Uint64(obj[24:]),
+		monotonic: le.Uint64(obj[32:]),
+		xorHash:   le.Uint64(obj[56:]),
+	}
+	copy(entry.bootId[:], obj[40:56])
+
+	// each item references a data object - compact files only store the offset, regular files also store the hash
+	itemSize := 16
+	if j.compact() {
+		itemSize = 4
+	}
+	items := obj[entryItemsOffset:]
+	for i := 0; i+itemSize <= len(items); i += itemSize {
+		var dataOffset uint64
+		if j.compact() {
+			dataOffset = uint64(le.Uint32(items[i:]))
+		} else {
+			dataOffset = le.Uint64(items[i:])
+		}
+		field, err := j.readData(dataOffset)
+		if err != nil {
+			return nil, err
+		}
+		// skip any data which is not of the form FIELD=value
+		if field.name != "" {
+			entry.fields = append(entry.fields, field)
+		}
+	}
+	return entry, nil
+}
+
+// readData reads the field stored in the data object at the given offset
+func (j *journalFile) readData(offset uint64) (journalField, error) {
+	if field, ok := j.dataCache[offset]; ok {
+		return field, nil
+	}
+
+	obj, err := j.readObject(offset, objectData)
+	if err != nil {
+		return journalField{}, err
+	}
+	payloadOffset := dataPayloadOffset
+	if j.compact() {
+		payloadOffset = dataPayloadOffsetCompact
+	}
+	if len(obj) < payloadOffset {
+		return journalField{}, fmt.Errorf("%s: invalid data object at offset %d", filepath.Base(j.path), offset)
+	}
+
+	payload, err := decompressPayload(obj[1]&objectCompressionMask, obj[payloadOffset:])
+	if err != nil {
+		return journalField{}, fmt.Errorf("%s: unable to decompress data object at offset %d: %w", filepath.Base(j.path), offset, err)
+	}
+
+	var field journalField
+	if name, value, ok := bytes.Cut(payload, []byte("=")); ok {
+		field = journalField{name: string(name), value: value}
+	}
+
+	if len(j.dataCache) >= maxDataCacheSize {
+		clear(j.dataCache)
+	}
+	j.dataCache[offset] = field
+	return field, nil
+}
+
+// readObject reads the object at the given offset, checking it has the expected type
+func (j *journalFile) readObject(offset uint64, objectType uint8) ([]byte, error) {
+	name := filepath.Base(j.path)
+	if offset%8 != 0 || offset < j.header.headerSize || offset+objectHeaderSize > j.size {
+		return nil, fmt.Errorf("%s: invalid object offset %d", name, offset)
+	}
+
+	header := make([]byte, objectHeaderSize)
+	if _, err := j.f.ReadAt(header, int64(offset)); err != nil {
+		return nil, fmt.Errorf("%s: unable to read object at offset %d: %w", name, offset, err)
+	}
+	if header[0] != objectType {
+		return nil, fmt.Errorf("%s: unexpected object type %d at offset %d", name, header[0], offset)
+	}
+	size := binary.LittleEndian.Uint64(header[8:])
+	if size < objectHeaderSize || size > maxObjectSize || offset+size > j.size {
+		return nil, fmt.Errorf("%s: invalid object size %d at offset %d", name, size, offset)
+	}
+
+	obj := make([]byte, size)
+	if _, err := j.f.ReadAt(obj, int64(offset)); err != nil {
+		return nil, fmt.Errorf("%s: unable to read object at offset %d: %w", name, offset, err)
+	}
+	return obj, nil
+}
+
+// the zstd decoder is safe for concurrent use with DecodeAll, so we share a single decoder
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+})
+
+func decompressPayload(compression uint8, payload []byte) ([]byte, error) {
+	switch compression {
+	case 0:
+		return payload, nil
+	case objectCompressedXz:
+		r, err := xz.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(io.LimitReader(r, maxObjectSize))
+	case objectCompressedLz4:
+		// lz4 payloads are a raw block, prefixed with the uncompressed size
+		if len(payload) < 8 {
+			return nil, errors.New("invalid lz4 payload")
+		}
+		size := binary.LittleEndian.Uint64(payload)
+		if size > maxObjectSize {
+			return nil, fmt.Errorf("invalid lz4 payload size %d", size)
+		}
+		res := make([]byte, size)
+		n, err := lz4.UncompressBlock(payload[8:], res)
+		if err != nil {
+			return nil, err
+		}
+		return res[:n], nil
+	case objectCompressedZstd:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return decoder.DecodeAll(payload, nil)
+	default:
+		return nil, fmt.Errorf("unsupported compression flags %#x", compression)
+	}
+}
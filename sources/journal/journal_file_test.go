@@ -0,0 +1,357 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	testSeqnumId  = [16]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	testMachineId = [16]byte{0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a, 0x3a}
+	testBootId    = [16]byte{0xb0, 0x07, 0xb0, 0x07, 0xb0, 0x07, 0xb0, 0x07, 0xb0, 0x07, 0xb0, 0x07, 0xb0, 0x07, 0xb0, 0x07}
+	longMessage   = "MESSAGE=" + strings.Repeat("a long message which is compressed ", 10)
+)
+
+type testEntry struct {
+	seqnum   uint64
+	realtime time.Time
+	fields   []string
+}
+
+type testJournalOptions struct {
+	compact bool
+	// the object compression flag to use for data objects (only payloads of at least 64 bytes are compressed)
+	compression uint8
+	online      bool
+	// the file id of the journal file
+	fileId [16]byte
+}
+
+func TestJournalFile_Entries(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entries := []testEntry{
+		{seqnum: 1, realtime: ts, fields: []string{"MESSAGE=first", "_PID=1", "_SYSTEMD_UNIT=init.scope"}},
+		{seqnum: 2, realtime: ts.Add(time.Second), fields: []string{longMessage, "_PID=1", "BINARY=\xff\x00"}},
+		{seqnum: 3, realtime: ts.Add(2 * time.Second), fields: []string{"MESSAGE=third", "TAG=a", "TAG=b", "INVALID"}},
+	}
+	expectedFields := [][]string{
+		{"MESSAGE=first", "_PID=1", "_SYSTEMD_UNIT=init.scope"},
+		{longMessage, "_PID=1", "BINARY=\xff\x00"},
+		{"MESSAGE=third", "TAG=a", "TAG=b"},
+	}
+
+	compressions := map[string]uint8{
+		"uncompressed": 0,
+		"xz":           objectCompressedXz,
+		"lz4":          objectCompressedLz4,
+		"zstd":         objectCompressedZstd,
+	}
+	for _, compact := range []bool{false, true} {
+		for name, compression := range compressions {
+			t.Run(fmt.Sprintf("%s compact=%v", name, compact), func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "system.journal")
+				writeTestJournal(t, path, testJournalOptions{compact: compact, compression: compression}, entries)
+
+				j, err := openJournalFile(path)
+				if err != nil {
+					t.Fatalf("openJournalFile() error = %v", err)
+				}
+				defer j.Close()
+
+				var i int
+				err = j.entries(func(entry *journalEntry) error {
+					if entry.seqnum != entries[i].seqnum {
+						t.Errorf("entry %d: expected seqnum %d, got %d", i, entries[i].seqnum, entry.seqnum)
+					}
+					if entry.realtime != uint64(entries[i].realtime.UnixMicro()) {
+						t.Errorf("entry %d: expected realtime %d, got %d", i, entries[i].realtime.UnixMicro(), entry.realtime)
+					}
+					var fields []string
+					for _, f := range entry.fields {
+						fields = append(fields, f.name+"="+string(f.value))
+					}
+					if !reflect.DeepEqual(fields, expectedFields[i]) {
+						t.Errorf("entry %d: expected fields %q, got %q", i, expectedFields[i], fields)
+					}
+					i++
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("entries() error = %v", err)
+				}
+				if i != len(entries) {
+					t.Errorf("expected %d entries, got %d", len(entries), i)
+				}
+			})
+		}
+	}
+}
+
+func TestJournalFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	notJournal := filepath.Join(dir, "not.journal")
+	if err := os.WriteFile(notJournal, []byte("this is not a journal file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openJournalFile(notJournal); err == nil || !strings.Contains(err.Error(), "not a journal file") {
+		t.Errorf("expected not a journal file error, got %v", err)
+	}
+
+	// truncate a valid journal file part way through the objects
+	truncated := filepath.Join(dir, "truncated.journal")
+	writeTestJournal(t, truncated, testJournalOptions{}, []testEntry{
+		{seqnum: 1, realtime: time.Now(), fields: []string{"MESSAGE=first"}},
+	})
+	data, err := os.ReadFile(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(truncated, data[:len(data)-8], 0644); err != nil {
+		t.Fatal(err)
+	}
+	j, err := openJournalFile(truncated)
+	if err != nil {
+		t.Fatalf("openJournalFile() error = %v", err)
+	}
+	defer j.Close()
+	if err := j.entries(func(*journalEntry) error { return nil }); err == nil {
+		t.Errorf("expected error reading truncated journal file")
+	}
+
+	// an entry array object which is too small to contain the next array offset
+	truncatedArray := filepath.Join(dir, "truncated_array.journal")
+	writeTestJournal(t, truncatedArray, testJournalOptions{}, []testEntry{
+		{seqnum: 1, realtime: time.Now(), fields: []string{"MESSAGE=first"}},
+	})
+	data, err = os.ReadFile(truncatedArray)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrayOffset := binary.LittleEndian.Uint64(data[176:])
+	binary.LittleEndian.PutUint64(data[arrayOffset+8:], objectHeaderSize+4)
+	if err := os.WriteFile(truncatedArray, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ja, err := openJournalFile(truncatedArray)
+	if err != nil {
+		t.Fatalf("openJournalFile() error = %v", err)
+	}
+	defer ja.Close()
+	if err := ja.entries(func(*journalEntry) error { return nil }); err == nil || !strings.Contains(err.Error(), "invalid entry array object") {
+		t.Errorf("expected invalid entry array object error, got %v", err)
+	}
+
+	// an entry array object whose size extends past the end of the file
+	binary.LittleEndian.PutUint64(data[arrayOffset+8:], uint64(len(data))-arrayOffset+8)
+	oversized := filepath.Join(dir, "oversized_array.journal")
+	if err := os.WriteFile(oversized, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	jo, err := openJournalFile(oversized)
+	if err != nil {
+		t.Fatalf("openJournalFile() error = %v", err)
+	}
+	defer jo.Close()
+	if err := jo.entries(func(*journalEntry) error { return nil }); err == nil || !strings.Contains(err.Error(), "invalid object size") {
+		t.Errorf("expected invalid object size error, got %v", err)
+	}
+}
+
+func TestEntryJson(t *testing.T) {
+	entry := &journalEntry{
+		seqnum:    10,
+		realtime:  1714564800000000,
+		monotonic: 5000,
+		bootId:    testBootId,
+		xorHash:   0xabc,
+		fields: []journalField{
+			{name: "MESSAGE", value: []byte("hello")},
+			{name: "TAG", value: []byte("a")},
+			{name: "TAG", value: []byte("b")},
+			{name: "BINARY", value: []byte{0xff, 0x00}},
+		},
+	}
+	seqnumId := "0102030405060708090a0b0c0d0e0f10"
+
+	line, err := entryJson(seqnumId, entry)
+	if err != nil {
+		t.Fatalf("entryJson() error = %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", line, err)
+	}
+
+	expected := map[string]any{
+		"__CURSOR":              "s=0102030405060708090a0b0c0d0e0f10;i=a;b=b007b007b007b007b007b007b007b007;m=1388;t=6176339d93000;x=abc",
+		"__REALTIME_TIMESTAMP":  "1714564800000000",
+		"__MONOTONIC_TIMESTAMP": "5000",
+		"__SEQNUM":              "10",
+		"__SEQNUM_ID":           seqnumId,
+		"_BOOT_ID":              "b007b007b007b007b007b007b007b007",
+		"MESSAGE":               "hello",
+		"TAG":                   []any{"a", "b"},
+		"BINARY":                []any{float64(255), float64(0)},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+// writeTestJournal writes a journal file containing the given entries
+// the entries are referenced from two chained entry arrays, the second of which has unused slots
+// NOTE: hashes are not written as they are not used by the reader
+func writeTestJournal(t *testing.T, path string, opts testJournalOptions, entries []testEntry) {
+	t.Helper()
+	const headerSize = 272
+	le := binary.LittleEndian
+	buf := make([]byte, headerSize)
+
+	appendObject := func(objectType, flags uint8, body []byte) uint64 {
+		offset := uint64(len(buf))
+		header := make([]byte, objectHeaderSize)
+		header[0] = objectType
+		header[1] = flags
+		le.PutUint64(header[8:], uint64(objectHeaderSize+len(body)))
+		buf = append(buf, header...)
+		buf = append(buf, body...)
+		for len(buf)%8 != 0 {
+			buf = append(buf, 0)
+		}
+		return offset
+	}
+	appendItem := func(items []byte, offset uint64, hashed bool) []byte {
+		if opts.compact {
+			return le.AppendUint32(items, uint32(offset))
+		}
+		items = le.AppendUint64(items, offset)
+		if hashed {
+			items = le.AppendUint64(items, 0)
+		}
+		return items
+	}
+
+	dataOffsets := make(map[string]uint64)
+	var entryOffsets []uint64
+	for _, e := range entries {
+		var items []byte
+		for _, field := range e.fields {
+			offset, ok := dataOffsets[field]
+			if !ok {
+				payload, flags := []byte(field), uint8(0)
+				if opts.compression != 0 && len(payload) >= 64 {
+					payload, flags = compressTestPayload(t, opts.compression, payload), opts.compression
+				}
+				prefixSize := dataPayloadOffset - objectHeaderSize
+				if opts.compact {
+					prefixSize = dataPayloadOffsetCompact - objectHeaderSize
+				}
+				offset = appendObject(objectData, flags, append(make([]byte, prefixSize), payload...))
+				dataOffsets[field] = offset
+			}
+			items = appendItem(items, offset, true)
+		}
+
+		body := make([]byte, entryItemsOffset-objectHeaderSize)
+		le.PutUint64(body[0:], e.seqnum)
+		le.PutUint64(body[8:], uint64(e.realtime.UnixMicro()))
+		le.PutUint64(body[16:], e.seqnum*1000)
+		copy(body[24:40], testBootId[:])
+		entryOffsets = append(entryOffsets, appendObject(objectEntry, 0, append(body, items...)))
+	}
+
+	appendArray := func(offsets []uint64, unused int) uint64 {
+		body := make([]byte, entryArrayItemsOffset-objectHeaderSize)
+		for _, offset := range offsets {
+			body = appendItem(body, offset, false)
+		}
+		for i := 0; i < unused; i++ {
+			body = appendItem(body, 0, false)
+		}
+		return appendObject(objectEntryArray, 0, body)
+	}
+	split := len(entryOffsets) / 2
+	first := appendArray(entryOffsets[:split], 0)
+	second := appendArray(entryOffsets[split:], 2)
+	le.PutUint64(buf[first+16:], second)
+
+	var flags uint32
+	if opts.compact {
+		flags |= headerIncompatibleCompact
+	}
+	switch opts.compression {
+	case objectCompressedXz:
+		flags |= headerIncompatibleCompressedXz
+	case objectCompressedLz4:
+		flags |= headerIncompatibleCompressedLz4
+	case objectCompressedZstd:
+		flags |= headerIncompatibleCompressedZstd
+	}
+	copy(buf, journalSignature)
+	le.PutUint32(buf[12:], flags)
+	if opts.online {
+		buf[16] = fileStateOnline
+	}
+	copy(buf[24:40], opts.fileId[:])
+	copy(buf[40:56], testMachineId[:])
+	copy(buf[72:88], testSeqnumId[:])
+	le.PutUint64(buf[88:], headerSize)
+	le.PutUint64(buf[96:], uint64(len(buf)-headerSize))
+	le.PutUint64(buf[152:], uint64(len(entries)))
+	if len(entries) > 0 {
+		le.PutUint64(buf[160:], entries[len(entries)-1].seqnum)
+		le.PutUint64(buf[168:], entries[0].seqnum)
+	}
+	le.PutUint64(buf[176:], first)
+
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func compressTestPayload(t *testing.T, compression uint8, payload []byte) []byte {
+	t.Helper()
+	switch compression {
+	case objectCompressedXz:
+		var out bytes.Buffer
+		w, err := xz.NewWriter(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(payload)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	case objectCompressedLz4:
+		out := make([]byte, 8+lz4.CompressBlockBound(len(payload)))
+		binary.LittleEndian.PutUint64(out, uint64(len(payload)))
+		n, err := lz4.CompressBlock(payload, out[8:], nil)
+		if err != nil || n == 0 {
+			t.Fatalf("failed to compress lz4 payload: %v", err)
+		}
+		return out[:8+n]
+	case objectCompressedZstd:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer encoder.Close()
+		return encoder.EncodeAll(payload, nil)
+	}
+	t.Fatalf("unsupported compression %d", compression)
+	return nil
+}
@@ -0,0 +1,302 @@
+package journal
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/turbot/tailpipe-plugin-core/sources/spool"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// entriesPerArtifact is the number of journal entries written to each artifact
+const entriesPerArtifact = 10000
+
+// JournalSource is an artifact source which reads entries directly from systemd journal files
+// (journalctl is not required). Each entry is written as a JSON object to an artifact in the temp dir, using the
+// same fields as `journalctl -o json` - so the jsonl format should be used to map the fields to columns
+// The sequence number of the last entry collected is stored in the collection state (for each journal file),
+// so subsequent collections only read new entries
+type JournalSource struct {
+	artifact_source.ArtifactSourceImpl[*JournalSourceConfig, *artifact_source.EmptyConnection]
+}
+
+func (s *JournalSource) Init(ctx context.Context, params *row_source.RowSourceParams, opts ...row_source.RowSourceOption) error {
+	// use the journal collection state - this tracks the cursor for each journal file, rather than the artifacts
+	s.NewCollectionStateFunc = NewJournalCollectionState
+
+	// call base to parse config and apply options
+	return s.ArtifactSourceImpl.Init(ctx, params, opts...)
+}
+
+func (s *JournalSource) Identifier() string {
+	return JournalSourceIdentifier
+}
+
+// DiscoverArtifacts reads the new entries from all journal files, writing them to artifacts in the temp dir,
+// and notifies observers of each artifact as it is completed
+// Errors reading a journal file are not fatal - they are notified and the next file is read
+func (s *JournalSource) DiscoverArtifacts(ctx context.Context) error {
+	executionId, err := context_values.ExecutionIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	paths := s.findJournalFiles(ctx, executionId)
+	files := make([]*journalFile, 0, len(paths))
+	for _, path := range paths {
+		j, err := openJournalFile(path)
+		if err != nil {
+			slog.Warn("JournalSource.DiscoverArtifacts error opening journal file", "path", path, "error", err)
+			s.NotifyError(ctx, executionId, fmt.Errorf("%s: unable to open journal file: %w", filepath.Base(path), err))
+			continue
+		}
+		defer j.Close()
+		files = append(files, j)
+	}
+	// read the files of each sequence in sequence number order
+	slices.SortFunc(files, func(a, b *journalFile) int {
+		return cmp.Or(
+			cmp.Compare(a.seqnumId(), b.seqnumId()),
+			cmp.Compare(a.header.headEntrySeqnum, b.header.headEntrySeqnum),
+			cmp.Compare(a.path, b.path),
+		)
+	})
+
+	var cursors map[string]JournalCursor
+	if state := s.journalCollectionState(); state != nil {
+		// remove the cursors of files which have been deleted (e.g. by journald vacuuming), so the state does not grow
+		// indefinitely - unless a file could not be opened, as we do not know which cursor is for that file
+		if len(files) == len(paths) {
+			keys := make(map[string]struct{}, len(files))
+			for _, j := range files {
+				keys[j.cursorKey()] = struct{}{}
+			}
+			state.RemoveCursors(func(key string) bool {
+				_, ok := keys[key]
+				return ok
+			})
+		}
+		cursors = state.GetCursors()
+	}
+
+	for _, j := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var cursor *JournalCursor
+		if c, ok := cursors[j.cursorKey()]; ok {
+			cursor = &c
+		}
+		if err := s.readJournalFile(ctx, executionId, j, cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DownloadArtifact does nothing as the artifact has already been spooled to the temp dir
+func (s *JournalSource) DownloadArtifact(ctx context.Context, info *types.ArtifactInfo) error {
+	downloadInfo, err := spool.NewDownloadedArtifactInfo(info)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDownloaded(ctx, downloadInfo)
+}
+
+// readJournalFile writes the entries of the journal file after the cursor to artifacts
+// only errors writing the artifacts are returned - errors reading the file are notified
+func (s *JournalSource) readJournalFile(ctx context.Context, executionId string, j *journalFile, cursor *JournalCursor) error {
+	seqnumId, cursorKey := j.seqnumId(), j.cursorKey()
+	// the cursors for the entries in the current artifact
+	artifactCursors := make(map[string]*JournalCursor)
+	w := spool.NewWriter(s.TempDir, "journal.jsonl", entriesPerArtifact, func(path string) error {
+		defer clear(artifactCursors)
+		return s.discoverArtifact(ctx, path, j.path, artifactCursors)
+	})
+	defer w.Close()
+
+	var writeErr error
+	var count int
+	readErr := j.entries(func(entry *journalEntry) error {
+		if cursor != nil && entry.seqnum <= cursor.Seqnum {
+			return nil
+		}
+		if !s.inCollectionTimeRange(time.UnixMicro(int64(entry.realtime))) { //nolint:gosec // realtime will not overflow
+			return nil
+		}
+
+		line, err := entryJson(seqnumId, entry)
+		if err != nil {
+			writeErr = err
+			return err
+		}
+		// set the cursor before writing, as the write may complete the artifact
+		artifactCursors[cursorKey] = &JournalCursor{Seqnum: entry.seqnum, Realtime: entry.realtime}
+		if err := w.WriteLine(line); err != nil {
+			writeErr = err
+			return err
+		}
+		count++
+		return nil
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if readErr != nil {
+		// the tail of a journal which is being written to may be incomplete - this is expected
+		if j.online() {
+			slog.Debug("JournalSource.DiscoverArtifacts error reading tail of online journal file", "path", j.path, "error", readErr)
+		} else {
+			slog.Warn("JournalSource.DiscoverArtifacts error reading journal file", "path", j.path, "error", readErr)
+			s.NotifyError(ctx, executionId, readErr)
+		}
+	}
+	slog.Info("JournalSource.DiscoverArtifacts read journal file", "path", j.path, "entries", count)
+
+	// write any entries we read before an error
+	return w.Flush()
+}
+
+// discoverArtifact notifies observers of a completed artifact, after storing the cursors for its entries
+func (s *JournalSource) discoverArtifact(ctx context.Context, path, journalPath string, cursors map[string]*JournalCursor) error {
+	info, err := spool.NewArtifactInfo(path, JournalSourceIdentifier, journalPath, nil)
+	if err != nil {
+		return err
+	}
+	if state := s.journalCollectionState(); state != nil {
+		state.SetPendingCursors(info.Identifier(), cursors)
+	}
+	return s.OnArtifactDiscovered(ctx, info)
+}
+
+// findJournalFiles returns the journal files in the configured paths
+// missing paths and errors searching directories are not fatal - they are notified
+func (s *JournalSource) findJournalFiles(ctx context.Context, executionId string) []string {
+	notify := func(err error) {
+		slog.Warn("JournalSource.DiscoverArtifacts error finding journal files", "error", err)
+		s.NotifyError(ctx, executionId, err)
+	}
+
+	var res []string
+	for _, p := range s.Config.GetPaths() {
+		roots := []string{p}
+		if isGlob(p) {
+			matches, err := doublestar.FilepathGlob(p)
+			if err != nil {
+				notify(fmt.Errorf("%s: unable to expand glob: %w", p, err))
+				continue
+			}
+			roots = matches
+		}
+
+		for _, root := range roots {
+			info, err := os.Stat(root)
+			if err != nil {
+				// the default paths may not exist - only notify for configured paths
+				if !errors.Is(err, fs.ErrNotExist) || len(s.Config.Paths) > 0 {
+					notify(fmt.Errorf("%s: path does not exist", root))
+				}
+				continue
+			}
+			if !info.IsDir() {
+				res = append(res, root)
+				continue
+			}
+			err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					notify(fmt.Errorf("%s: %w", path, errors.Unwrap(err)))
+					return nil
+				}
+				// archived journal files have the form <name>@<seqnum id>-<head seqnum>-<head realtime>.journal
+				if d.Type().IsRegular() && strings.HasSuffix(path, ".journal") {
+					res = append(res, path)
+				}
+				return nil
+			})
+			if err != nil {
+				notify(err)
+			}
+		}
+	}
+
+	slices.Sort(res)
+	return slices.Compact(res)
+}
+
+// inCollectionTimeRange returns whether the timestamp falls within the collection time range
+func (s *JournalSource) inCollectionTimeRange(ts time.Time) bool {
+	if ts.Before(s.CollectionTimeRange.LowerBoundary) {
+		return false
+	}
+	return s.CollectionTimeRange.UpperBoundary.IsZero() || ts.Before(s.CollectionTimeRange.UpperBoundary)
+}
+
+// journalCollectionState returns our collection state as a JournalCollectionState
+func (s *JournalSource) journalCollectionState() *JournalCollectionState {
+	if s.CollectionState == nil {
+		return nil
+	}
+	state, _ := s.CollectionState.State.(*JournalCollectionState)
+	return state
+}
+
+// entryJson returns the entry as a JSON object, in the same form as `journalctl -o json`:
+// - the entry metadata is added as the __CURSOR, __REALTIME_TIMESTAMP, __MONOTONIC_TIMESTAMP, __SEQNUM and __SEQNUM_ID fields
+// - values which are not valid UTF-8 are written as an array of bytes
+// - if a field has multiple values, they are written as an array
+func entryJson(seqnumId string, entry *journalEntry) ([]byte, error) {
+	row := map[string]any{
+		"__CURSOR":              entry.cursor(seqnumId),
+		"__REALTIME_TIMESTAMP":  strconv.FormatUint(entry.realtime, 10),
+		"__MONOTONIC_TIMESTAMP": strconv.FormatUint(entry.monotonic, 10),
+		"__SEQNUM":              strconv.FormatUint(entry.seqnum, 10),
+		"__SEQNUM_ID":           seqnumId,
+	}
+	for _, field := range entry.fields {
+		var value any = string(field.value)
+		if !utf8.Valid(field.value) {
+			bytes := make([]int, len(field.value))
+			for i, b := range field.value {
+				bytes[i] = int(b)
+			}
+			value = bytes
+		}
+
+		switch existing := row[field.name].(type) {
+		case nil:
+			row[field.name] = value
+		case []any:
+			row[field.name] = append(existing, value)
+		default:
+			row[field.name] = []any{existing, value}
+		}
+	}
+	// the boot id is stored in the entry, and is usually also a field
+	if _, ok := row["_BOOT_ID"]; !ok {
+		row["_BOOT_ID"] = hex.EncodeToString(entry.bootId[:])
+	}
+
+	return json.Marshal(row)
+}
+
+// cursor returns the cursor string for the entry, in the same form as journalctl
+func (e *journalEntry) cursor(seqnumId string) string {
+	return fmt.Sprintf("s=%s;i=%x;b=%x;m=%x;t=%x;x=%x", seqnumId, e.seqnum, e.bootId[:], e.monotonic, e.realtime, e.xorHash)
+}
@@ -0,0 +1,62 @@
+package journal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source_config"
+)
+
+const (
+	JournalSourceIdentifier = "journal"
+)
+
+// the default locations of the persistent and volatile journals
+var defaultJournalPaths = []string{"/var/log/journal", "/run/log/journal"}
+
+type JournalSourceConfig struct {
+	artifact_source_config.ArtifactSourceConfigImpl
+	// required to allow partial decoding
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// the journal directories or files to read - globs are supported
+	// directories are searched recursively for .journal files (default /var/log/journal and /run/log/journal)
+	Paths []string `hcl:"paths,optional"`
+}
+
+func (c *JournalSourceConfig) Validate() error {
+	// validate the base fields
+	if err := c.ArtifactSourceConfigImpl.Validate(); err != nil {
+		return err
+	}
+
+	for _, p := range c.Paths {
+		if p == "" {
+			return fmt.Errorf("paths can not contain an empty path")
+		}
+		if isGlob(p) && !doublestar.ValidatePathPattern(p) {
+			return fmt.Errorf("invalid glob pattern in paths: %s", p)
+		}
+	}
+
+	return nil
+}
+
+func (c *JournalSourceConfig) Identifier() string {
+	return JournalSourceIdentifier
+}
+
+// GetPaths returns the paths to read journal files from
+func (c *JournalSourceConfig) GetPaths() []string {
+	if len(c.Paths) == 0 {
+		return defaultJournalPaths
+	}
+	return c.Paths
+}
+
+// isGlob returns whether the path contains any glob meta characters
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[{")
+}
@@ -0,0 +1,244 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/events"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+type rowObserver struct {
+	Rows []string
+	mut  sync.Mutex
+}
+
+func (r *rowObserver) Notify(_ context.Context, e events.Event) error {
+	if row, ok := e.(*events.RowExtracted); ok {
+		r.mut.Lock()
+		r.Rows = append(r.Rows, row.Row.(string))
+		r.mut.Unlock()
+	}
+	return nil
+}
+
+func TestJournalSource_Collect(t *testing.T) {
+	dir := t.TempDir()
+	journalDir := filepath.Join(dir, "journal", "machine-id")
+	statePath := filepath.Join(dir, "state.json")
+	tempDir := filepath.Join(dir, "collection")
+
+	start := time.Now().Add(-time.Hour)
+	entry := func(seqnum uint64) testEntry {
+		return testEntry{
+			seqnum:   seqnum,
+			realtime: start.Add(time.Duration(seqnum) * time.Minute),
+			fields:   []string{fmt.Sprintf("MESSAGE=message %d", seqnum), "_SYSTEMD_UNIT=test.service"},
+		}
+	}
+	archivedPath := filepath.Join(journalDir, "system@0102030405060708090a0b0c0d0e0f10-0000000000000001-0000000000000001.journal")
+	activePath := filepath.Join(journalDir, "system.journal")
+
+	if err := os.MkdirAll(journalDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	archivedOpts := testJournalOptions{fileId: [16]byte{1}}
+	activeOpts := testJournalOptions{compact: true, online: true, fileId: [16]byte{2}}
+	writeTestJournal(t, archivedPath, archivedOpts, []testEntry{entry(1), entry(2), entry(3)})
+	writeTestJournal(t, activePath, activeOpts, []testEntry{entry(4), entry(5)})
+
+	messages := collectJournal(t, filepath.Join(dir, "journal"), statePath, tempDir)
+	assertMessages(t, "initial collection", messages, []string{"message 1", "message 2", "message 3", "message 4", "message 5"})
+
+	// nothing new has been written
+	messages = collectJournal(t, filepath.Join(dir, "journal"), statePath, tempDir)
+	assertMessages(t, "no new entries", messages, nil)
+
+	// new entries are written to the active file
+	writeTestJournal(t, activePath, activeOpts, []testEntry{entry(4), entry(5), entry(6), entry(7)})
+	messages = collectJournal(t, filepath.Join(dir, "journal"), statePath, tempDir)
+	assertMessages(t, "new entries", messages, []string{"message 6", "message 7"})
+}
+
+func TestJournalSource_CollectSharedSequence(t *testing.T) {
+	dir := t.TempDir()
+	journalDir := filepath.Join(dir, "journal", "machine-id")
+	statePath := filepath.Join(dir, "state.json")
+	tempDir := filepath.Join(dir, "collection")
+
+	start := time.Now().Add(-time.Hour)
+	entries := func(seqnums ...uint64) []testEntry {
+		var res []testEntry
+		for _, seqnum := range seqnums {
+			res = append(res, testEntry{
+				seqnum:   seqnum,
+				realtime: start.Add(time.Duration(seqnum) * time.Minute),
+				fields:   []string{fmt.Sprintf("MESSAGE=message %d", seqnum), "_SYSTEMD_UNIT=test.service"},
+			})
+		}
+		return res
+	}
+	// the system and user journals share a sequence number id, so their entries are interleaved
+	systemPath := filepath.Join(journalDir, "system.journal")
+	systemOpts := testJournalOptions{online: true, fileId: [16]byte{1}}
+	userPath := filepath.Join(journalDir, "user-1000.journal")
+	userOpts := testJournalOptions{online: true, fileId: [16]byte{2}}
+	if err := os.MkdirAll(journalDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// entry 7 is written to the system journal after it has been read, but before the user journal has been read
+	writeTestJournal(t, systemPath, systemOpts, entries(1, 3, 5))
+	writeTestJournal(t, userPath, userOpts, entries(2, 4, 6, 8))
+	messages := collectJournal(t, filepath.Join(dir, "journal"), statePath, tempDir)
+	assertMessages(t, "initial collection", messages, []string{"message 1", "message 2", "message 3", "message 4", "message 5", "message 6", "message 8"})
+
+	writeTestJournal(t, systemPath, systemOpts, entries(1, 3, 5, 7, 9))
+	writeTestJournal(t, userPath, userOpts, entries(2, 4, 6, 8, 10))
+	messages = collectJournal(t, filepath.Join(dir, "journal"), statePath, tempDir)
+	assertMessages(t, "new entries", messages, []string{"message 7", "message 9", "message 10"})
+
+	// when the system journal is archived, the entries collected from it are not collected again
+	archivedPath := filepath.Join(journalDir, "system@0102030405060708090a0b0c0d0e0f10-0000000000000001-0000000000000001.journal")
+	if err := os.Rename(systemPath, archivedPath); err != nil {
+		t.Fatal(err)
+	}
+	writeTestJournal(t, systemPath, testJournalOptions{online: true, fileId: [16]byte{3}}, entries(11))
+	messages = collectJournal(t, filepath.Join(dir, "journal"), statePath, tempDir)
+	assertMessages(t, "archived journal", messages, []string{"message 11"})
+
+	// the cursor of a deleted journal file is removed from the state
+	if err := os.Remove(archivedPath); err != nil {
+		t.Fatal(err)
+	}
+	collectJournal(t, filepath.Join(dir, "journal"), statePath, tempDir)
+	stateBytes, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		State struct {
+			Cursors map[string]JournalCursor `json:"cursors"`
+		} `json:"state"`
+	}
+	if err := json.Unmarshal(stateBytes, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.State.Cursors) != 2 {
+		t.Errorf("expected cursors for the two remaining journal files, got %v", saved.State.Cursors)
+	}
+}
+
+func TestJournalSource_CollectionStateOnlyContainsCursors(t *testing.T) {
+	dir := t.TempDir()
+	journalDir := filepath.Join(dir, "journal")
+	statePath := filepath.Join(dir, "state.json")
+	tempDir := filepath.Join(dir, "collection")
+
+	start := time.Now().Add(-time.Hour)
+	var entries []testEntry
+	for seqnum := uint64(1); seqnum <= 3; seqnum++ {
+		entries = append(entries, testEntry{
+			seqnum:   seqnum,
+			realtime: start.Add(time.Duration(seqnum) * time.Minute),
+			fields:   []string{fmt.Sprintf("MESSAGE=message %d", seqnum), "_SYSTEMD_UNIT=test.service"},
+		})
+	}
+	if err := os.MkdirAll(journalDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	activePath := filepath.Join(journalDir, "system.journal")
+	writeTestJournal(t, activePath, testJournalOptions{online: true}, entries[:2])
+	collectJournal(t, journalDir, statePath, tempDir)
+	writeTestJournal(t, activePath, testJournalOptions{online: true}, entries)
+	collectJournal(t, journalDir, statePath, tempDir)
+
+	// the spooled artifacts must not be stored in the state, otherwise it would grow with every collection
+	stateBytes, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		State map[string]json.RawMessage `json:"state"`
+	}
+	if err := json.Unmarshal(stateBytes, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.State) != 1 || saved.State["cursors"] == nil {
+		t.Fatalf("expected the collection state to only contain cursors, got %s", stateBytes)
+	}
+	var cursors map[string]JournalCursor
+	if err := json.Unmarshal(saved.State["cursors"], &cursors); err != nil {
+		t.Fatal(err)
+	}
+	if len(cursors) != 1 {
+		t.Fatalf("expected a single cursor, got %v", cursors)
+	}
+	for _, cursor := range cursors {
+		if cursor.Seqnum != 3 {
+			t.Errorf("expected cursor at seqnum 3, got %d", cursor.Seqnum)
+		}
+	}
+}
+
+// collectJournal runs a collection of the given journal directory and returns the MESSAGE field of the rows extracted
+func collectJournal(t *testing.T, journalDir, statePath, tempDir string) []string {
+	ctx := context_values.WithExecutionId(context.Background(), "test")
+
+	s := &JournalSource{}
+	any(s).(row_source.BaseSource).RegisterSource(s)
+
+	hclBytes := []byte(fmt.Sprintf("paths = [%q]", journalDir))
+	err := s.Init(ctx, &row_source.RowSourceParams{
+		SourceConfigData:    types.NewSourceConfigData(hclBytes, hcl.Range{}, JournalSourceIdentifier),
+		CollectionStatePath: statePath,
+		CollectionTempDir:   tempDir,
+	}, artifact_source.WithRowPerLine())
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+
+	var observer rowObserver
+	_ = s.AddObserver(&observer)
+
+	if err := s.Collect(ctx); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if err := s.OnCollectionComplete(); err != nil {
+		t.Fatalf("OnCollectionComplete() error = %v", err)
+	}
+
+	var messages []string
+	for _, row := range observer.Rows {
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(row), &fields); err != nil {
+			t.Fatalf("invalid row %s: %v", row, err)
+		}
+		if fields["_SYSTEMD_UNIT"] != "test.service" {
+			t.Errorf("expected _SYSTEMD_UNIT field in row %s", row)
+		}
+		messages = append(messages, fields["MESSAGE"].(string))
+	}
+	return messages
+}
+
+func assertMessages(t *testing.T, name string, messages, expected []string) {
+	// entries from different files may be extracted in any order
+	slices.Sort(messages)
+	expected = slices.Clone(expected)
+	slices.Sort(expected)
+	if !slices.Equal(messages, expected) {
+		t.Fatalf("%s: expected messages %v, got %v", name, expected, messages)
+	}
+}
@@ -0,0 +1,117 @@
+package spool
+
+import (
+	"encoding/json"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-sdk/collection_state"
+)
+
+// CursorCollectionState is the collection state for a spooling source which reads logs that persist between
+// collections (e.g. journal files or container logs). It tracks a cursor for each log (i.e. the position of the last
+// entry collected) so subsequent collections only read new entries
+// The artifacts are spooled files which are named uniquely for each collection, so they are not tracked - all other
+// CollectionState methods are provided by the no-op spool collection state
+type CursorCollectionState[C any] struct {
+	collection_state.CollectionState `json:"-"`
+
+	// map of log key to the cursor for that log
+	Cursors map[string]*C `json:"cursors,omitempty"`
+
+	// map of artifact to the cursors for the entries written to it
+	// the cursors are moved into Cursors when OnCollected is called for the artifact
+	pending map[string]map[string]*C
+	// returns whether a cursor is after another cursor - used to ensure cursors only ever move forwards
+	after func(c, other *C) bool
+	// the cursors are accessed from the discovery and collection goroutines, so we need our own lock
+	mut sync.RWMutex
+}
+
+func NewCursorCollectionState[C any](after func(c, other *C) bool) *CursorCollectionState[C] {
+	return &CursorCollectionState[C]{
+		CollectionState: NewCollectionState(),
+		Cursors:         make(map[string]*C),
+		pending:         make(map[string]map[string]*C),
+		after:           after,
+	}
+}
+
+// OnCollected is called when an artifact has been collected - if there are pending cursors for the artifact,
+// store them so the next collection continues after the entries in the artifact
+func (s *CursorCollectionState[C]) OnCollected(id string, _ time.Time) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for key, cursor := range s.pending[id] {
+		// artifacts may be collected out of order - only ever move the cursor forwards
+		if existing, ok := s.Cursors[key]; !ok || s.after(cursor, existing) {
+			s.Cursors[key] = cursor
+		}
+	}
+	delete(s.pending, id)
+	return nil
+}
+
+// IsEmpty returns whether the collection state is empty
+func (s *CursorCollectionState[C]) IsEmpty() bool {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return len(s.Cursors) == 0
+}
+
+// Clear clears the collection state for the given time range
+// A cursor can not be moved back to a point in time, so we clear all cursors
+// (meaning all entries within the collection time range will be collected again)
+func (s *CursorCollectionState[C]) Clear(_ collection_state.DirectionalTimeRange) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.Cursors = make(map[string]*C)
+	s.pending = make(map[string]map[string]*C)
+}
+
+// GetCursors returns a copy of the cursors for all logs
+func (s *CursorCollectionState[C]) GetCursors() map[string]C {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	res := make(map[string]C, len(s.Cursors))
+	for key, cursor := range s.Cursors {
+		res[key] = *cursor
+	}
+	return res
+}
+
+// RemoveCursors removes the cursors for the logs which no longer exist, i.e. the keys for which exists returns false
+func (s *CursorCollectionState[C]) RemoveCursors(exists func(key string) bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	maps.DeleteFunc(s.Cursors, func(key string, _ *C) bool {
+		return !exists(key)
+	})
+}
+
+// SetPendingCursors stores the cursors for the entries written to an artifact
+// these will be stored in Cursors when the artifact is collected
+func (s *CursorCollectionState[C]) SetPendingCursors(id string, cursors map[string]*C) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.pending[id] = maps.Clone(cursors)
+}
+
+// MarshalJSON locks the mutex before serialising the state
+// as the Cursors map may be updated by a collection goroutine while the state is being saved
+func (s *CursorCollectionState[C]) MarshalJSON() ([]byte, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	// serialise the exported fields only, to avoid MarshalJSON recursing
+	return json.Marshal(struct {
+		Cursors map[string]*C `json:"cursors,omitempty"`
+	}{s.Cursors})
+}
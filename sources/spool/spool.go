@@ -0,0 +1,53 @@
+// Package spool provides helpers for sources which receive a stream of data (e.g. stdin, a listener or a command),
+// and spool the data to a file in the collection temp dir so it can be processed by the artifact pipeline
+package spool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/collection_state"
+	"github.com/turbot/tailpipe-plugin-sdk/schema"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// CreateFile creates a file to spool data to in the temp dir
+// the name is retained as a suffix of the file name, so the extension (which determines the loader) is unchanged
+func CreateFile(tempDir, name string) (*os.File, error) {
+	return os.CreateTemp(tempDir, "*-"+filepath.Base(name))
+}
+
+// NewCollectionState returns the collection state for a spooling source
+// Spooled files are always collected, and are named uniquely for each collection, so there is nothing to track
+// (the default ArtifactCollectionState would record every spooled file, so the state would grow indefinitely)
+// NOTE: the returned state never reports an artifact should be collected, so sources must not call ShouldCollect
+func NewCollectionState() collection_state.CollectionState {
+	return &artifact_source.NilArtifactCollectionState{}
+}
+
+// NewArtifactInfo returns the artifact info for a spooled file
+// As the artifact name is the path of the spooled file, the source location is set to the location the data was
+// received from (e.g. "stdin" or a listen address). Any additional metadata is added to the source enrichment
+func NewArtifactInfo(path, sourceType, sourceLocation string, metadata map[string]string) (*types.ArtifactInfo, error) {
+	enrichment := map[string]string{
+		"tp_source_location": sourceLocation,
+		"tp_source_type":     sourceType,
+	}
+	for k, v := range metadata {
+		enrichment[k] = v
+	}
+	// spooled files have no time granularity - they are always collected
+	return types.NewArtifactInfo(path, schema.NewSourceEnrichment(enrichment), 0)
+}
+
+// NewDownloadedArtifactInfo returns the downloaded artifact info for a spooled file
+// the file is already in the temp dir so there is nothing to download
+func NewDownloadedArtifactInfo(info *types.ArtifactInfo) (*types.DownloadedArtifactInfo, error) {
+	fileInfo, err := os.Stat(info.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to obtain file info", filepath.Base(info.Name))
+	}
+	return types.NewDownloadedArtifactInfo(info, info.Name, fileInfo.Size()), nil
+}
@@ -0,0 +1,83 @@
+package spool
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Writer writes lines to spool files in the temp dir, completing the file once it contains linesPerFile lines
+// (so artifacts can be collected while the source continues to receive data)
+// onComplete is called with the path of each completed file - this will usually notify observers of the artifact
+//
+// Writer is not safe for concurrent use
+type Writer struct {
+	tempDir      string
+	name         string
+	linesPerFile int
+	onComplete   func(path string) error
+
+	file  *os.File
+	w     *bufio.Writer
+	lines int
+}
+
+func NewWriter(tempDir, name string, linesPerFile int, onComplete func(path string) error) *Writer {
+	return &Writer{
+		tempDir:      tempDir,
+		name:         name,
+		linesPerFile: linesPerFile,
+		onComplete:   onComplete,
+	}
+}
+
+// WriteLine writes a line to the current spool file (a newline is appended)
+func (w *Writer) WriteLine(line []byte) error {
+	if w.file == nil {
+		f, err := CreateFile(w.tempDir, w.name)
+		if err != nil {
+			slog.Error("spool.Writer error creating spool file", "error", err)
+			return fmt.Errorf("unable to create spool file: %w", err)
+		}
+		w.file = f
+		w.w = bufio.NewWriter(f)
+	}
+
+	if _, err := w.w.Write(line); err != nil {
+		return fmt.Errorf("error writing spool file: %w", err)
+	}
+	if err := w.w.WriteByte('\n'); err != nil {
+		return fmt.Errorf("error writing spool file: %w", err)
+	}
+
+	w.lines++
+	if w.lines >= w.linesPerFile {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush completes the current spool file, if any
+func (w *Writer) Flush() error {
+	if w.file == nil {
+		return nil
+	}
+	path := w.file.Name()
+	err := w.w.Flush()
+	w.Close()
+	if err != nil {
+		return fmt.Errorf("error writing spool file: %w", err)
+	}
+	return w.onComplete(path)
+}
+
+// Close closes the current spool file without completing it
+func (w *Writer) Close() {
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.file = nil
+	w.w = nil
+	w.lines = 0
+}
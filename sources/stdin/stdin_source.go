@@ -0,0 +1,115 @@
+package stdin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/turbot/tailpipe-plugin-core/sources/spool"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// StdinSource is an artifact source which reads rows from stdin, or from a named pipe
+// The data is spooled to a file in the temp dir, which is then processed as an artifact, so all formats
+// supported for files are supported
+type StdinSource struct {
+	artifact_source.ArtifactSourceImpl[*StdinSourceConfig, *artifact_source.EmptyConnection]
+
+	// the reader to use for stdin - this is overridden for testing
+	stdin io.Reader
+}
+
+// Init sets the collection state then calls the base Init
+func (s *StdinSource) Init(ctx context.Context, params *row_source.RowSourceParams, opts ...row_source.RowSourceOption) error {
+	// spooled data is always collected, so there is no collection state to keep
+	s.NewCollectionStateFunc = spool.NewCollectionState
+
+	return s.ArtifactSourceImpl.Init(ctx, params, opts...)
+}
+
+func (s *StdinSource) Identifier() string {
+	return StdinSourceIdentifier
+}
+
+// DiscoverArtifacts reads all data from stdin (or the named pipe) into a file in the temp dir,
+// and notifies observers of the file as a discovered artifact
+func (s *StdinSource) DiscoverArtifacts(ctx context.Context) error {
+	r, sourceLocation, err := s.openInput()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// if the context is cancelled, close the input to unblock the read
+	readComplete := make(chan struct{})
+	defer close(readComplete)
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.Close()
+		case <-readComplete:
+		}
+	}()
+
+	f, err := spool.CreateFile(s.TempDir, sourceLocation)
+	if err != nil {
+		slog.Error("StdinSource.DiscoverArtifacts error creating spool file", "error", err)
+		return fmt.Errorf("unable to create spool file: %w", err)
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.Error("StdinSource.DiscoverArtifacts error reading input", "source", sourceLocation, "error", err)
+		return fmt.Errorf("error reading %s: %w", sourceLocation, err)
+	}
+	slog.Info("StdinSource.DiscoverArtifacts read input", "source", sourceLocation, "bytes", n)
+
+	// if there is no data, there is nothing to collect
+	if n == 0 {
+		return nil
+	}
+
+	info, err := spool.NewArtifactInfo(f.Name(), StdinSourceIdentifier, sourceLocation, nil)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDiscovered(ctx, info)
+}
+
+// openInput returns the reader for the input, and the location to use as the source location
+func (s *StdinSource) openInput() (io.ReadCloser, string, error) {
+	if s.Config.Path == nil {
+		stdin := s.stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		return io.NopCloser(stdin), "stdin", nil
+	}
+
+	// NOTE: opening a named pipe blocks until there is a writer
+	path := *s.Config.Path
+	f, err := os.Open(path)
+	if err != nil {
+		slog.Error("StdinSource.DiscoverArtifacts error opening named pipe", "path", path, "error", err)
+		return nil, "", fmt.Errorf("%s: unable to open named pipe", filepath.Base(path))
+	}
+	return f, path, nil
+}
+
+// DownloadArtifact does nothing as the artifact has already been spooled to the temp dir
+func (s *StdinSource) DownloadArtifact(ctx context.Context, info *types.ArtifactInfo) error {
+	downloadInfo, err := spool.NewDownloadedArtifactInfo(info)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDownloaded(ctx, downloadInfo)
+}
@@ -0,0 +1,47 @@
+package stdin
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source_config"
+)
+
+const (
+	StdinSourceIdentifier = "stdin"
+)
+
+type StdinSourceConfig struct {
+	artifact_source_config.ArtifactSourceConfigImpl
+	// required to allow partial decoding
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// if set, data is read from this named pipe (FIFO) rather than from stdin
+	// data is read until all writers have closed the pipe
+	Path *string `hcl:"path,optional"`
+}
+
+func (c *StdinSourceConfig) Validate() error {
+	// validate the base fields
+	if err := c.ArtifactSourceConfigImpl.Validate(); err != nil {
+		return err
+	}
+
+	// validate the path is a named pipe
+	if c.Path != nil {
+		info, err := os.Stat(*c.Path)
+		if err != nil {
+			return fmt.Errorf("path %s does not exist", *c.Path)
+		}
+		if info.Mode()&os.ModeNamedPipe == 0 {
+			return fmt.Errorf("path %s is not a named pipe", *c.Path)
+		}
+	}
+
+	return nil
+}
+
+func (c *StdinSourceConfig) Identifier() string {
+	return StdinSourceIdentifier
+}
@@ -0,0 +1,81 @@
+package stdin
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/events"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+type rowObserver struct {
+	Rows []string
+	mut  sync.Mutex
+}
+
+func (r *rowObserver) Notify(_ context.Context, e events.Event) error {
+	if row, ok := e.(*events.RowExtracted); ok {
+		r.mut.Lock()
+		r.Rows = append(r.Rows, row.Row.(string))
+		r.mut.Unlock()
+	}
+	return nil
+}
+
+func TestStdinSource_Collect(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		expectedRows []string
+	}{
+		{
+			name:         "rows",
+			input:        "line 1\nline 2\nline 3\n",
+			expectedRows: []string{"line 1", "line 2", "line 3"},
+		},
+		{
+			name:         "no trailing newline",
+			input:        "line 1\nline 2",
+			expectedRows: []string{"line 1", "line 2"},
+		},
+		{
+			name:  "no input",
+			input: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context_values.WithExecutionId(context.Background(), "test")
+
+			s := &StdinSource{stdin: strings.NewReader(tt.input)}
+			any(s).(row_source.BaseSource).RegisterSource(s)
+
+			err := s.Init(ctx, &row_source.RowSourceParams{
+				SourceConfigData:  types.NewSourceConfigData(nil, hcl.Range{}, StdinSourceIdentifier),
+				CollectionTempDir: t.TempDir(),
+			}, artifact_source.WithRowPerLine())
+			if err != nil {
+				t.Fatalf("failed to init: %v", err)
+			}
+
+			var observer rowObserver
+			_ = s.AddObserver(&observer)
+
+			if err := s.Collect(ctx); err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			slices.Sort(observer.Rows)
+			if !slices.Equal(observer.Rows, tt.expectedRows) {
+				t.Errorf("expected rows %v, got %v", tt.expectedRows, observer.Rows)
+			}
+		})
+	}
+}
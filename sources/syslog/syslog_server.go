@@ -0,0 +1,300 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// maxMessageSize is the maximum size of a syslog message we will accept
+const maxMessageSize = 64 * 1024
+
+// maxFrameLengthDigits is the maximum number of digits in the length prefix of an octet counted frame
+var maxFrameLengthDigits = len(strconv.Itoa(maxMessageSize))
+
+// syslogServer listens for syslog messages over UDP, TCP or TCP+TLS, and sends each message received
+// to the messages channel until it is stopped
+type syslogServer struct {
+	protocol  string
+	address   string
+	tlsConfig *tls.Config
+
+	messages chan []byte
+
+	packetConn net.PacketConn
+	listener   net.Listener
+	// the open TCP connections - these are closed when the server is stopped
+	conns    map[net.Conn]struct{}
+	connsMut sync.Mutex
+
+	// closed when the server is stopped, to stop the receiving goroutines
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newSyslogServer(config *SyslogSourceConfig) (*syslogServer, error) {
+	s := &syslogServer{
+		protocol: config.GetProtocol(),
+		address:  config.Address,
+		messages: make(chan []byte, 1000),
+		conns:    make(map[net.Conn]struct{}),
+		done:     make(chan struct{}),
+	}
+	if s.protocol == ProtocolTLS {
+		tlsConfig, err := newTlsConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		s.tlsConfig = tlsConfig
+	}
+	return s, nil
+}
+
+func newTlsConfig(config *SyslogSourceConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(*config.TlsCertFile, *config.TlsKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading tls certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if config.TlsCaFile != nil {
+		caCert, err := os.ReadFile(*config.TlsCaFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading tls ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("tls ca file %s contains no certificates", *config.TlsCaFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// start starts listening - messages are sent to the messages channel
+func (s *syslogServer) start() error {
+	switch s.protocol {
+	case ProtocolUDP:
+		conn, err := net.ListenPacket("udp", s.address)
+		if err != nil {
+			return fmt.Errorf("error listening on %s: %w", s.address, err)
+		}
+		s.packetConn = conn
+		s.wg.Add(1)
+		go s.receivePackets()
+	default:
+		var listener net.Listener
+		var err error
+		if s.tlsConfig != nil {
+			listener, err = tls.Listen("tcp", s.address, s.tlsConfig)
+		} else {
+			listener, err = net.Listen("tcp", s.address)
+		}
+		if err != nil {
+			return fmt.Errorf("error listening on %s: %w", s.address, err)
+		}
+		s.listener = listener
+		s.wg.Add(1)
+		go s.acceptConnections()
+	}
+	slog.Info("syslogServer listening", "protocol", s.protocol, "address", s.addr())
+	return nil
+}
+
+// addr returns the address we are listening on
+func (s *syslogServer) addr() net.Addr {
+	if s.packetConn != nil {
+		return s.packetConn.LocalAddr()
+	}
+	if s.listener != nil {
+		return s.listener.Addr()
+	}
+	return nil
+}
+
+// stop stops listening, closes all connections and waits for the receiving goroutines to complete
+// any messages already in the messages channel may still be read
+func (s *syslogServer) stop() {
+	select {
+	case <-s.done:
+		// already stopped
+		return
+	default:
+	}
+	close(s.done)
+
+	if s.packetConn != nil {
+		s.packetConn.Close()
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.connsMut.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connsMut.Unlock()
+
+	s.wg.Wait()
+}
+
+// send sends a message to the messages channel, returning false if the server has been stopped
+func (s *syslogServer) send(msg []byte) bool {
+	msg = trimMessage(msg)
+	if len(msg) == 0 {
+		return true
+	}
+	select {
+	case s.messages <- msg:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// receivePackets receives UDP packets - each packet is a single message
+func (s *syslogServer) receivePackets() {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := s.packetConn.ReadFrom(buf)
+		if err != nil {
+			if !s.stopped() {
+				slog.Error("syslogServer error receiving packet", "error", err)
+			}
+			return
+		}
+		if !s.send(bytes.Clone(buf[:n])) {
+			return
+		}
+	}
+}
+
+func (s *syslogServer) acceptConnections() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !s.stopped() {
+				slog.Error("syslogServer error accepting connection", "error", err)
+			}
+			return
+		}
+
+		s.connsMut.Lock()
+		// if we have been stopped since accepting, do not start reading
+		if s.stopped() {
+			s.connsMut.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.connsMut.Unlock()
+
+		s.wg.Add(1)
+		go s.receiveStream(conn)
+	}
+}
+
+// receiveStream reads messages from a TCP connection until it is closed
+func (s *syslogServer) receiveStream(conn net.Conn) {
+	defer func() {
+		s.connsMut.Lock()
+		delete(s.conns, conn)
+		s.connsMut.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+
+	// the buffer size bounds the size of a message with non-transparent framing
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		msg, err := readFrame(r)
+		if len(msg) > 0 && !s.send(msg) {
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !s.stopped() {
+				slog.Warn("syslogServer error reading from connection", "remote", conn.RemoteAddr(), "error", err)
+			}
+			return
+		}
+	}
+}
+
+func (s *syslogServer) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// readFrame reads a single message from a syslog stream. Both framing methods of RFC 6587 are supported,
+// and the method is detected for each frame:
+// - octet counting, where the message is prefixed with its length, e.g. "11 <34>1 hello"
+// - non-transparent framing, where the message is terminated with a newline
+// An error is returned for a frame which is longer than maxMessageSize (the size of the buffer of the reader)
+// - the stream can not be resynchronised, so the connection should be closed
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	// a message with octet counting framing starts with a non-zero digit (a message with
+	// non-transparent framing starts with the '<' of the PRI)
+	if first[0] >= '1' && first[0] <= '9' {
+		// read the length a byte at a time, so a prefix without a terminating space is not read indefinitely
+		length := 0
+		for digits := 0; ; digits++ {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c == ' ' {
+				break
+			}
+			if c < '0' || c > '9' || digits == maxFrameLengthDigits {
+				return nil, errors.New("invalid frame length")
+			}
+			length = length*10 + int(c-'0')
+		}
+		if length > maxMessageSize {
+			return nil, fmt.Errorf("frame length %d exceeds the maximum message size of %d bytes", length, maxMessageSize)
+		}
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	// return any final message which is not terminated with a newline along with the EOF
+	msg, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("message exceeds the maximum message size of %d bytes", r.Size())
+	}
+	// the slice is only valid until the next read, so copy it
+	return bytes.Clone(msg), err
+}
+
+// trimMessage removes any trailing newline, carriage return or NUL characters from the message
+func trimMessage(msg []byte) []byte {
+	return bytes.TrimRight(msg, "\r\n\x00")
+}
@@ -0,0 +1,135 @@
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/turbot/tailpipe-plugin-core/sources/spool"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// messagesPerArtifact is the number of messages written to each artifact
+// - artifacts are collected while we continue to listen
+const messagesPerArtifact = 10000
+
+// SyslogSource is an artifact source which listens for syslog messages over UDP, TCP or TCP+TLS
+// It listens for the configured duration, or until the message or byte budget is met, writing each message
+// as a line to an artifact in the temp dir. Any line breaks within a message are escaped as #012 (and carriage
+// returns as #015) - this is consistent with rsyslog
+type SyslogSource struct {
+	artifact_source.ArtifactSourceImpl[*SyslogSourceConfig, *artifact_source.EmptyConnection]
+
+	// called once we are listening - used for testing
+	onListening func(addr net.Addr)
+}
+
+// Init sets the collection state then calls the base Init
+func (s *SyslogSource) Init(ctx context.Context, params *row_source.RowSourceParams, opts ...row_source.RowSourceOption) error {
+	// spooled data is always collected, so there is no collection state to keep
+	s.NewCollectionStateFunc = spool.NewCollectionState
+
+	return s.ArtifactSourceImpl.Init(ctx, params, opts...)
+}
+
+func (s *SyslogSource) Identifier() string {
+	return SyslogSourceIdentifier
+}
+
+// DiscoverArtifacts listens for syslog messages, writing them to artifacts in the temp dir,
+// and notifies observers of each artifact as it is completed
+func (s *SyslogSource) DiscoverArtifacts(ctx context.Context) error {
+	server, err := newSyslogServer(s.Config)
+	if err != nil {
+		return err
+	}
+	if err := server.start(); err != nil {
+		slog.Error("SyslogSource.DiscoverArtifacts error starting listener", "error", err)
+		return err
+	}
+	defer server.stop()
+
+	if s.onListening != nil {
+		s.onListening(server.addr())
+	}
+
+	sourceLocation := fmt.Sprintf("%s://%s", server.protocol, server.addr())
+	w := spool.NewWriter(s.TempDir, "syslog.log", messagesPerArtifact, func(path string) error {
+		return s.discoverArtifact(ctx, path, sourceLocation)
+	})
+
+	timer := time.NewTimer(s.Config.GetDuration())
+	defer timer.Stop()
+
+	var messageCount int
+	var byteCount int64
+	budgetMet := func() bool {
+		return (s.Config.MaxMessages != nil && messageCount >= *s.Config.MaxMessages) ||
+			(s.Config.MaxBytes != nil && byteCount >= *s.Config.MaxBytes)
+	}
+
+listen:
+	for !budgetMet() {
+		select {
+		case <-ctx.Done():
+			w.Close()
+			return ctx.Err()
+		case <-timer.C:
+			break listen
+		case msg := <-server.messages:
+			messageCount++
+			byteCount += int64(len(msg))
+			if err := w.WriteLine(escapeMessage(msg)); err != nil {
+				w.Close()
+				return err
+			}
+		}
+	}
+	slog.Info("SyslogSource.DiscoverArtifacts stopped listening", "messages", messageCount, "bytes", byteCount)
+
+	// stop the server, then write any messages which have been received but not yet written
+	server.stop()
+	for len(server.messages) > 0 && !budgetMet() {
+		msg := <-server.messages
+		messageCount++
+		byteCount += int64(len(msg))
+		if err := w.WriteLine(escapeMessage(msg)); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// DownloadArtifact does nothing as the artifact has already been spooled to the temp dir
+func (s *SyslogSource) DownloadArtifact(ctx context.Context, info *types.ArtifactInfo) error {
+	downloadInfo, err := spool.NewDownloadedArtifactInfo(info)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDownloaded(ctx, downloadInfo)
+}
+
+// discoverArtifact notifies observers of a completed spool file
+func (s *SyslogSource) discoverArtifact(ctx context.Context, path, sourceLocation string) error {
+	info, err := spool.NewArtifactInfo(path, SyslogSourceIdentifier, sourceLocation, nil)
+	if err != nil {
+		return err
+	}
+	return s.OnArtifactDiscovered(ctx, info)
+}
+
+// escapeMessage escapes any line breaks in the message, so each message is written as a single line
+func escapeMessage(msg []byte) []byte {
+	if bytes.IndexAny(msg, "\r\n") == -1 {
+		return msg
+	}
+	msg = bytes.ReplaceAll(msg, []byte("\n"), []byte("#012"))
+	return bytes.ReplaceAll(msg, []byte("\r"), []byte("#015"))
+}
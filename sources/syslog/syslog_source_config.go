@@ -0,0 +1,117 @@
+package syslog
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source_config"
+)
+
+const (
+	SyslogSourceIdentifier = "syslog"
+
+	ProtocolUDP = "udp"
+	ProtocolTCP = "tcp"
+	ProtocolTLS = "tls"
+
+	defaultDuration = time.Minute
+)
+
+type SyslogSourceConfig struct {
+	artifact_source_config.ArtifactSourceConfigImpl
+	// required to allow partial decoding
+	Remain hcl.Body `hcl:",remain" json:"-"`
+
+	// the address to listen on, e.g. ":514" or "127.0.0.1:5514"
+	Address string `hcl:"address"`
+	// the protocol to listen with: "udp" (the default), "tcp" or "tls"
+	Protocol *string `hcl:"protocol,optional"`
+
+	// how long to listen for, e.g. "30s" or "5m" (default 1m)
+	Duration *string `hcl:"duration,optional"`
+	// if set, stop listening once this many messages have been received
+	MaxMessages *int `hcl:"max_messages,optional"`
+	// if set, stop listening once this many bytes of messages have been received
+	MaxBytes *int64 `hcl:"max_bytes,optional"`
+
+	// the server certificate and key - required for the "tls" protocol
+	TlsCertFile *string `hcl:"tls_cert_file,optional"`
+	TlsKeyFile  *string `hcl:"tls_key_file,optional"`
+	// if set, clients must present a certificate signed by this CA
+	TlsCaFile *string `hcl:"tls_ca_file,optional"`
+}
+
+func (c *SyslogSourceConfig) Validate() error {
+	// validate the base fields
+	if err := c.ArtifactSourceConfigImpl.Validate(); err != nil {
+		return err
+	}
+
+	if c.Address == "" {
+		return fmt.Errorf("required field: address can not be empty")
+	}
+
+	protocol := c.GetProtocol()
+	if !slices.Contains([]string{ProtocolUDP, ProtocolTCP, ProtocolTLS}, protocol) {
+		return fmt.Errorf("invalid protocol %s: must be one of %s, %s or %s", protocol, ProtocolUDP, ProtocolTCP, ProtocolTLS)
+	}
+
+	if c.Duration != nil {
+		d, err := time.ParseDuration(*c.Duration)
+		if err != nil {
+			return fmt.Errorf("invalid duration %s: %w", *c.Duration, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("duration must be greater than zero")
+		}
+	}
+	if c.MaxMessages != nil && *c.MaxMessages < 1 {
+		return fmt.Errorf("max_messages must be at least 1")
+	}
+	if c.MaxBytes != nil && *c.MaxBytes < 1 {
+		return fmt.Errorf("max_bytes must be at least 1")
+	}
+
+	// validate the tls files
+	if protocol == ProtocolTLS && (c.TlsCertFile == nil || c.TlsKeyFile == nil) {
+		return fmt.Errorf("tls_cert_file and tls_key_file are required for protocol %s", ProtocolTLS)
+	}
+	for _, path := range []*string{c.TlsCertFile, c.TlsKeyFile, c.TlsCaFile} {
+		if path == nil {
+			continue
+		}
+		if protocol != ProtocolTLS {
+			return fmt.Errorf("tls_cert_file, tls_key_file and tls_ca_file are only supported for protocol %s", ProtocolTLS)
+		}
+		if _, err := os.Stat(*path); err != nil {
+			return fmt.Errorf("tls file %s does not exist", *path)
+		}
+	}
+
+	return nil
+}
+
+func (c *SyslogSourceConfig) Identifier() string {
+	return SyslogSourceIdentifier
+}
+
+// GetProtocol returns the protocol to listen with (default udp)
+func (c *SyslogSourceConfig) GetProtocol() string {
+	if c.Protocol == nil {
+		return ProtocolUDP
+	}
+	return *c.Protocol
+}
+
+// GetDuration returns how long to listen for
+func (c *SyslogSourceConfig) GetDuration() time.Duration {
+	if c.Duration == nil {
+		return defaultDuration
+	}
+	// we have already validated the duration
+	d, _ := time.ParseDuration(*c.Duration)
+	return d
+}
@@ -0,0 +1,157 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
+	"github.com/turbot/tailpipe-plugin-sdk/context_values"
+	"github.com/turbot/tailpipe-plugin-sdk/events"
+	"github.com/turbot/tailpipe-plugin-sdk/row_source"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+type rowObserver struct {
+	Rows []string
+	mut  sync.Mutex
+}
+
+func (r *rowObserver) Notify(_ context.Context, e events.Event) error {
+	if row, ok := e.(*events.RowExtracted); ok {
+		r.mut.Lock()
+		r.Rows = append(r.Rows, row.Row.(string))
+		r.mut.Unlock()
+	}
+	return nil
+}
+
+func TestSyslogSource_Collect(t *testing.T) {
+	tests := []struct {
+		name         string
+		protocol     string
+		frames       []string
+		expectedRows []string
+	}{
+		{
+			name:     "udp",
+			protocol: ProtocolUDP,
+			frames: []string{
+				"<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8\n",
+				"<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event",
+			},
+			expectedRows: []string{
+				"<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event",
+				"<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+			},
+		},
+		{
+			name:     "tcp with octet counting and newline framing",
+			protocol: ProtocolTCP,
+			frames: []string{
+				"<34>Oct 11 22:14:15 mymachine su: newline framed\n",
+				octetCounted("<165>1 - host app - - - octet counted"),
+				octetCounted("<165>1 - host app - - - multi\nline message"),
+				"<34>Oct 11 22:14:16 mymachine su: newline framed 2\r\n",
+			},
+			expectedRows: []string{
+				"<165>1 - host app - - - multi#012line message",
+				"<165>1 - host app - - - octet counted",
+				"<34>Oct 11 22:14:15 mymachine su: newline framed",
+				"<34>Oct 11 22:14:16 mymachine su: newline framed 2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context_values.WithExecutionId(context.Background(), "test")
+
+			s := &SyslogSource{}
+			s.onListening = func(addr net.Addr) {
+				// send the messages once the source is listening
+				go sendFrames(t, tt.protocol, addr.String(), tt.frames)
+			}
+			any(s).(row_source.BaseSource).RegisterSource(s)
+
+			// listen until we have received all messages
+			hclBytes := []byte(fmt.Sprintf("address = \"127.0.0.1:0\"\nprotocol = %q\nduration = \"10s\"\nmax_messages = %d", tt.protocol, len(tt.frames)))
+			err := s.Init(ctx, &row_source.RowSourceParams{
+				SourceConfigData:  types.NewSourceConfigData(hclBytes, hcl.Range{}, SyslogSourceIdentifier),
+				CollectionTempDir: t.TempDir(),
+			}, artifact_source.WithRowPerLine())
+			if err != nil {
+				t.Fatalf("failed to init: %v", err)
+			}
+
+			var observer rowObserver
+			_ = s.AddObserver(&observer)
+
+			if err := s.Collect(ctx); err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			slices.Sort(observer.Rows)
+			if !slices.Equal(observer.Rows, tt.expectedRows) {
+				t.Errorf("expected rows %q, got %q", tt.expectedRows, observer.Rows)
+			}
+		})
+	}
+}
+
+// octetCounted returns the message framed using octet counting
+func octetCounted(msg string) string {
+	return fmt.Sprintf("%d %s", len(msg), msg)
+}
+
+func sendFrames(t *testing.T, protocol, addr string, frames []string) {
+	network := "tcp"
+	if protocol == ProtocolUDP {
+		network = "udp"
+	}
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		t.Errorf("failed to connect: %v", err)
+		return
+	}
+	defer conn.Close()
+	for _, frame := range frames {
+		if _, err := conn.Write([]byte(frame)); err != nil {
+			t.Errorf("failed to send: %v", err)
+			return
+		}
+	}
+}
+
+func TestReadFrame_TooLong(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "octet counted frame", input: octetCounted(strings.Repeat("a", maxMessageSize+1))},
+		{name: "length prefix without a space", input: strings.Repeat("1", 1024)},
+		{name: "non-transparent frame", input: "<34>" + strings.Repeat("a", maxMessageSize) + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReaderSize(strings.NewReader(tt.input), maxMessageSize)
+			if msg, err := readFrame(r); err == nil || errors.Is(err, io.EOF) {
+				t.Errorf("expected an error reading an oversized frame, got %d bytes, error %v", len(msg), err)
+			}
+		})
+	}
+
+	// a message of the maximum size is accepted
+	msg := "<34>" + strings.Repeat("a", maxMessageSize-5) + "\n"
+	r := bufio.NewReaderSize(strings.NewReader(msg), maxMessageSize)
+	if got, err := readFrame(r); err != nil || string(got) != msg {
+		t.Errorf("expected message of %d bytes, got %d bytes, error %v", len(msg), len(got), err)
+	}
+}
@@ -3,6 +3,13 @@ package log
 import (
 	"fmt"
 
+	"github.com/turbot/tailpipe-plugin-core/formats"
+	"github.com/turbot/tailpipe-plugin-core/sources/command"
+	"github.com/turbot/tailpipe-plugin-core/sources/container_logs"
+	"github.com/turbot/tailpipe-plugin-core/sources/http_receiver"
+	"github.com/turbot/tailpipe-plugin-core/sources/journal"
+	"github.com/turbot/tailpipe-plugin-core/sources/stdin"
+	"github.com/turbot/tailpipe-plugin-core/sources/syslog"
 	"github.com/turbot/tailpipe-plugin-sdk/artifact_source"
 	"github.com/turbot/tailpipe-plugin-sdk/constants"
 	"github.com/turbot/tailpipe-plugin-sdk/row_source"
@@ -32,16 +39,36 @@ func (c *CustomLogTable) GetSourceMetadata() ([]*table.SourceMetadata[*types.Dyn
 		return nil, fmt.Errorf("error creating '%s' mapper for custom table '%s': %w", c.Format.Identifier(), c.Identifier(), err)
 	}
 
-	return []*table.SourceMetadata[*types.DynamicRow]{
-		{
-			// any artifact source
-			SourceName: constants.ArtifactSourceIdentifier,
-			Mapper:     mapper,
-			Options: []row_source.RowSourceOption{
-				artifact_source.WithRowPerLine(),
-			},
-		},
-	}, nil
+	// any artifact source, plus the sources which spool their data to a local artifact
+	// (these must be listed explicitly as the sdk does not recognise them as artifact sources)
+	sourceNames := []string{
+		constants.ArtifactSourceIdentifier,
+		stdin.StdinSourceIdentifier,
+		syslog.SyslogSourceIdentifier,
+		http_receiver.HttpReceiverSourceIdentifier,
+		command.CommandSourceIdentifier,
+		journal.JournalSourceIdentifier,
+		container_logs.ContainerLogsSourceIdentifier,
+	}
+
+	// formats which choose how their artifacts are read (e.g. binary files, JSON documents, multiline records or whole
+	// files) provide their own source options - otherwise each line is a row
+	opts := []row_source.RowSourceOption{
+		artifact_source.WithRowPerLine(),
+	}
+	if p, ok := c.Format.(formats.SourceOptionsProvider); ok {
+		opts = p.GetSourceOptions()
+	}
+
+	var res []*table.SourceMetadata[*types.DynamicRow]
+	for _, sourceName := range sourceNames {
+		res = append(res, &table.SourceMetadata[*types.DynamicRow]{
+			SourceName: sourceName,
+			Mapper:     newRowErrorMapper(mapper),
+			Options:    opts,
+		})
+	}
+	return res, nil
 }
 
 func (c *CustomLogTable) GetTableDefinition() *schema.TableSchema {
@@ -0,0 +1,34 @@
+package log
+
+import (
+	"context"
+
+	"github.com/turbot/tailpipe-plugin-sdk/mappers"
+	"github.com/turbot/tailpipe-plugin-sdk/types"
+)
+
+// rowErrorMapper wraps the mapper of the format
+// A source may send an error in place of a row which it could not read (for example the file source sends an error
+// for a row containing sequences which are invalid in the configured encoding) - the mapper returns this error,
+// so it is reported as a row error
+type rowErrorMapper struct {
+	mappers.Mapper[*types.DynamicRow]
+}
+
+func newRowErrorMapper(mapper mappers.Mapper[*types.DynamicRow]) *rowErrorMapper {
+	return &rowErrorMapper{Mapper: mapper}
+}
+
+func (m *rowErrorMapper) Map(ctx context.Context, a any, opts ...mappers.MapOption[*types.DynamicRow]) (*types.DynamicRow, error) {
+	if err, ok := a.(error); ok {
+		return nil, err
+	}
+	return m.Mapper.Map(ctx, a, opts...)
+}
+
+// OnHeader passes the header row to the wrapped mapper, if it handles it
+func (m *rowErrorMapper) OnHeader(header []string) {
+	if h, ok := m.Mapper.(mappers.HeaderHandler); ok {
+		h.OnHeader(header)
+	}
+}